@@ -16,8 +16,36 @@ func LoadConfig() {
 
 	viper.AutomaticEnv() // use env vars from system or .env
 	viper.SetDefault("PORT", "8080")
+	viper.SetDefault("JWT_SIGNING_METHOD", "HS256")
 	viper.SetDefault("JWT_SECRET", "supersecret")
-	viper.SetDefault("JWT_EXPIRE_HOURS", 24)
+	viper.SetDefault("JWT_ACCESS_EXPIRE_MINUTES", 15)
+	viper.SetDefault("REFRESH_TOKEN_EXPIRE_HOURS", 720)
+	viper.SetDefault("PASSWORD_RESET_EXPIRE_MINUTES", 30)
+	viper.SetDefault("EMAIL_VERIFICATION_EXPIRE_HOURS", 48)
+	viper.SetDefault("REQUIRE_EMAIL_VERIFICATION", false)
+	viper.SetDefault("MFA_ISSUER", "Expense Tracker")
+	viper.SetDefault("MFA_CHALLENGE_EXPIRE_MINUTES", 5)
+	viper.SetDefault("LOGIN_MAX_FAILURES_PER_EMAIL", 10)
+	viper.SetDefault("LOGIN_MAX_FAILURES_PER_IP", 50)
+	viper.SetDefault("LOGIN_FAILURE_WINDOW_MINUTES", 15)
+	viper.SetDefault("LOGIN_LOCKOUT_MINUTES", 15)
+	viper.SetDefault("ACCOUNT_DELETION_GRACE_DAYS", 14)
+	viper.SetDefault("ACCOUNT_PURGE_INTERVAL_MINUTES", 60)
+	viper.SetDefault("TRANSACTION_MAX_FUTURE_DAYS", 30)
+	viper.SetDefault("FX_RATE_SOURCE", "ecb")
+	viper.SetDefault("FX_MAX_RATE_AGE_DAYS", 7)
+	viper.SetDefault("FX_RATES_FORMAT", "ecb")
+	viper.SetDefault("FX_IMPORT_INTERVAL_HOURS", 24)
+	viper.SetDefault("RECURRING_INTERVAL_MINUTES", 15)
+	viper.SetDefault("BILL_REMINDER_INTERVAL_MINUTES", 60)
+	viper.SetDefault("BILL_REMINDER_DAYS", 3)
+	viper.SetDefault("LARGE_TRANSACTION_FACTOR", 3)
+	viper.SetDefault("LARGE_TRANSACTION_MIN_HISTORY", 5)
+	viper.SetDefault("APP_URL", "http://localhost:8080")
+	viper.SetDefault("API_URL", "http://localhost:8080")
+	viper.SetDefault("MAIL_DRIVER", "log")
+	viper.SetDefault("MAIL_FILE_DIR", "tmp/mail")
+	viper.SetDefault("MAIL_FROM", "Expense Tracker <no-reply@localhost>")
 }
 
 // Helper to get a config value
@@ -29,3 +57,8 @@ func Get(key string) string {
 func GetInt(key string) int {
 	return viper.GetInt(key)
 }
+
+// Or as bool
+func GetBool(key string) bool {
+	return viper.GetBool(key)
+}
@@ -0,0 +1,251 @@
+package controllers
+
+import (
+	"backend101/dto"
+	"backend101/money"
+	"backend101/services"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// CreateAccount godoc
+// @Summary Create an account
+// @Description Add a checking, savings, cash or credit card account. The currency defaults to the preferred currency and cannot be changed later.
+// @Tags Accounts
+// @Accept  json
+// @Produce  json
+// @Param account body dto.CreateAccountInput true "Account to create"
+// @Success 201 {object} dto.AccountResponse
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /accounts [post]
+func CreateAccount(c *gin.Context) {
+	var input dto.CreateAccountInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.MustGet("userID").(uint)
+
+	account, err := services.CreateAccount(userID, input)
+	if err != nil {
+		respondAccountError(c, err, "Failed to create account")
+		return
+	}
+
+	c.JSON(http.StatusCreated, dto.NewAccountResponse(*account, apiVersion(c)))
+}
+
+// respondAccountError maps errors from the account service to a response.
+func respondAccountError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Account not found"})
+	case errors.Is(err, services.ErrAccountInUse):
+		c.JSON(http.StatusConflict, gin.H{"error": "Account has transactions or recurring transactions; archive it instead"})
+	case errors.Is(err, money.ErrTooManyDigits):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "opening_balance has more decimal places than the currency allows"})
+	case isAmountError(err):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "opening_balance must be a decimal number"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+// GetAccounts godoc
+// @Summary List accounts
+// @Description List the user's accounts by name. Archived accounts are left out unless include_archived is true.
+// @Tags Accounts
+// @Produce  json
+// @Param include_archived query bool false "Include archived accounts"
+// @Success 200 {array} dto.AccountResponse
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /accounts [get]
+func GetAccounts(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	accounts, err := services.ListAccounts(userID, c.Query("include_archived") == "true")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve accounts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewAccountResponses(accounts, apiVersion(c)))
+}
+
+// GetAccount godoc
+// @Summary Get an account
+// @Tags Accounts
+// @Produce  json
+// @Param id path string true "Account ID"
+// @Success 200 {object} dto.AccountResponse
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /accounts/{id} [get]
+func GetAccount(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	account, err := services.GetAccount(userID, c.Param("id"))
+	if err != nil {
+		respondAccountError(c, err, "Failed to retrieve account")
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewAccountResponse(*account, apiVersion(c)))
+}
+
+// UpdateAccount godoc
+// @Summary Update an account
+// @Description Change the name, type or opening balance; omitted fields are left unchanged
+// @Tags Accounts
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Account ID"
+// @Param account body dto.UpdateAccountInput true "Fields to change"
+// @Success 200 {object} dto.AccountResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /accounts/{id} [patch]
+func UpdateAccount(c *gin.Context) {
+	var input dto.UpdateAccountInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.MustGet("userID").(uint)
+
+	account, err := services.UpdateAccount(userID, c.Param("id"), input)
+	if err != nil {
+		respondAccountError(c, err, "Failed to update account")
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewAccountResponse(*account, apiVersion(c)))
+}
+
+// ArchiveAccount godoc
+// @Summary Archive an account
+// @Description Hide an account from listings and balances. Its transactions are kept and still count towards the overall balance.
+// @Tags Accounts
+// @Produce  json
+// @Param id path string true "Account ID"
+// @Success 200 {object} dto.AccountResponse
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /accounts/{id}/archive [post]
+func ArchiveAccount(c *gin.Context) {
+	setAccountArchived(c, true)
+}
+
+// UnarchiveAccount godoc
+// @Summary Restore an archived account
+// @Tags Accounts
+// @Produce  json
+// @Param id path string true "Account ID"
+// @Success 200 {object} dto.AccountResponse
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /accounts/{id}/unarchive [post]
+func UnarchiveAccount(c *gin.Context) {
+	setAccountArchived(c, false)
+}
+
+func setAccountArchived(c *gin.Context, archived bool) {
+	userID := c.MustGet("userID").(uint)
+
+	account, err := services.SetAccountArchived(userID, c.Param("id"), archived)
+	if err != nil {
+		respondAccountError(c, err, "Failed to update account")
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewAccountResponse(*account, apiVersion(c)))
+}
+
+// DeleteAccount godoc
+// @Summary Delete an account
+// @Description Delete an account that has no transactions. Accounts with transactions must be archived instead.
+// @Tags Accounts
+// @Produce  json
+// @Param id path string true "Account ID"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /accounts/{id} [delete]
+func DeleteAccount(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	if err := services.DeleteAccount(userID, c.Param("id")); err != nil {
+		respondAccountError(c, err, "Failed to delete account")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Account deleted"})
+}
+
+// GetAccountBalances godoc
+// @Summary Get account balances
+// @Description Balance of every account in its own currency (opening balance plus income minus expenses), and the total converted to the report currency at the latest exchange rate. Accounts without a rate are listed in missing_rates and left out of the total.
+// @Tags Accounts
+// @Produce  json
+// @Param currency query string false "ISO 4217 currency to report the total in; defaults to the preferred currency"
+// @Param include_archived query bool false "Include archived accounts"
+// @Success 200 {object} dto.AccountBalances
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /accounts/balances [get]
+func GetAccountBalances(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	prefs, err := services.GetPreferences(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate balances"})
+		return
+	}
+	currency := strings.ToUpper(c.DefaultQuery("currency", prefs.Currency))
+	if len(currency) != 3 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "currency must be an ISO 4217 code"})
+		return
+	}
+
+	balances, err := services.GetAccountBalances(userID, currency, c.Query("include_archived") == "true")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate balances"})
+		return
+	}
+
+	version := apiVersion(c)
+	response := dto.AccountBalances{
+		Currency:     balances.Currency,
+		Total:        dto.Amount(balances.Total, balances.Currency, version),
+		Accounts:     make([]dto.AccountBalance, len(balances.Accounts)),
+		RateSource:   balances.RateSource,
+		MissingRates: balances.MissingRates,
+	}
+	for i, b := range balances.Accounts {
+		response.Accounts[i] = dto.AccountBalance{
+			AccountResponse: dto.NewAccountResponse(b.Account, version),
+			Balance:         dto.Amount(b.Balance, b.Account.Currency, version),
+		}
+		if b.Converted != nil {
+			response.Accounts[i].ConvertedBalance = dto.Amount(*b.Converted, balances.Currency, version)
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
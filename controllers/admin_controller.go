@@ -0,0 +1,218 @@
+package controllers
+
+import (
+	"backend101/models"
+	"backend101/services"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func userIDParam(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func respondAdminError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	case errors.Is(err, services.ErrCannotModifySelf):
+		c.JSON(http.StatusConflict, gin.H{"error": "You cannot disable or demote yourself"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+// AdminListUsers godoc
+// @Summary List and search users
+// @Description Search users by name or email and filter by role or status
+// @Tags Admin
+// @Produce  json
+// @Param q query string false "Name or email substring"
+// @Param role query string false "user, admin or auditor"
+// @Param status query string false "active or disabled"
+// @Param page query int false "Page number (default 1)"
+// @Param limit query int false "Page size (default 20, max 100)"
+// @Success 200 {object} models.AdminUserList
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /admin/users [get]
+func AdminListUsers(c *gin.Context) {
+	var q models.AdminUserQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	list, err := services.SearchUsers(q)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve users"})
+		return
+	}
+
+	c.JSON(http.StatusOK, list)
+}
+
+// AdminGetUser godoc
+// @Summary Get a user
+// @Tags Admin
+// @Produce  json
+// @Param id path int true "User ID"
+// @Success 200 {object} models.User
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /admin/users/{id} [get]
+func AdminGetUser(c *gin.Context) {
+	id, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	user, err := services.GetUser(id)
+	if err != nil {
+		respondAdminError(c, err, "Failed to retrieve user")
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// AdminDisableUser godoc
+// @Summary Disable a user
+// @Description Block the account from signing in and revoke all of its tokens
+// @Tags Admin
+// @Produce  json
+// @Param id path int true "User ID"
+// @Success 200 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Security BearerAuth
+// @Router /admin/users/{id}/disable [post]
+func AdminDisableUser(c *gin.Context) {
+	setUserDisabled(c, true)
+}
+
+// AdminEnableUser godoc
+// @Summary Enable a user
+// @Tags Admin
+// @Produce  json
+// @Param id path int true "User ID"
+// @Success 200 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /admin/users/{id}/enable [post]
+func AdminEnableUser(c *gin.Context) {
+	setUserDisabled(c, false)
+}
+
+func setUserDisabled(c *gin.Context, disabled bool) {
+	id, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	actorID := c.MustGet("userID").(uint)
+	if err := services.SetUserDisabled(c.Request.Context(), actorID, id, disabled); err != nil {
+		respondAdminError(c, err, "Failed to update user")
+		return
+	}
+
+	message := "User enabled"
+	if disabled {
+		message = "User disabled"
+	}
+	c.JSON(http.StatusOK, gin.H{"message": message})
+}
+
+// AdminUpdateUserRole godoc
+// @Summary Change a user's role
+// @Tags Admin
+// @Accept  json
+// @Produce  json
+// @Param id path int true "User ID"
+// @Param request body models.UpdateRoleRequest true "New role"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Security BearerAuth
+// @Router /admin/users/{id}/role [put]
+func AdminUpdateUserRole(c *gin.Context) {
+	id, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	actorID := c.MustGet("userID").(uint)
+	if err := services.SetUserRole(c.Request.Context(), actorID, id, req.Role); err != nil {
+		respondAdminError(c, err, "Failed to update role")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Role updated"})
+}
+
+// AdminForcePasswordReset godoc
+// @Summary Force a password reset
+// @Description Sign the user out everywhere, block every login, including social login, until the password is reset, and email a reset link
+// @Tags Admin
+// @Produce  json
+// @Param id path int true "User ID"
+// @Success 200 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /admin/users/{id}/force-password-reset [post]
+func AdminForcePasswordReset(c *gin.Context) {
+	id, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := services.ForcePasswordReset(c.Request.Context(), id); err != nil {
+		respondAdminError(c, err, "Failed to force password reset")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset required; reset link sent"})
+}
+
+// AdminTransactionStats godoc
+// @Summary System-wide transaction statistics
+// @Description Aggregated counts and totals across all users. Descriptions are never included.
+// @Tags Admin
+// @Produce  json
+// @Success 200 {object} models.TransactionStats
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /admin/stats/transactions [get]
+func AdminTransactionStats(c *gin.Context) {
+	stats, err := services.GetTransactionStats()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute statistics"})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
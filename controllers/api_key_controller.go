@@ -0,0 +1,97 @@
+package controllers
+
+import (
+	"backend101/models"
+	"backend101/services"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// CreateAPIKey godoc
+// @Summary Create an API key
+// @Description Create a personal API key with the given scopes. The full key is only returned in this response.
+// @Tags API Keys
+// @Accept  json
+// @Produce  json
+// @Param request body models.CreateAPIKeyRequest true "Key name, scopes and optional expiry"
+// @Success 201 {object} models.CreateAPIKeyResponse
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /user/api-keys [post]
+func CreateAPIKey(c *gin.Context) {
+	var req models.CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.MustGet("userID").(uint)
+	key, err := services.CreateAPIKey(userID, req)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidScope) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "valid_scopes": models.APIKeyScopes})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create API key"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, key)
+}
+
+// GetAPIKeys godoc
+// @Summary List API keys
+// @Description List the authenticated user's active API keys
+// @Tags API Keys
+// @Produce  json
+// @Success 200 {array} models.APIKey
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /user/api-keys [get]
+func GetAPIKeys(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	keys, err := services.ListAPIKeys(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve API keys"})
+		return
+	}
+
+	c.JSON(http.StatusOK, keys)
+}
+
+// RevokeAPIKey godoc
+// @Summary Revoke an API key
+// @Description Revoke an API key. It stops working immediately.
+// @Tags API Keys
+// @Produce  json
+// @Param id path int true "API key ID"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /user/api-keys/{id} [delete]
+func RevokeAPIKey(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+		return
+	}
+
+	if err := services.RevokeAPIKey(userID, uint(id)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke API key"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "API key revoked"})
+}
@@ -4,20 +4,28 @@ import (
 	"backend101/database"
 	"backend101/models"
 	"backend101/services"
+	"errors"
+	"io"
+	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // Register godoc
 // @Summary Register a new user
-// @Description Create a new user account
+// @Description Create a new user account and email a verification link
 // @Tags Auth
 // @Accept  json
 // @Produce  json
 // @Param user body models.RegisterRequest true "User Registration Request"
 // @Success 201 {object} map[string]string
 // @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /auth/register [post]
 func Register(c *gin.Context) {
@@ -42,25 +50,42 @@ func Register(c *gin.Context) {
 		Password: hashedPassword,
 	}
 
-	// Save user
-	if err := database.DB.Create(&user).Error; err != nil {
+	// Save user together with their default categories
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		return services.SeedDefaultCategories(tx, user.ID)
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Email is already registered"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Couldn't create user"})
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{"message": "User Registered successfully"})
+	// The account exists either way; a failed email can be re-sent later.
+	if err := services.SendVerificationEmail(&user); err != nil {
+		log.Printf("❌ Failed to send verification email to user %d: %v", user.ID, err)
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "User Registered successfully. Check your email to verify your address."})
 }
 
 // Login godoc
 // @Summary Login user
-// @Description Authenticate user and return JWT token
+// @Description Authenticate user and return a short-lived access token and a refresh token. If MFA is enabled, an mfa_required challenge is returned instead; complete it at /auth/mfa/verify.
 // @Tags Auth
 // @Accept  json
 // @Produce  json
 // @Param credentials body models.LoginRequest true "User login credentials"
-// @Success 200 {object} map[string]string
+// @Success 200 {object} models.TokenResponse
+// @Success 200 {object} models.MFAChallengeResponse
 // @Failure 400 {object} map[string]string
 // @Failure 401 {object} map[string]string
+// @Failure 429 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /auth/login [post]
 func Login(c *gin.Context) {
@@ -68,24 +93,218 @@ func Login(c *gin.Context) {
 
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
+	ctx := c.Request.Context()
+	ip := c.ClientIP()
+
+	if err := services.CheckLoginThrottle(ctx, req.Email, ip); err != nil {
+		respondThrottled(c, err)
+		return
+	}
+
+	// Unknown emails and wrong passwords must be indistinguishable, in both
+	// the response body and the time it takes.
+	var found *models.User
 	var user models.User
-	if err := database.DB.Where("email = ?", req.Email).First(&user).Error; err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid Credentials"})
+	err := database.DB.Where("email = ?", req.Email).First(&user).Error
+	switch {
+	case err == nil:
+		found = &user
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to login"})
 		return
 	}
 
-	if !services.CheckPasswordHash(req.Password, user.Password) {
+	if !services.VerifyUserPassword(found, req.Password) {
+		var userID *uint
+		if found != nil {
+			userID = &found.ID
+		}
+		if err := services.RecordLoginFailure(ctx, req.Email, ip, userID); err != nil {
+			log.Printf("❌ Failed to record login failure: %v", err)
+		}
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
 
-	token, err := services.GenerateJWT(user.ID)
+	// With MFA the login is only complete once the second factor is checked.
+	if user.MFAEnabledAt == nil {
+		if err := services.ResetLoginFailures(ctx, req.Email); err != nil {
+			log.Printf("❌ Failed to reset login failures: %v", err)
+		}
+	}
+
+	completeLogin(c, &user)
+}
+
+// completeLogin finishes a successful first-factor login, by password or
+// with a provider: users with MFA get a challenge token, everyone else gets
+// access and refresh tokens.
+func completeLogin(c *gin.Context, user *models.User) {
+	if user.DisabledAt != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account is disabled"})
+		return
+	}
+	if user.PasswordResetRequired {
+		respondPasswordResetRequired(c)
+		return
+	}
+
+	if user.MFAEnabledAt != nil {
+		challenge, err := services.IssueMFAChallenge(user.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+			return
+		}
+		c.JSON(http.StatusOK, challenge)
+		return
+	}
+
+	respondWithTokens(c, user)
+}
+
+// respondThrottled answers with 429 and Retry-After for a *ThrottledError
+// and with 500 for anything else.
+func respondThrottled(c *gin.Context, err error) {
+	var throttled *services.ThrottledError
+	if !errors.As(err, &throttled) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to login"})
+		return
+	}
+
+	seconds := int(math.Ceil(throttled.RetryAfter.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":       "Too many login attempts. Please try again later.",
+		"retry_after": seconds,
+	})
+}
+
+func clientInfo(c *gin.Context) services.ClientInfo {
+	return services.ClientInfo{UserAgent: c.Request.UserAgent(), IP: c.ClientIP()}
+}
+
+func respondWithTokens(c *gin.Context, user *models.User) {
+	tokens, err := services.IssueTokens(user, clientInfo(c))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrAccountDisabled):
+			c.JSON(http.StatusForbidden, gin.H{"error": "Account is disabled"})
+		case errors.Is(err, services.ErrPasswordResetRequired):
+			respondPasswordResetRequired(c)
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+func respondPasswordResetRequired(c *gin.Context) {
+	c.JSON(http.StatusForbidden, gin.H{"error": "Password reset required. Check your email for a reset link."})
+}
+
+// Refresh godoc
+// @Summary Refresh tokens
+// @Description Exchange a refresh token for a new access token and a rotated refresh token. Replaying an already used refresh token revokes the whole token family.
+// @Tags Auth
+// @Accept  json
+// @Produce  json
+// @Param request body models.RefreshRequest true "Refresh token"
+// @Success 200 {object} models.TokenResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/refresh [post]
+func Refresh(c *gin.Context) {
+	var req models.RefreshRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tokens, err := services.RefreshTokens(req.RefreshToken, clientInfo(c))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		if errors.Is(err, services.ErrInvalidRefreshToken) || errors.Is(err, services.ErrRefreshTokenReused) || errors.Is(err, services.ErrAccountDisabled) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired refresh token"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// Logout godoc
+// @Summary Logout
+// @Description Revoke the current access token and end its session. A refresh token passed in the body is revoked as well.
+// @Tags Auth
+// @Accept  json
+// @Produce  json
+// @Param request body models.LogoutRequest false "Refresh token to revoke"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /auth/logout [post]
+func Logout(c *gin.Context) {
+	var req models.LogoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	userID := c.MustGet("userID").(uint)
+	tokenID := c.GetString("tokenID")
+	expiresAt := c.MustGet("tokenExpiresAt").(time.Time)
+
+	if err := services.RevokeAccessToken(ctx, tokenID, expiresAt); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to logout"})
+		return
+	}
+
+	if sessionID := c.GetString("sessionID"); sessionID != "" {
+		err := services.RevokeUserSession(ctx, userID, sessionID)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to logout"})
+			return
+		}
+	}
+
+	if req.RefreshToken != "" {
+		if err := services.RevokeRefreshToken(ctx, userID, req.RefreshToken); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to logout"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out"})
+}
+
+// LogoutAll godoc
+// @Summary Logout everywhere
+// @Description Revoke every access and refresh token issued to the authenticated user
+// @Tags Auth
+// @Produce  json
+// @Success 200 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /auth/logout-all [post]
+func LogoutAll(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	if err := services.RevokeAllUserTokens(c.Request.Context(), userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to logout"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"token": token})
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out from all devices"})
 }
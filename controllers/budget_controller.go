@@ -0,0 +1,254 @@
+package controllers
+
+import (
+	"backend101/dto"
+	"backend101/money"
+	"backend101/services"
+	"errors"
+	"math"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// CreateBudget godoc
+// @Summary Create a budget
+// @Description Cap the spending in an expense category (including its subcategories) or on a tag per week, month or year. With rollover, what is left at the end of a period is added to the next one.
+// @Tags Budgets
+// @Accept  json
+// @Produce  json
+// @Param budget body dto.CreateBudgetInput true "Budget to create"
+// @Success 201 {object} dto.BudgetResponse
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /budgets [post]
+func CreateBudget(c *gin.Context) {
+	var input dto.CreateBudgetInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.MustGet("userID").(uint)
+
+	budget, err := services.CreateBudget(userID, input)
+	if err != nil {
+		respondBudgetError(c, err, "Failed to create budget")
+		return
+	}
+
+	c.JSON(http.StatusCreated, dto.NewBudgetResponse(*budget, apiVersion(c)))
+}
+
+// respondBudgetError maps errors from the budget service to a response.
+func respondBudgetError(c *gin.Context, err error, message string) {
+	var validation *services.ValidationError
+	switch {
+	case errors.As(err, &validation):
+		c.JSON(http.StatusBadRequest, gin.H{"validation_errors": validation.Fields})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Budget not found"})
+	case errors.Is(err, services.ErrCategoryNotFound):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "category_id does not refer to one of your categories"})
+	case errors.Is(err, services.ErrCategoryTypeMismatch):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Budgets are for expense categories"})
+	case errors.Is(err, services.ErrTagNotFound):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "tag_id does not refer to one of your tags"})
+	case errors.Is(err, services.ErrBudgetDates):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "end_date must not be before start_date"})
+	case errors.Is(err, services.ErrInvalidDate):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "dates must be YYYY-MM-DD"})
+	case errors.Is(err, money.ErrTooManyDigits):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "amount has more decimal places than the currency allows"})
+	case isAmountError(err):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "amount must be a decimal number"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+// GetBudgets godoc
+// @Summary List budgets
+// @Tags Budgets
+// @Produce  json
+// @Success 200 {array} dto.BudgetResponse
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /budgets [get]
+func GetBudgets(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	budgets, err := services.ListBudgets(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve budgets"})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewBudgetResponses(budgets, apiVersion(c)))
+}
+
+// GetBudget godoc
+// @Summary Get a budget
+// @Tags Budgets
+// @Produce  json
+// @Param id path string true "Budget ID"
+// @Success 200 {object} dto.BudgetResponse
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /budgets/{id} [get]
+func GetBudget(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	budget, err := services.GetBudget(userID, c.Param("id"))
+	if err != nil {
+		respondBudgetError(c, err, "Failed to retrieve budget")
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewBudgetResponse(*budget, apiVersion(c)))
+}
+
+// UpdateBudget godoc
+// @Summary Update a budget
+// @Description Change the name, amount, rollover or end date. A new amount also applies to earlier periods when working out what rolls over.
+// @Tags Budgets
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Budget ID"
+// @Param budget body dto.UpdateBudgetInput true "Fields to change"
+// @Success 200 {object} dto.BudgetResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /budgets/{id} [patch]
+func UpdateBudget(c *gin.Context) {
+	var input dto.UpdateBudgetInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.MustGet("userID").(uint)
+
+	budget, err := services.UpdateBudget(userID, c.Param("id"), input)
+	if err != nil {
+		respondBudgetError(c, err, "Failed to update budget")
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewBudgetResponse(*budget, apiVersion(c)))
+}
+
+// DeleteBudget godoc
+// @Summary Delete a budget
+// @Tags Budgets
+// @Produce  json
+// @Param id path string true "Budget ID"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /budgets/{id} [delete]
+func DeleteBudget(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	if err := services.DeleteBudget(userID, c.Param("id")); err != nil {
+		respondBudgetError(c, err, "Failed to delete budget")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Budget deleted"})
+}
+
+// GetBudgetProgress godoc
+// @Summary Get a budget's progress
+// @Description Spent, remaining and projected spending in the current period, or the period containing date. Spending in other currencies is converted at the rate on its date; spending in a currency listed in exchange_rates.missing_rates has no usable rate and is not counted. The projection extends the daily rate so far to the whole period.
+// @Tags Budgets
+// @Produce  json
+// @Param id path string true "Budget ID"
+// @Param date query string false "YYYY-MM-DD in the period to report"
+// @Success 200 {object} dto.BudgetProgressResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /budgets/{id}/progress [get]
+func GetBudgetProgress(c *gin.Context) {
+	var query dto.BudgetProgressQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.MustGet("userID").(uint)
+
+	progress, err := services.GetBudgetProgress(userID, c.Param("id"), query.Date)
+	if err != nil {
+		respondBudgetError(c, err, "Failed to calculate budget progress")
+		return
+	}
+
+	c.JSON(http.StatusOK, newBudgetProgressResponse(progress, apiVersion(c)))
+}
+
+// GetBudgetSummary godoc
+// @Summary Get progress of all active budgets
+// @Description The current period of every budget that covers it, with the number of budgets on track, at risk of being overspent and overspent.
+// @Tags Budgets
+// @Produce  json
+// @Success 200 {object} dto.BudgetSummaryResponse
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /budgets/summary [get]
+func GetBudgetSummary(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	progress, err := services.GetBudgetSummary(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate budget summary"})
+		return
+	}
+
+	version := apiVersion(c)
+	summary := dto.BudgetSummaryResponse{Budgets: make([]dto.BudgetProgressResponse, len(progress))}
+	for i := range progress {
+		summary.Budgets[i] = newBudgetProgressResponse(&progress[i], version)
+		switch summary.Budgets[i].Status {
+		case dto.BudgetOnTrack:
+			summary.OnTrack++
+		case dto.BudgetAtRisk:
+			summary.AtRisk++
+		case dto.BudgetOver:
+			summary.Over++
+		}
+	}
+
+	c.JSON(http.StatusOK, summary)
+}
+
+func newBudgetProgressResponse(p *services.BudgetProgress, version int) dto.BudgetProgressResponse {
+	currency := p.Budget.Currency
+	percent := 0.0
+	if available := p.Available(); available > 0 {
+		percent = math.Round(float64(p.Spent)*1000/float64(available)) / 10
+	}
+	return dto.BudgetProgressResponse{
+		BudgetID:      p.Budget.ID,
+		Name:          p.Budget.Name,
+		Currency:      currency,
+		From:          p.Period.From,
+		To:            p.Period.To,
+		Amount:        dto.Amount(p.Budget.AmountMinor, currency, version),
+		RolledOver:    dto.Amount(p.RolledOver, currency, version),
+		Available:     dto.Amount(p.Available(), currency, version),
+		Spent:         dto.Amount(p.Spent, currency, version),
+		Remaining:     dto.Amount(p.Remaining(), currency, version),
+		Projected:     dto.Amount(p.Projected, currency, version),
+		PercentUsed:   percent,
+		Status:        p.Status(),
+		ExchangeRates: p.Conversion,
+	}
+}
@@ -0,0 +1,206 @@
+package controllers
+
+import (
+	"backend101/dto"
+	"backend101/services"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// CreateCategory godoc
+// @Summary Create a category
+// @Description Add an income or expense category, optionally nested under a top-level category of the same type
+// @Tags Categories
+// @Accept  json
+// @Produce  json
+// @Param category body dto.CreateCategoryInput true "Category to create"
+// @Success 201 {object} models.Category
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /categories [post]
+func CreateCategory(c *gin.Context) {
+	var input dto.CreateCategoryInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.MustGet("userID").(uint)
+
+	category, err := services.CreateCategory(userID, input)
+	if err != nil {
+		respondCategoryError(c, err, "Failed to create category")
+		return
+	}
+
+	c.JSON(http.StatusCreated, category)
+}
+
+// respondCategoryError maps errors from the category service to a response.
+func respondCategoryError(c *gin.Context, err error, message string) {
+	var validation *services.ValidationError
+	switch {
+	case errors.As(err, &validation):
+		c.JSON(http.StatusBadRequest, gin.H{"validation_errors": validation.Fields})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+	case errors.Is(err, services.ErrCategoryNotFound):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The referenced category does not exist"})
+	case errors.Is(err, services.ErrCategoryDepth):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Categories can only be nested one level deep"})
+	case errors.Is(err, services.ErrCategoryTypeMismatch):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Both categories must have the same type"})
+	case errors.Is(err, services.ErrMergeIntoSelf):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A category cannot be merged into itself or one of its children"})
+	case errors.Is(err, services.ErrCategoryExists):
+		c.JSON(http.StatusConflict, gin.H{"error": "A category with this name already exists"})
+	case errors.Is(err, services.ErrCategoryInUse):
+		c.JSON(http.StatusConflict, gin.H{"error": "Category has transactions, budgets or subcategories; merge it into another category instead"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+// GetCategories godoc
+// @Summary List categories
+// @Description List the user's categories. Child categories have a parent_id.
+// @Tags Categories
+// @Produce  json
+// @Param type query string false "income or expense"
+// @Success 200 {array} models.Category
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /categories [get]
+func GetCategories(c *gin.Context) {
+	typ := c.Query("type")
+	if typ != "" && typ != "income" && typ != "expense" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "type must be income or expense"})
+		return
+	}
+
+	userID := c.MustGet("userID").(uint)
+
+	categories, err := services.ListCategories(userID, typ)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve categories"})
+		return
+	}
+
+	c.JSON(http.StatusOK, categories)
+}
+
+// GetCategory godoc
+// @Summary Get a category
+// @Tags Categories
+// @Produce  json
+// @Param id path string true "Category ID"
+// @Success 200 {object} models.Category
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /categories/{id} [get]
+func GetCategory(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	category, err := services.GetCategory(userID, c.Param("id"))
+	if err != nil {
+		respondCategoryError(c, err, "Failed to retrieve category")
+		return
+	}
+
+	c.JSON(http.StatusOK, category)
+}
+
+// UpdateCategory godoc
+// @Summary Update a category
+// @Description Change the name, parent, color or icon; omitted fields are left unchanged. A renamed category is renamed on its transactions too.
+// @Tags Categories
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Category ID"
+// @Param category body dto.UpdateCategoryInput true "Fields to change"
+// @Success 200 {object} models.Category
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /categories/{id} [patch]
+func UpdateCategory(c *gin.Context) {
+	var input dto.UpdateCategoryInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.MustGet("userID").(uint)
+
+	category, err := services.UpdateCategory(userID, c.Param("id"), input)
+	if err != nil {
+		respondCategoryError(c, err, "Failed to update category")
+		return
+	}
+
+	c.JSON(http.StatusOK, category)
+}
+
+// DeleteCategory godoc
+// @Summary Delete a category
+// @Description Delete a category without transactions or subcategories. Use merge for the others.
+// @Tags Categories
+// @Produce  json
+// @Param id path string true "Category ID"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /categories/{id} [delete]
+func DeleteCategory(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	if err := services.DeleteCategory(userID, c.Param("id")); err != nil {
+		respondCategoryError(c, err, "Failed to delete category")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Category deleted"})
+}
+
+// MergeCategory godoc
+// @Summary Merge categories
+// @Description Move every transaction of the category to into_id, move its subcategories under into_id (or into_id's parent), and delete it
+// @Tags Categories
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Category to merge away"
+// @Param merge body dto.MergeCategoryInput true "Target category"
+// @Success 200 {object} models.Category
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /categories/{id}/merge [post]
+func MergeCategory(c *gin.Context) {
+	var input dto.MergeCategoryInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.MustGet("userID").(uint)
+
+	category, err := services.MergeCategories(userID, c.Param("id"), input.IntoID)
+	if err != nil {
+		respondCategoryError(c, err, "Failed to merge categories")
+		return
+	}
+
+	c.JSON(http.StatusOK, category)
+}
@@ -0,0 +1,68 @@
+package controllers
+
+import (
+	"backend101/models"
+	"backend101/services"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// VerifyEmail godoc
+// @Summary Verify email address
+// @Description Confirm the account's email address with the token from the verification email
+// @Tags Auth
+// @Accept  json
+// @Produce  json
+// @Param request body models.VerifyEmailRequest true "Verification token"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/verify-email [post]
+func VerifyEmail(c *gin.Context) {
+	var req models.VerifyEmailRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := services.VerifyEmail(req.Token); err != nil {
+		if errors.Is(err, services.ErrInvalidUserToken) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired verification token"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified"})
+}
+
+// ResendVerification godoc
+// @Summary Resend verification email
+// @Description Send a new verification link. The response is the same whether or not the email is registered or already verified.
+// @Tags Auth
+// @Accept  json
+// @Produce  json
+// @Param request body models.ResendVerificationRequest true "Account email"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/resend-verification [post]
+func ResendVerification(c *gin.Context) {
+	var req models.ResendVerificationRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := services.ResendVerificationEmail(req.Email); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send verification email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "If the email is registered and unverified, a verification link has been sent"})
+}
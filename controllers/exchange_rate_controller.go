@@ -0,0 +1,85 @@
+package controllers
+
+import (
+	"backend101/models"
+	"backend101/services"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetExchangeRates godoc
+// @Summary Get exchange rates
+// @Description List the rates of the configured rate source for a day, or for the latest day with rates
+// @Tags Exchange rates
+// @Produce  json
+// @Param date query string false "YYYY-MM-DD"
+// @Success 200 {array} models.ExchangeRate
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /exchange-rates [get]
+func GetExchangeRates(c *gin.Context) {
+	var day time.Time
+	if raw := c.Query("date"); raw != "" {
+		var err error
+		if day, err = time.Parse("2006-01-02", raw); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "date must be YYYY-MM-DD"})
+			return
+		}
+	}
+
+	rates, err := services.ListExchangeRates(services.RateSource(), day)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve exchange rates"})
+		return
+	}
+
+	c.JSON(http.StatusOK, rates)
+}
+
+// AdminImportExchangeRates godoc
+// @Summary Import exchange rates
+// @Description Import rates from an uploaded ECB XML (eurofxref) or CSV (date,base,quote,rate) file. Rates already stored for the same source, pair and day are replaced.
+// @Tags Admin
+// @Accept  multipart/form-data
+// @Produce  json
+// @Param file formData file true "Rates file"
+// @Param format formData string true "ecb or csv"
+// @Param source formData string false "Source name to store the rates under (default: the format)"
+// @Success 200 {object} models.ExchangeRateImportResult
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /admin/exchange-rates/import [post]
+func AdminImportExchangeRates(c *gin.Context) {
+	header, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+		return
+	}
+
+	open := func(context.Context) (io.ReadCloser, error) { return header.Open() }
+	provider, err := services.NewRateProvider(c.PostForm("format"), c.PostForm("source"), open)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be ecb or csv"})
+		return
+	}
+
+	imported, err := services.ImportRates(c.Request.Context(), provider)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidRates) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import exchange rates"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ExchangeRateImportResult{Source: provider.Name(), Imported: imported})
+}
@@ -0,0 +1,27 @@
+package controllers
+
+import (
+	"backend101/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JWKS godoc
+// @Summary JSON Web Key Set
+// @Description Public keys that verify access tokens issued by this API. Empty when tokens are signed with a shared HMAC secret.
+// @Tags Auth
+// @Produce  json
+// @Success 200 {object} services.JWKSet
+// @Failure 500 {object} map[string]string
+// @Router /.well-known/jwks.json [get]
+func JWKS(c *gin.Context) {
+	set, err := services.PublicJWKS()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load signing keys"})
+		return
+	}
+
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, set)
+}
@@ -0,0 +1,178 @@
+package controllers
+
+import (
+	"backend101/models"
+	"backend101/services"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// respondMFAError maps MFA service errors to HTTP responses.
+func respondMFAError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrMFAAlreadyEnabled):
+		c.JSON(http.StatusConflict, gin.H{"error": "MFA is already enabled"})
+	case errors.Is(err, services.ErrMFANotEnabled):
+		c.JSON(http.StatusConflict, gin.H{"error": "MFA is not enabled"})
+	case errors.Is(err, services.ErrMFANoEnrollment):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Start enrollment first"})
+	case errors.Is(err, services.ErrInvalidMFACode):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid code"})
+	case errors.Is(err, services.ErrInvalidPassword):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid password"})
+	case errors.Is(err, services.ErrInvalidMFAToken):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired MFA token"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "MFA request failed"})
+	}
+}
+
+// EnrollMFA godoc
+// @Summary Start MFA enrollment
+// @Description Generate a TOTP secret and otpauth:// URI. MFA is enabled only after it is confirmed with a valid code.
+// @Tags MFA
+// @Produce  json
+// @Success 200 {object} models.MFAEnrollResponse
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /user/mfa/enroll [post]
+func EnrollMFA(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	enrollment, err := services.BeginMFAEnrollment(userID)
+	if err != nil {
+		respondMFAError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, enrollment)
+}
+
+// ConfirmMFA godoc
+// @Summary Confirm MFA enrollment
+// @Description Enable MFA with the first code from the authenticator app. Returns one-time recovery codes, shown only once.
+// @Tags MFA
+// @Accept  json
+// @Produce  json
+// @Param request body models.MFACodeRequest true "TOTP code"
+// @Success 200 {object} models.RecoveryCodesResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /user/mfa/confirm [post]
+func ConfirmMFA(c *gin.Context) {
+	var req models.MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.MustGet("userID").(uint)
+	codes, err := services.ConfirmMFAEnrollment(userID, req.Code)
+	if err != nil {
+		respondMFAError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// DisableMFA godoc
+// @Summary Disable MFA
+// @Description Turn off MFA. Requires the current password and a TOTP or recovery code.
+// @Tags MFA
+// @Accept  json
+// @Produce  json
+// @Param request body models.MFADisableRequest true "Password and code"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /user/mfa/disable [post]
+func DisableMFA(c *gin.Context) {
+	var req models.MFADisableRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.MustGet("userID").(uint)
+	if err := services.DisableMFA(userID, req.Password, req.Code); err != nil {
+		respondMFAError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "MFA disabled"})
+}
+
+// RegenerateRecoveryCodes godoc
+// @Summary Regenerate recovery codes
+// @Description Replace all recovery codes. Requires a current TOTP code.
+// @Tags MFA
+// @Accept  json
+// @Produce  json
+// @Param request body models.MFACodeRequest true "TOTP code"
+// @Success 200 {object} models.RecoveryCodesResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /user/mfa/recovery-codes [post]
+func RegenerateRecoveryCodes(c *gin.Context) {
+	var req models.MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.MustGet("userID").(uint)
+	codes, err := services.RegenerateRecoveryCodes(userID, req.Code)
+	if err != nil {
+		respondMFAError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// VerifyMFA godoc
+// @Summary Complete an MFA login
+// @Description Exchange the mfa_token from login and a TOTP or recovery code for access and refresh tokens
+// @Tags Auth
+// @Accept  json
+// @Produce  json
+// @Param request body models.MFAVerifyRequest true "Challenge token and code"
+// @Success 200 {object} models.TokenResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/mfa/verify [post]
+func VerifyMFA(c *gin.Context) {
+	var req models.MFAVerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := services.CompleteMFAChallenge(c.Request.Context(), req.MFAToken, req.Code, c.ClientIP())
+	if err != nil {
+		var throttled *services.ThrottledError
+		if errors.As(err, &throttled) {
+			respondThrottled(c, err)
+			return
+		}
+		respondMFAError(c, err)
+		return
+	}
+
+	respondWithTokens(c, user)
+}
@@ -0,0 +1,194 @@
+package controllers
+
+import (
+	"backend101/dto"
+	"backend101/services"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetNotifications godoc
+// @Summary List notifications
+// @Description In-app notifications, newest first, with the number of unread ones.
+// @Tags Notifications
+// @Produce  json
+// @Param unread query bool false "Only unread notifications"
+// @Param page query int false "Page number (default 1)"
+// @Param limit query int false "Page size (1-100, default 20)"
+// @Success 200 {object} dto.NotificationList
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /notifications [get]
+func GetNotifications(c *gin.Context) {
+	var query dto.NotificationQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.MustGet("userID").(uint)
+
+	list, err := services.ListNotifications(userID, query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve notifications"})
+		return
+	}
+
+	c.JSON(http.StatusOK, list)
+}
+
+// respondNotificationError maps errors from the notification service to a
+// response.
+func respondNotificationError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+	case errors.Is(err, services.ErrUnknownChannel):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown notification channel"})
+	case errors.Is(err, services.ErrWebhookURLRequired):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Set a url to enable the webhook channel"})
+	case errors.Is(err, services.ErrWebhookURLNotAllowed):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The webhook url must use https and point to a public address"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+// MarkNotificationRead godoc
+// @Summary Mark a notification as read
+// @Tags Notifications
+// @Produce  json
+// @Param id path string true "Notification ID"
+// @Success 200 {object} models.Notification
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /notifications/{id}/read [post]
+func MarkNotificationRead(c *gin.Context) {
+	setNotificationRead(c, true)
+}
+
+// MarkNotificationUnread godoc
+// @Summary Mark a notification as unread
+// @Tags Notifications
+// @Produce  json
+// @Param id path string true "Notification ID"
+// @Success 200 {object} models.Notification
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /notifications/{id}/unread [post]
+func MarkNotificationUnread(c *gin.Context) {
+	setNotificationRead(c, false)
+}
+
+func setNotificationRead(c *gin.Context, read bool) {
+	userID := c.MustGet("userID").(uint)
+
+	n, err := services.SetNotificationRead(userID, c.Param("id"), read)
+	if err != nil {
+		respondNotificationError(c, err, "Failed to update notification")
+		return
+	}
+
+	c.JSON(http.StatusOK, n)
+}
+
+// MarkAllNotificationsRead godoc
+// @Summary Mark all notifications as read
+// @Tags Notifications
+// @Produce  json
+// @Success 200 {object} map[string]interface{}
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /notifications/read-all [post]
+func MarkAllNotificationsRead(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	count, err := services.MarkAllNotificationsRead(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notifications"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Notifications marked as read", "count": count})
+}
+
+// DeleteNotification godoc
+// @Summary Delete a notification
+// @Description Remove a notification from the list. The same event is not notified again.
+// @Tags Notifications
+// @Produce  json
+// @Param id path string true "Notification ID"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /notifications/{id} [delete]
+func DeleteNotification(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	if err := services.DeleteNotification(userID, c.Param("id")); err != nil {
+		respondNotificationError(c, err, "Failed to delete notification")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Notification deleted"})
+}
+
+// GetNotificationChannels godoc
+// @Summary Get notification channel preferences
+// @Description Whether each channel (in_app, email, webhook) is enabled and which kinds of notification it gets. Only in_app is enabled by default.
+// @Tags Notifications
+// @Produce  json
+// @Success 200 {array} dto.ChannelResponse
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /notifications/channels [get]
+func GetNotificationChannels(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	prefs, err := services.GetNotificationPreferences(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve notification channels"})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewChannelResponses(prefs))
+}
+
+// UpdateNotificationChannel godoc
+// @Summary Update a notification channel
+// @Description Enable or disable a channel and choose the kinds of notification it gets: budget_threshold, negative_balance, large_transaction and upcoming_bill. The webhook channel needs a url; its secret is generated when the url is first set.
+// @Tags Notifications
+// @Accept  json
+// @Produce  json
+// @Param channel path string true "in_app, email or webhook"
+// @Param preferences body dto.UpdateChannelInput true "Fields to change"
+// @Success 200 {object} dto.ChannelResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /notifications/channels/{channel} [put]
+func UpdateNotificationChannel(c *gin.Context) {
+	var input dto.UpdateChannelInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.MustGet("userID").(uint)
+
+	pref, err := services.UpdateNotificationPreference(userID, c.Param("channel"), input)
+	if err != nil {
+		respondNotificationError(c, err, "Failed to update notification channel")
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewChannelResponse(*pref))
+}
@@ -0,0 +1,131 @@
+package controllers
+
+import (
+	"backend101/services"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// OAuthLogin godoc
+// @Summary Start social login
+// @Description Redirect to the external OAuth2/OIDC provider's sign-in page
+// @Tags Auth
+// @Param provider path string true "Provider name, e.g. google or github"
+// @Success 302
+// @Failure 404 {object} map[string]string
+// @Failure 502 {object} map[string]string
+// @Router /auth/oauth/{provider}/login [get]
+func OAuthLogin(c *gin.Context) {
+	url, err := services.BeginOAuthLogin(c.Request.Context(), c.Param("provider"))
+	if err != nil {
+		if errors.Is(err, services.ErrUnknownProvider) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Unknown provider"})
+			return
+		}
+		log.Printf("❌ OAuth login for %s failed: %v", c.Param("provider"), err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Provider unavailable"})
+		return
+	}
+
+	c.Redirect(http.StatusFound, url)
+}
+
+// OAuthCallback godoc
+// @Summary Social login callback
+// @Description Handle the provider redirect, link or create the local account by verified email, and return the same tokens as /auth/login
+// @Tags Auth
+// @Produce  json
+// @Param provider path string true "Provider name"
+// @Param code query string true "Authorization code"
+// @Param state query string true "State from the login redirect"
+// @Success 200 {object} models.TokenResponse
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 502 {object} map[string]string
+// @Router /auth/oauth/{provider}/callback [get]
+func OAuthCallback(c *gin.Context) {
+	if reason := c.Query("error"); reason != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Sign-in was cancelled or denied: " + reason})
+		return
+	}
+
+	user, err := services.CompleteOAuthLogin(c.Request.Context(), c.Param("provider"), c.Query("state"), c.Query("code"))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrUnknownProvider):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Unknown provider"})
+		case errors.Is(err, services.ErrInvalidOAuthState):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired login attempt"})
+		case errors.Is(err, services.ErrExternalEmailUnverified):
+			c.JSON(http.StatusForbidden, gin.H{"error": "The provider did not return a verified email address"})
+		default:
+			log.Printf("❌ OAuth callback for %s failed: %v", c.Param("provider"), err)
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Sign-in with provider failed"})
+		}
+		return
+	}
+
+	completeLogin(c, user)
+}
+
+// GetIdentities godoc
+// @Summary List linked identities
+// @Description List the external sign-in providers linked to the authenticated user
+// @Tags User
+// @Produce  json
+// @Success 200 {array} models.UserIdentity
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /user/identities [get]
+func GetIdentities(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	identities, err := services.ListIdentities(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve identities"})
+		return
+	}
+
+	c.JSON(http.StatusOK, identities)
+}
+
+// DeleteIdentity godoc
+// @Summary Unlink an identity
+// @Description Remove a linked provider. The last sign-in method of an account without a password cannot be removed.
+// @Tags User
+// @Produce  json
+// @Param id path int true "Identity ID"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /user/identities/{id} [delete]
+func DeleteIdentity(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Identity not found"})
+		return
+	}
+
+	if err := services.UnlinkIdentity(userID, uint(id)); err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Identity not found"})
+		case errors.Is(err, services.ErrLastLoginMethod):
+			c.JSON(http.StatusConflict, gin.H{"error": "Set a password before removing your only sign-in provider"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove identity"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Identity removed"})
+}
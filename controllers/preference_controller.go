@@ -0,0 +1,60 @@
+package controllers
+
+import (
+	"backend101/models"
+	"backend101/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetPreferences godoc
+// @Summary Get preferences
+// @Description Get the user's currency, time zone, locale, week start and month start day. Defaults are returned if none were set.
+// @Tags User
+// @Produce  json
+// @Success 200 {object} models.UserPreference
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /user/preferences [get]
+func GetPreferences(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	prefs, err := services.GetPreferences(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve preferences"})
+		return
+	}
+
+	c.JSON(http.StatusOK, prefs)
+}
+
+// UpdatePreferences godoc
+// @Summary Update preferences
+// @Description Update the preferences present in the body; omitted fields are left unchanged
+// @Tags User
+// @Accept  json
+// @Produce  json
+// @Param preferences body models.UpdatePreferencesRequest true "Preferences"
+// @Success 200 {object} models.UserPreference
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /user/preferences [patch]
+func UpdatePreferences(c *gin.Context) {
+	var req models.UpdatePreferencesRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.MustGet("userID").(uint)
+	prefs, err := services.UpdatePreferences(userID, req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update preferences"})
+		return
+	}
+
+	c.JSON(http.StatusOK, prefs)
+}
@@ -0,0 +1,269 @@
+package controllers
+
+import (
+	"backend101/dto"
+	"backend101/money"
+	"backend101/services"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// CreateRecurringRule godoc
+// @Summary Create a recurring transaction
+// @Description Create a transaction on every occurrence of a daily, weekly, monthly or yearly schedule. Occurrences between start_date and today are created right away.
+// @Tags Recurring
+// @Accept  json
+// @Produce  json
+// @Param rule body dto.CreateRecurringInput true "Recurring rule to create"
+// @Success 201 {object} dto.RecurringResponse
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /recurring [post]
+func CreateRecurringRule(c *gin.Context) {
+	var input dto.CreateRecurringInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.MustGet("userID").(uint)
+
+	rule, err := services.CreateRecurringRule(userID, input)
+	if err != nil {
+		respondRecurringError(c, err, "Failed to create recurring transaction")
+		return
+	}
+
+	c.JSON(http.StatusCreated, dto.NewRecurringResponse(*rule, apiVersion(c)))
+}
+
+// respondRecurringError maps errors from the recurring service to a response.
+func respondRecurringError(c *gin.Context, err error, message string) {
+	var validation *services.ValidationError
+	switch {
+	case errors.As(err, &validation):
+		c.JSON(http.StatusBadRequest, gin.H{"validation_errors": validation.Fields})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Recurring transaction not found"})
+	case errors.Is(err, services.ErrNotAnOccurrence):
+		c.JSON(http.StatusNotFound, gin.H{"error": "The recurring transaction does not occur on this date"})
+	case errors.Is(err, services.ErrOccurrenceCreated):
+		c.JSON(http.StatusConflict, gin.H{"error": "This occurrence has already been created; change its transaction instead"})
+	case errors.Is(err, services.ErrInvalidRecurrence):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidDate):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "dates must be YYYY-MM-DD"})
+	case errors.Is(err, services.ErrCategoryNotFound):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "category_id does not refer to one of your categories"})
+	case errors.Is(err, services.ErrCategoryTypeMismatch):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The category is for a different transaction type"})
+	case errors.Is(err, services.ErrAccountNotFound):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "account_id does not refer to one of your accounts"})
+	case errors.Is(err, services.ErrAccountArchived):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Transactions cannot be added to an archived account"})
+	case errors.Is(err, money.ErrTooManyDigits):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "amount has more decimal places than the currency allows"})
+	case isAmountError(err):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "amount must be a decimal number"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+// GetRecurringRules godoc
+// @Summary List recurring transactions
+// @Description Rules that are still running come first, by their next occurrence.
+// @Tags Recurring
+// @Produce  json
+// @Success 200 {array} dto.RecurringResponse
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /recurring [get]
+func GetRecurringRules(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	rules, err := services.ListRecurringRules(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve recurring transactions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewRecurringResponses(rules, apiVersion(c)))
+}
+
+// GetRecurringRule godoc
+// @Summary Get a recurring transaction
+// @Tags Recurring
+// @Produce  json
+// @Param id path string true "Recurring rule ID"
+// @Success 200 {object} dto.RecurringResponse
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /recurring/{id} [get]
+func GetRecurringRule(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	rule, err := services.GetRecurringRule(userID, c.Param("id"))
+	if err != nil {
+		respondRecurringError(c, err, "Failed to retrieve recurring transaction")
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewRecurringResponse(*rule, apiVersion(c)))
+}
+
+// UpdateRecurringRule godoc
+// @Summary Update a recurring transaction
+// @Description Change the fields that are present. Transactions already created are not changed. A new schedule takes effect from today and discards changes to single upcoming occurrences.
+// @Tags Recurring
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Recurring rule ID"
+// @Param rule body dto.UpdateRecurringInput true "Fields to change"
+// @Success 200 {object} dto.RecurringResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /recurring/{id} [patch]
+func UpdateRecurringRule(c *gin.Context) {
+	var input dto.UpdateRecurringInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.MustGet("userID").(uint)
+
+	rule, err := services.UpdateRecurringRule(userID, c.Param("id"), input)
+	if err != nil {
+		respondRecurringError(c, err, "Failed to update recurring transaction")
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewRecurringResponse(*rule, apiVersion(c)))
+}
+
+// DeleteRecurringRule godoc
+// @Summary Delete a recurring transaction
+// @Description Stop the schedule. Transactions it already created are kept.
+// @Tags Recurring
+// @Produce  json
+// @Param id path string true "Recurring rule ID"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /recurring/{id} [delete]
+func DeleteRecurringRule(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	if err := services.DeleteRecurringRule(userID, c.Param("id")); err != nil {
+		respondRecurringError(c, err, "Failed to delete recurring transaction")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Recurring transaction deleted"})
+}
+
+// GetOccurrences godoc
+// @Summary Preview upcoming occurrences
+// @Description The next occurrences that have not been created yet, including skipped and changed ones.
+// @Tags Recurring
+// @Produce  json
+// @Param id path string true "Recurring rule ID"
+// @Param limit query int false "Number of occurrences (1-100, default 10)"
+// @Success 200 {array} dto.OccurrenceResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /recurring/{id}/occurrences [get]
+func GetOccurrences(c *gin.Context) {
+	var query dto.OccurrenceQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if query.Limit == 0 {
+		query.Limit = 10
+	}
+
+	userID := c.MustGet("userID").(uint)
+
+	rule, occurrences, err := services.UpcomingOccurrences(userID, c.Param("id"), query.Limit)
+	if err != nil {
+		respondRecurringError(c, err, "Failed to retrieve occurrences")
+		return
+	}
+
+	version := apiVersion(c)
+	out := make([]dto.OccurrenceResponse, len(occurrences))
+	for i, o := range occurrences {
+		out[i] = dto.NewOccurrenceResponse(*rule, o.Date, o.Override, version)
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// UpdateOccurrence godoc
+// @Summary Skip or change one occurrence
+// @Description Skip a single upcoming occurrence, or change its amount or description. Other occurrences are not affected.
+// @Tags Recurring
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Recurring rule ID"
+// @Param date path string true "Occurrence date (YYYY-MM-DD)"
+// @Param occurrence body dto.UpdateOccurrenceInput true "Changes to the occurrence"
+// @Success 200 {object} dto.OccurrenceResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /recurring/{id}/occurrences/{date} [patch]
+func UpdateOccurrence(c *gin.Context) {
+	var input dto.UpdateOccurrenceInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.MustGet("userID").(uint)
+
+	rule, occurrence, err := services.UpdateOccurrence(userID, c.Param("id"), c.Param("date"), input)
+	if err != nil {
+		respondRecurringError(c, err, "Failed to update occurrence")
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewOccurrenceResponse(*rule, occurrence.Date, occurrence.Override, apiVersion(c)))
+}
+
+// ResetOccurrence godoc
+// @Summary Restore one occurrence
+// @Description Undo skipping or changing a single upcoming occurrence.
+// @Tags Recurring
+// @Produce  json
+// @Param id path string true "Recurring rule ID"
+// @Param date path string true "Occurrence date (YYYY-MM-DD)"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /recurring/{id}/occurrences/{date} [delete]
+func ResetOccurrence(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	if err := services.ResetOccurrence(userID, c.Param("id"), c.Param("date")); err != nil {
+		respondRecurringError(c, err, "Failed to restore occurrence")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Occurrence restored"})
+}
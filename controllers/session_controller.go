@@ -0,0 +1,57 @@
+package controllers
+
+import (
+	"backend101/services"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetSessions godoc
+// @Summary List active sessions
+// @Description List the devices the user is signed in on. The session of the current token is marked current.
+// @Tags User
+// @Produce  json
+// @Success 200 {array} models.Session
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /user/sessions [get]
+func GetSessions(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	sessions, err := services.ListSessions(userID, c.GetString("sessionID"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, sessions)
+}
+
+// DeleteSession godoc
+// @Summary Revoke a session
+// @Description Sign out a device. Its access and refresh tokens stop working immediately.
+// @Tags User
+// @Produce  json
+// @Param id path string true "Session ID"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /user/sessions/{id} [delete]
+func DeleteSession(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	if err := services.RevokeUserSession(c.Request.Context(), userID, c.Param("id")); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked"})
+}
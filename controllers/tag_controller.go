@@ -0,0 +1,219 @@
+package controllers
+
+import (
+	"backend101/dto"
+	"backend101/services"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// CreateTag godoc
+// @Summary Create a tag
+// @Description Tags are stored in lower case. Tags are also created on the fly when a transaction uses a new one.
+// @Tags Tags
+// @Accept  json
+// @Produce  json
+// @Param tag body dto.TagInput true "Tag to create"
+// @Success 201 {object} models.Tag
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /tags [post]
+func CreateTag(c *gin.Context) {
+	var input dto.TagInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.MustGet("userID").(uint)
+
+	tag, err := services.CreateTag(userID, input.Name)
+	if err != nil {
+		respondTagError(c, err, "Failed to create tag")
+		return
+	}
+
+	c.JSON(http.StatusCreated, tag)
+}
+
+func respondTagError(c *gin.Context, err error, message string) {
+	var validation *services.ValidationError
+	switch {
+	case errors.As(err, &validation):
+		c.JSON(http.StatusBadRequest, gin.H{"validation_errors": validation.Fields})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+	case errors.Is(err, services.ErrTagExists):
+		c.JSON(http.StatusConflict, gin.H{"error": "A tag with this name already exists"})
+	case errors.Is(err, services.ErrTagInUse):
+		c.JSON(http.StatusConflict, gin.H{"error": "Tag has budgets; delete them first"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+// GetTags godoc
+// @Summary List tags
+// @Tags Tags
+// @Produce  json
+// @Success 200 {array} models.Tag
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /tags [get]
+func GetTags(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	tags, err := services.ListTags(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve tags"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tags)
+}
+
+// GetTag godoc
+// @Summary Get a tag
+// @Tags Tags
+// @Produce  json
+// @Param id path string true "Tag ID"
+// @Success 200 {object} models.Tag
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /tags/{id} [get]
+func GetTag(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	tag, err := services.GetTag(userID, c.Param("id"))
+	if err != nil {
+		respondTagError(c, err, "Failed to retrieve tag")
+		return
+	}
+
+	c.JSON(http.StatusOK, tag)
+}
+
+// RenameTag godoc
+// @Summary Rename a tag
+// @Description The new name applies to every transaction with the tag
+// @Tags Tags
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Tag ID"
+// @Param tag body dto.TagInput true "New name"
+// @Success 200 {object} models.Tag
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /tags/{id} [patch]
+func RenameTag(c *gin.Context) {
+	var input dto.TagInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.MustGet("userID").(uint)
+
+	tag, err := services.RenameTag(userID, c.Param("id"), input.Name)
+	if err != nil {
+		respondTagError(c, err, "Failed to rename tag")
+		return
+	}
+
+	c.JSON(http.StatusOK, tag)
+}
+
+// DeleteTag godoc
+// @Summary Delete a tag
+// @Description Delete a tag and remove it from its transactions. The transactions are kept. Tags with budgets cannot be deleted.
+// @Tags Tags
+// @Produce  json
+// @Param id path string true "Tag ID"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /tags/{id} [delete]
+func DeleteTag(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	if err := services.DeleteTag(userID, c.Param("id")); err != nil {
+		respondTagError(c, err, "Failed to delete tag")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Tag deleted"})
+}
+
+// GetTagTotals godoc
+// @Summary Get totals per tag
+// @Description Income and expense totals of each tag's transactions, converted like the balance. A transaction with several tags counts towards each of them, so the totals do not add up to the balance.
+// @Tags Tags
+// @Produce  json
+// @Param period query string false "all (default), week, month or year"
+// @Param currency query string false "ISO 4217 currency to report in; defaults to the preferred currency"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /tags/totals [get]
+func GetTagTotals(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	prefs, err := services.GetPreferences(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate tag totals"})
+		return
+	}
+	period, err := services.CurrentPeriod(c.Query("period"), prefs, services.Now())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "period must be one of all, week, month, year"})
+		return
+	}
+
+	currency := strings.ToUpper(c.DefaultQuery("currency", prefs.Currency))
+	if len(currency) != 3 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "currency must be an ISO 4217 code"})
+		return
+	}
+
+	totals, conversion, err := services.GetTagTotals(userID, currency, period, services.UserLocation(prefs))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate tag totals"})
+		return
+	}
+
+	version := apiVersion(c)
+	tags := make([]dto.TagTotal, len(totals))
+	for i, t := range totals {
+		tags[i] = dto.TagTotal{
+			ID:           t.Tag.ID,
+			Name:         t.Tag.Name,
+			IncomeTotal:  dto.Amount(t.Totals.Income, currency, version),
+			ExpenseTotal: dto.Amount(t.Totals.Expense, currency, version),
+		}
+	}
+
+	response := gin.H{
+		"currency":       currency,
+		"tags":           tags,
+		"exchange_rates": conversion,
+	}
+	if period != nil {
+		response["period"] = period.Name
+		response["from"] = period.From
+		response["to"] = period.To
+	}
+
+	c.JSON(http.StatusOK, response)
+}
@@ -1,127 +1,221 @@
 package controllers
 
 import (
-	"backend101/database"
-	"backend101/models"
-	"backend101/utils"
+	"backend101/config"
+	"backend101/dto"
+	"backend101/money"
+	"backend101/services"
+	"errors"
+	"fmt"
 	"net/http"
-	"time"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // CreateTransaction godoc
 // @Summary Create a transaction
-// @Description Add a new income or expense transaction
+// @Description Add a new income or expense transaction. date is optional (RFC 3339 or YYYY-MM-DD in the user's time zone) and defaults to now. With account_id, the currency defaults to the account's currency.
 // @Tags Transactions
 // @Accept  json
 // @Produce  json
 // @Param transaction body dto.CreateTransactionInput true "Transaction to create"
-// @Success 200 {object} models.Transaction
+// @Success 200 {object} dto.TransactionResponse
 // @Failure 400 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Security BearerAuth
 // @Router /transactions [post]
 func CreateTransaction(c *gin.Context) {
-	var tx models.Transaction
+	var input dto.CreateTransactionInput
 
-	if err := c.ShouldBindJSON(&tx); err != nil {
+	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Validate
-	if validationErrors := utils.ValidateStruct(&tx); validationErrors != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"validation_errors": validationErrors})
-	}
-
 	userID := c.MustGet("userID").(uint)
-	tx.UserID = userID
-	tx.Date = time.Now()
 
-	if err := database.DB.Create(&tx).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create transaction."})
+	tx, err := services.CreateTransaction(userID, input)
+	if err != nil {
+		respondTransactionError(c, err, "Failed to create transaction.")
 		return
 	}
 
-	c.JSON(http.StatusOK, tx)
+	c.JSON(http.StatusOK, dto.NewTransactionResponse(*tx, apiVersion(c)))
+}
+
+// respondTransactionError maps errors from creating or updating a
+// transaction to a response.
+func respondTransactionError(c *gin.Context, err error, message string) {
+	var validation *services.ValidationError
+	switch {
+	case errors.As(err, &validation):
+		c.JSON(http.StatusBadRequest, gin.H{"validation_errors": validation.Fields})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+	case errors.Is(err, services.ErrTransferLeg):
+		c.JSON(http.StatusConflict, gin.H{"error": "Transaction is part of a transfer; change it with PATCH /api/transfers/:id"})
+	case errors.Is(err, services.ErrCategoryNotFound):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "category_id does not refer to one of your categories"})
+	case errors.Is(err, services.ErrCategoryTypeMismatch):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The category is for a different transaction type"})
+	case errors.Is(err, services.ErrAccountNotFound):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "account_id does not refer to one of your accounts"})
+	case errors.Is(err, services.ErrAccountArchived):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Transactions cannot be added to an archived account"})
+	case errors.Is(err, services.ErrInvalidDate):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "date must be an RFC 3339 timestamp or a YYYY-MM-DD date"})
+	case errors.Is(err, services.ErrDateTooFarInFuture):
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("date cannot be more than %d days in the future", config.GetInt("TRANSACTION_MAX_FUTURE_DAYS"))})
+	case errors.Is(err, services.ErrDateTooOld):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "date is too far in the past"})
+	case errors.Is(err, money.ErrTooManyDigits):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "amount has more decimal places than the currency allows"})
+	case isAmountError(err):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "amount must be a decimal number"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+func isAmountError(err error) bool {
+	return errors.Is(err, money.ErrInvalidAmount) || errors.Is(err, money.ErrTooManyDigits) || errors.Is(err, money.ErrOutOfRange)
+}
+
+// apiVersion is the version chosen with the X-API-Version header.
+func apiVersion(c *gin.Context) int {
+	return c.GetInt("apiVersion")
 }
 
 // GetTransactions godoc
-// @Summary Get all user transactions
-// @Description Retrieve all transactions for the authenticated user
+// @Summary Get user transactions
+// @Description Retrieve a page of the authenticated user's transactions. Pass next_cursor from the previous page as cursor to get the next one.
 // @Tags Transactions
 // @Produce  json
-// @Success 200 {array} models.Transaction
+// @Param type query string false "income, expense, transfer_in or transfer_out"
+// @Param category query string false "Exact category"
+// @Param category_id query int false "Category ID; includes its child categories"
+// @Param currency query string false "ISO 4217 currency"
+// @Param account_id query int false "Account ID"
+// @Param min_amount query string false "Minimum amount, in currency or the preferred currency; only matches transactions in that currency"
+// @Param max_amount query string false "Maximum amount, in currency or the preferred currency"
+// @Param from query string false "Earliest date, RFC 3339 or YYYY-MM-DD"
+// @Param to query string false "Latest date, RFC 3339 or YYYY-MM-DD (inclusive)"
+// @Param q query string false "Description contains (case-insensitive)"
+// @Param tag query []string false "Tag; repeat for several" collectionFormat(multi)
+// @Param tag_mode query string false "any (default): at least one tag; all: every tag"
+// @Param sort query string false "-date (default), date, -amount or amount"
+// @Param cursor query string false "Cursor from the previous page"
+// @Param limit query int false "Page size, 1-200 (default 50)"
+// @Success 200 {object} dto.TransactionPage
+// @Failure 400 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Security BearerAuth
 // @Router /transactions [get]
 func GetTransactions(c *gin.Context) {
+	var query dto.TransactionQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	userID := c.MustGet("userID").(uint)
 
-	var transaction []models.Transaction
-	if err := database.DB.Where("user_id = ?", userID).Find(&transaction).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve transactions"})
+	page, err := services.ListTransactions(userID, query)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidCursor):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+		case errors.Is(err, services.ErrInvalidDate):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from and to must be RFC 3339 timestamps or YYYY-MM-DD dates"})
+		case isAmountError(err):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "min_amount and max_amount must be decimal amounts in the currency"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve transactions"})
+		}
 		return
 	}
 
-	c.JSON(http.StatusOK, transaction)
+	c.JSON(http.StatusOK, dto.TransactionPage{
+		Data:       dto.NewTransactionResponses(page.Transactions, apiVersion(c)),
+		NextCursor: page.NextCursor,
+		Total:      page.Total,
+	})
 }
 
 // UpdateTransaction godoc
 // @Summary Update a transaction
-// @Description Update an existing transaction by ID for the authenticated user
+// @Description Replace an existing transaction by ID for the authenticated user. The date is kept unless one is given.
 // @Tags Transactions
 // @Accept  json
 // @Produce  json
 // @Param id path string true "Transaction ID"
 // @Param transaction body dto.UpdateTransactionInput true "Updated transaction data"
-// @Success 200 {object} models.Transaction
+// @Success 200 {object} dto.TransactionResponse
 // @Failure 400 {object} map[string]string
 // @Failure 404 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Security BearerAuth
 // @Router /transactions/{id} [put]
 func UpdateTransaction(c *gin.Context) {
-	userID := c.MustGet("userID").(uint)
-	id := c.Param("id")
-
-	var tx models.Transaction
-	if err := database.DB.Where("id =? AND user_id = ?", id, userID).First(&tx).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+	var input dto.UpdateTransactionInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	var input models.Transaction
+	updateTransaction(c, dto.PatchTransactionInput{
+		Amount:      &input.Amount,
+		Type:        &input.Type,
+		CategoryID:  input.CategoryID,
+		Category:    &input.Category,
+		Description: &input.Description,
+		Date:        input.Date,
+		AccountID:   input.AccountID,
+		Tags:        input.Tags,
+	})
+}
+
+// PatchTransaction godoc
+// @Summary Partially update a transaction
+// @Description Change only the fields present in the body
+// @Tags Transactions
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Transaction ID"
+// @Param transaction body dto.PatchTransactionInput true "Fields to change"
+// @Success 200 {object} dto.TransactionResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /transactions/{id} [patch]
+func PatchTransaction(c *gin.Context) {
+	var input dto.PatchTransactionInput
 	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Validate
-	if validationErrors := utils.ValidateStruct(&input); validationErrors != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"validation_errors": validationErrors})
-		return
-	}
+	updateTransaction(c, input)
+}
 
-	// Only allow updates to this field and only update fields after validation
-	tx.Amount = input.Amount
-	tx.Category = input.Category
-	tx.Description = input.Description
-	tx.Type = input.Type
-	tx.Date = input.Date
+func updateTransaction(c *gin.Context, input dto.PatchTransactionInput) {
+	userID := c.MustGet("userID").(uint)
 
-	if err := database.DB.Save(&tx).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update transaction"})
+	tx, err := services.UpdateTransaction(userID, c.Param("id"), input)
+	if err != nil {
+		respondTransactionError(c, err, "Failed to update transaction")
 		return
 	}
 
-	c.JSON(http.StatusOK, tx)
+	c.JSON(http.StatusOK, dto.NewTransactionResponse(*tx, apiVersion(c)))
 }
 
 // DeleteTransaction godoc
 // @Summary Delete a transaction
-// @Description Delete a transaction by ID for the authenticated user
+// @Description Delete a transaction by ID for the authenticated user. Deleting a leg of a transfer deletes the whole transfer.
 // @Tags Transactions
 // @Produce  json
 // @Param id path string true "Transaction ID"
@@ -132,15 +226,12 @@ func UpdateTransaction(c *gin.Context) {
 // @Router /transactions/{id} [delete]
 func DeleteTransaction(c *gin.Context) {
 	userID := c.MustGet("userID").(uint)
-	id := c.Param("id")
 
-	var tx models.Transaction
-	if err := database.DB.Where("id = ? AND user_id = ?", id, userID).First(&tx).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
-		return
-	}
-
-	if err := database.DB.Delete(&tx).Error; err != nil {
+	if err := services.DeleteTransaction(userID, c.Param("id")); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete transaction"})
 		return
 	}
@@ -150,28 +241,53 @@ func DeleteTransaction(c *gin.Context) {
 
 // GetBalance godoc
 // @Summary Get current balance
-// @Description Calculate and return total income, total expenses, and balance status (positive/negative)
+// @Description Calculate and return total income, total expenses, and balance status (positive/negative). Transfers between accounts are neither income nor expense and are not counted. Transactions in other currencies are converted at the exchange rate on their date; exchange_rates states the rate source. Transactions in a currency listed in exchange_rates.missing_rates have no usable rate and are left out of every total, so the balance is incomplete while that list is not empty. With a period, only transactions in the current week, month or year are counted, using the user's time zone, week start and month start day.
 // @Tags Transactions
 // @Produce  json
+// @Param period query string false "all (default), week, month or year"
+// @Param currency query string false "ISO 4217 currency to report in; defaults to the preferred currency"
 // @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Security BearerAuth
 // @Router /transactions/balance [get]
 func GetBalance(c *gin.Context) {
 	userID := c.MustGet("userID").(uint)
 
-	var incomeTotal float64
-	var expenseTotal float64
+	prefs, err := services.GetPreferences(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate balance"})
+		return
+	}
+	period, err := services.CurrentPeriod(c.Query("period"), prefs, services.Now())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "period must be one of all, week, month, year"})
+		return
+	}
+
+	currency := strings.ToUpper(c.DefaultQuery("currency", prefs.Currency))
+	if len(currency) != 3 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "currency must be an ISO 4217 code"})
+		return
+	}
 
-	// Sum incomes
-	database.DB.Model(&models.Transaction{}).
-		Where("user_id = ? AND type = ?", userID, "income").
-		Select("COALESCE(SUM(amount), 0)").Scan(&incomeTotal)
+	// Transactions of the user in the requested period
+	scope := func(db *gorm.DB) *gorm.DB {
+		db = db.Where("user_id = ?", userID)
+		if period != nil {
+			db = db.Where("date >= ? AND date < ?", period.From, period.To)
+		}
+		return db
+	}
 
-	// Sum expenses
-	database.DB.Model(&models.Transaction{}).
-		Where("user_id = ? AND type = ?", userID, "expense").
-		Select("COALESCE(SUM(amount), 0)").Scan(&expenseTotal)
+	// Amounts are summed in minor units, so the totals are exact. Transfer
+	// legs are left out: they only move money between accounts.
+	totals, conversion, err := services.SumTransactions(scope, currency, services.UserLocation(prefs))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate balance"})
+		return
+	}
+	incomeTotal, expenseTotal := totals.Income, totals.Expense
 
 	balance := incomeTotal - expenseTotal
 	status := "positive"
@@ -179,10 +295,20 @@ func GetBalance(c *gin.Context) {
 		status = "negative"
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"income_total":   incomeTotal,
-		"expense_total":  expenseTotal,
-		"balance":        balance,
+	version := apiVersion(c)
+	response := gin.H{
+		"currency":       currency,
+		"income_total":   dto.Amount(incomeTotal, currency, version),
+		"expense_total":  dto.Amount(expenseTotal, currency, version),
+		"balance":        dto.Amount(balance, currency, version),
 		"financial_zone": status,
-	})
+		"exchange_rates": conversion,
+	}
+	if period != nil {
+		response["period"] = period.Name
+		response["from"] = period.From
+		response["to"] = period.To
+	}
+
+	c.JSON(http.StatusOK, response)
 }
@@ -0,0 +1,170 @@
+package controllers
+
+import (
+	"backend101/dto"
+	"backend101/services"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// CreateTransfer godoc
+// @Summary Transfer between accounts
+// @Description Move money from one account to another. Both legs are created together and count towards account balances, but not towards income or expenses. Between currencies, pass rate (destination units per source unit) or to_amount.
+// @Tags Transfers
+// @Accept  json
+// @Produce  json
+// @Param transfer body dto.CreateTransferInput true "Transfer to create"
+// @Success 201 {object} dto.TransferResponse
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /transfers [post]
+func CreateTransfer(c *gin.Context) {
+	var input dto.CreateTransferInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.MustGet("userID").(uint)
+
+	transfer, err := services.CreateTransfer(userID, input)
+	if err != nil {
+		respondTransferError(c, err, "Failed to create transfer")
+		return
+	}
+
+	c.JSON(http.StatusCreated, dto.NewTransferResponse(*transfer, apiVersion(c)))
+}
+
+// respondTransferError maps errors from creating or updating a transfer to a
+// response.
+func respondTransferError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Transfer not found"})
+	case errors.Is(err, services.ErrSameAccount):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from_account_id and to_account_id must differ"})
+	case errors.Is(err, services.ErrTransferRateRequired):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Accounts use different currencies; pass either rate or to_amount"})
+	case errors.Is(err, services.ErrInvalidTransferRate):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "rate must be a positive decimal, and 1 between accounts in the same currency"})
+	default:
+		// Account, date, amount and validation errors are shared with
+		// transactions.
+		respondTransactionError(c, err, message)
+	}
+}
+
+// GetTransfers godoc
+// @Summary List transfers
+// @Description List the user's transfers, newest first
+// @Tags Transfers
+// @Produce  json
+// @Param account_id query int false "Transfers from or to this account"
+// @Param from query string false "Earliest date, RFC 3339 or YYYY-MM-DD"
+// @Param to query string false "Latest date, RFC 3339 or YYYY-MM-DD (inclusive)"
+// @Success 200 {array} dto.TransferResponse
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /transfers [get]
+func GetTransfers(c *gin.Context) {
+	var query dto.TransferQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.MustGet("userID").(uint)
+
+	transfers, err := services.ListTransfers(userID, query)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidDate) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from and to must be RFC 3339 timestamps or YYYY-MM-DD dates"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve transfers"})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewTransferResponses(transfers, apiVersion(c)))
+}
+
+// GetTransfer godoc
+// @Summary Get a transfer
+// @Tags Transfers
+// @Produce  json
+// @Param id path string true "Transfer ID"
+// @Success 200 {object} dto.TransferResponse
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /transfers/{id} [get]
+func GetTransfer(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	transfer, err := services.GetTransfer(userID, c.Param("id"))
+	if err != nil {
+		respondTransferError(c, err, "Failed to retrieve transfer")
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewTransferResponse(*transfer, apiVersion(c)))
+}
+
+// UpdateTransfer godoc
+// @Summary Update a transfer
+// @Description Change the amount, rate, received amount, description or date. Both legs are updated together. If only amount changes between currencies, the rate is kept.
+// @Tags Transfers
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Transfer ID"
+// @Param transfer body dto.UpdateTransferInput true "Fields to change"
+// @Success 200 {object} dto.TransferResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /transfers/{id} [patch]
+func UpdateTransfer(c *gin.Context) {
+	var input dto.UpdateTransferInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.MustGet("userID").(uint)
+
+	transfer, err := services.UpdateTransfer(userID, c.Param("id"), input)
+	if err != nil {
+		respondTransferError(c, err, "Failed to update transfer")
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.NewTransferResponse(*transfer, apiVersion(c)))
+}
+
+// DeleteTransfer godoc
+// @Summary Delete a transfer
+// @Description Delete a transfer and both its legs
+// @Tags Transfers
+// @Produce  json
+// @Param id path string true "Transfer ID"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /transfers/{id} [delete]
+func DeleteTransfer(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	if err := services.DeleteTransfer(userID, c.Param("id")); err != nil {
+		respondTransferError(c, err, "Failed to delete transfer")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Transfer deleted"})
+}
@@ -1,15 +1,275 @@
 package controllers
 
 import (
+	"backend101/models"
+	"backend101/services"
+	"errors"
+	"fmt"
+	"io"
+	"log"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 )
 
+// Me godoc
+// @Summary Get profile
+// @Description Get the authenticated user's profile
+// @Tags User
+// @Produce  json
+// @Success 200 {object} models.User
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /user/me [get]
 func Me(c *gin.Context) {
 	userID := c.MustGet("userID").(uint)
-	c.JSON(http.StatusOK, gin.H{
-		"message": "You are authenticated",
-		"user_id": userID,
+
+	user, err := services.GetProfile(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve profile"})
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// UpdateMe godoc
+// @Summary Update profile
+// @Description Update the fields present in the body, including any preferences; omitted fields are left unchanged
+// @Tags User
+// @Accept  json
+// @Produce  json
+// @Param profile body models.UpdateProfileRequest true "Profile fields"
+// @Success 200 {object} models.User
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /user/me [patch]
+func UpdateMe(c *gin.Context) {
+	var req models.UpdateProfileRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.MustGet("userID").(uint)
+	user, err := services.UpdateProfile(userID, req)
+	if errors.Is(err, services.ErrEmptyName) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update profile"})
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// ChangePassword godoc
+// @Summary Change password
+// @Description Set a new password. The current password is required, and every other session is signed out.
+// @Tags User
+// @Accept  json
+// @Produce  json
+// @Param request body models.ChangePasswordRequest true "Current and new password"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /user/password [post]
+func ChangePassword(c *gin.Context) {
+	var req models.ChangePasswordRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.MustGet("userID").(uint)
+	err := services.ChangePassword(c.Request.Context(), userID, c.GetString("sessionID"), req.CurrentPassword, req.NewPassword)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidPassword) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Current password is incorrect"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change password"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password changed. Other sessions have been signed out."})
+}
+
+// ChangeEmail godoc
+// @Summary Change email address
+// @Description Email a confirmation link to the new address. The email is only changed once the link is opened.
+// @Tags User
+// @Accept  json
+// @Produce  json
+// @Param request body models.ChangeEmailRequest true "New email and current password"
+// @Success 202 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /user/email [post]
+func ChangeEmail(c *gin.Context) {
+	var req models.ChangeEmailRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.MustGet("userID").(uint)
+	if err := services.RequestEmailChange(userID, req.Password, req.NewEmail); err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidPassword):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Password is incorrect"})
+		case errors.Is(err, services.ErrEmailTaken):
+			c.JSON(http.StatusConflict, gin.H{"error": "Email is already registered"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change email"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Check the new address for a confirmation link"})
+}
+
+// ConfirmEmailChange godoc
+// @Summary Confirm email change
+// @Description Switch to the new email address with the token from the confirmation email
+// @Tags Auth
+// @Accept  json
+// @Produce  json
+// @Param request body models.ConfirmEmailChangeRequest true "Confirmation token"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/confirm-email-change [post]
+func ConfirmEmailChange(c *gin.Context) {
+	var req models.ConfirmEmailChangeRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := services.ConfirmEmailChange(req.Token); err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidUserToken):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired confirmation token"})
+		case errors.Is(err, services.ErrEmailTaken):
+			c.JSON(http.StatusConflict, gin.H{"error": "Email is already registered"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change email"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email address changed"})
+}
+
+// ExportData godoc
+// @Summary Export personal data
+// @Description Download a ZIP archive with the user's profile, linked identities and transactions (as JSON and CSV)
+// @Tags User
+// @Produce  application/zip
+// @Success 200 {file} file
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /user/export [get]
+func ExportData(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	// Load the user before sending any headers, so this failure can still
+	// be reported.
+	user, err := services.GetProfile(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export data"})
+		return
+	}
+
+	filename := fmt.Sprintf("export-%s.zip", services.Now().UTC().Format("2006-01-02"))
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Header("Cache-Control", "no-store")
+	c.Status(http.StatusOK)
+
+	// The status is already sent once the archive starts streaming, so a
+	// failure can only be logged; the client sees a truncated archive.
+	if err := services.WriteUserExport(c.Writer, user); err != nil {
+		log.Printf("❌ Failed to export data for user %d: %v", userID, err)
+	}
+}
+
+// DeleteMe godoc
+// @Summary Delete account
+// @Description Schedule the account for permanent deletion after a grace period. Every other session is signed out. The password is required unless the account only uses social login.
+// @Tags User
+// @Accept  json
+// @Produce  json
+// @Param request body models.DeleteAccountRequest false "Current password"
+// @Success 202 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /user/me [delete]
+func DeleteMe(c *gin.Context) {
+	var req models.DeleteAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.MustGet("userID").(uint)
+	deleteAt, err := services.ScheduleAccountDeletion(c.Request.Context(), userID, c.GetString("sessionID"), req.Password)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidPassword):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Password is incorrect"})
+		case errors.Is(err, services.ErrDeletionAlreadyScheduled):
+			c.JSON(http.StatusConflict, gin.H{"error": "Account deletion is already scheduled"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete account"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":               "Account scheduled for deletion",
+		"deletion_scheduled_at": deleteAt,
 	})
 }
+
+// CancelDeletion godoc
+// @Summary Cancel account deletion
+// @Description Keep the account after a deletion was scheduled
+// @Tags User
+// @Produce  json
+// @Success 200 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /user/me/cancel-deletion [post]
+func CancelDeletion(c *gin.Context) {
+	userID := c.MustGet("userID").(uint)
+
+	if err := services.CancelAccountDeletion(userID); err != nil {
+		if errors.Is(err, services.ErrDeletionNotScheduled) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Account deletion is not scheduled"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel deletion"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Account deletion cancelled"})
+}
@@ -0,0 +1,158 @@
+package database
+
+import (
+	"backend101/models"
+	"backend101/money"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Arbitrary key for the advisory lock that serializes migrations when
+// several instances start at once.
+const migrationLockKey = 8_214_001
+
+type migration struct {
+	Version string
+	Up      func(tx *gorm.DB) error
+}
+
+// migrations run in order, once each, after AutoMigrate. Never edit or
+// reorder an entry that has shipped; add a new one instead.
+var migrations = []migration{
+	{"2026101801_transaction_amount_minor_units", migrateTransactionAmounts},
+	{"2026101802_transaction_categories", migrateTransactionCategories},
+	{"2026101803_backfill_email_verified_at", backfillEmailVerifiedAt},
+}
+
+func runMigrations() error {
+	if err := DB.AutoMigrate(&models.SchemaMigration{}); err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		err := DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", migrationLockKey).Error; err != nil {
+				return err
+			}
+
+			var count int64
+			if err := tx.Model(&models.SchemaMigration{}).Where("version = ?", m.Version).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				return nil
+			}
+
+			if err := m.Up(tx); err != nil {
+				return err
+			}
+			log.Printf("📦 Applied migration %s", m.Version)
+			return tx.Create(&models.SchemaMigration{Version: m.Version, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("migration %s: %w", m.Version, err)
+		}
+	}
+	return nil
+}
+
+// legacyCurrency is the currency of transactions stored before amounts had
+// one. Preferences can change since, so they are not used to guess it.
+const legacyCurrency = "USD"
+
+// migrateTransactionAmounts moves the float "amount" column to integer minor
+// units of legacyCurrency. Casting float8 to numeric keeps 15 significant
+// digits, which recovers the decimal value the client originally sent. Rows
+// with more decimal places than the currency allows would be rounded, so the
+// migration fails and lists them instead.
+func migrateTransactionAmounts(tx *gorm.DB) error {
+	if !tx.Migrator().HasColumn("transactions", "amount") {
+		return nil
+	}
+
+	scale := fmt.Sprintf("(amount::numeric * (10::numeric ^ %d))", money.Exponent(legacyCurrency))
+
+	inexact := tx.Table("transactions").Where("amount IS NOT NULL AND " + scale + " <> TRUNC(" + scale + ")").Session(&gorm.Session{})
+	var count int64
+	if err := inexact.Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		var ids []uint
+		if err := inexact.Order("id").Limit(20).Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		return fmt.Errorf("%d transactions have more decimal places than %s allows, fix their amount first (ids %v)",
+			count, legacyCurrency, ids)
+	}
+
+	if err := tx.Exec("UPDATE transactions SET currency = ?", legacyCurrency).Error; err != nil {
+		return err
+	}
+	if err := tx.Exec("UPDATE transactions SET amount_minor = " + scale + "::bigint WHERE amount IS NOT NULL").Error; err != nil {
+		return err
+	}
+
+	return tx.Migrator().DropColumn("transactions", "amount")
+}
+
+// migrateTransactionCategories turns free-text transaction categories into
+// category rows. Existing users get the default categories first; every
+// other distinct name per user and type, ignoring case and surrounding
+// spaces, becomes a top-level category. Transactions then point at the
+// category with their name.
+func migrateTransactionCategories(tx *gorm.DB) error {
+	// Category names are unique per user, type and parent, ignoring case.
+	if err := tx.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_categories_user_name
+		ON categories (user_id, type, COALESCE(parent_id, 0), LOWER(name))`).Error; err != nil {
+		return err
+	}
+
+	for _, d := range models.DefaultCategories {
+		if err := tx.Exec(`INSERT INTO categories (user_id, name, type, color, icon, created_at, updated_at)
+			SELECT id, ?, ?, ?, ?, NOW(), NOW() FROM users
+			ON CONFLICT DO NOTHING`, d.Name, d.Type, d.Color, d.Icon).Error; err != nil {
+			return err
+		}
+		for _, child := range d.Children {
+			if err := tx.Exec(`INSERT INTO categories (user_id, parent_id, name, type, color, icon, created_at, updated_at)
+				SELECT user_id, id, ?, type, color, icon, NOW(), NOW() FROM categories
+				WHERE parent_id IS NULL AND name = ? AND type = ?
+				ON CONFLICT DO NOTHING`, child, d.Name, d.Type).Error; err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := tx.Exec(`INSERT INTO categories (user_id, name, type, color, icon, created_at, updated_at)
+		SELECT t.user_id, MIN(TRIM(t.category)), t.type, '', '', NOW(), NOW() FROM transactions t
+		WHERE t.type IN ('income', 'expense') AND t.category_id IS NULL AND TRIM(t.category) <> ''
+			AND NOT EXISTS (SELECT 1 FROM categories c
+				WHERE c.user_id = t.user_id AND c.type = t.type AND LOWER(c.name) = LOWER(TRIM(t.category)))
+		GROUP BY t.user_id, t.type, LOWER(TRIM(t.category))
+		ON CONFLICT DO NOTHING`).Error; err != nil {
+		return err
+	}
+
+	// Prefer a top-level category when a child has the same name.
+	return tx.Exec(`UPDATE transactions t SET category_id = c.id, category = c.name
+		FROM categories c
+		WHERE t.category_id IS NULL AND t.type IN ('income', 'expense')
+			AND c.id = (SELECT c2.id FROM categories c2
+				WHERE c2.user_id = t.user_id AND c2.type = t.type AND LOWER(c2.name) = LOWER(TRIM(t.category))
+				ORDER BY c2.parent_id NULLS FIRST, c2.id LIMIT 1)`).Error
+}
+
+// backfillEmailVerifiedAt marks accounts created before email verification
+// existed as verified when they were created, so REQUIRE_EMAIL_VERIFICATION
+// does not lock them out. Users who were sent a verification link signed up
+// afterwards and are left alone.
+func backfillEmailVerifiedAt(tx *gorm.DB) error {
+	return tx.Exec(`UPDATE users u SET email_verified_at = u.created_at
+		WHERE u.email_verified_at IS NULL
+			AND NOT EXISTS (SELECT 1 FROM user_tokens t WHERE t.user_id = u.id AND t.purpose = ?)`,
+		models.TokenPurposeEmailVerification).Error
+}
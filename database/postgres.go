@@ -24,16 +24,33 @@ func ConnectPostgres() {
 	)
 
 	var err error
-	DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	DB, err = Open(dsn)
 	if err != nil {
 		log.Fatal("❌ Failed to connect to database: ", err)
 	}
 
 	log.Println("✅ Connected to PostgreSQL database!")
 
-	err = DB.AutoMigrate(&models.User{}, &models.Transaction{})
-	if err != nil {
-		log.Fatal("❌ Failed to migrate models: ", err)
+	if err := Migrate(); err != nil {
+		log.Fatal("❌ Failed to migrate database: ", err)
 	}
 	log.Println("📦 User table migrated!")
 }
+
+// Open connects to Postgres with the settings the services rely on.
+func Open(dsn string) (*gorm.DB, error) {
+	return gorm.Open(postgres.Open(dsn), &gorm.Config{
+		// Report unique violations as gorm.ErrDuplicatedKey.
+		TranslateError: true,
+	})
+}
+
+// Migrate creates or updates the tables of every model and then runs the
+// data migrations.
+func Migrate() error {
+	err := DB.AutoMigrate(&models.User{}, &models.Transaction{}, &models.RefreshToken{}, &models.UserToken{}, &models.RecoveryCode{}, &models.UserIdentity{}, &models.APIKey{}, &models.AuditEvent{}, &models.Session{}, &models.UserPreference{}, &models.ExchangeRate{}, &models.Account{}, &models.Transfer{}, &models.Category{}, &models.Tag{}, &models.RecurringRule{}, &models.RecurringOccurrence{}, &models.Budget{}, &models.Notification{}, &models.NotificationPreference{}, &models.AlertState{})
+	if err != nil {
+		return err
+	}
+	return runMigrations()
+}
@@ -0,0 +1,76 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"backend101/config"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var Redis *redis.Client
+
+// ConnectRedis points Cache at Redis when REDIS_ADDR is configured and keeps
+// the in-memory store otherwise. A configured Redis that cannot be reached is
+// fatal: falling back to per-process memory would stop revocations and login
+// lockouts from being shared between instances.
+func ConnectRedis() {
+	addr := config.Get("REDIS_ADDR")
+	if addr == "" {
+		log.Println("⚠️  REDIS_ADDR not set, using in-memory store")
+		return
+	}
+
+	Redis = redis.NewClient(&redis.Options{
+		Addr:     addr,
+		Password: config.Get("REDIS_PASSWORD"),
+		DB:       config.GetInt("REDIS_DB"),
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := Redis.Ping(ctx).Err(); err != nil {
+		log.Fatal("❌ Failed to connect to Redis: ", err)
+	}
+
+	Cache = &RedisStore{client: Redis}
+	log.Println("✅ Connected to Redis!")
+}
+
+type RedisStore struct {
+	client *redis.Client
+}
+
+func (r *RedisStore) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	return r.client.Set(ctx, key, value, ttl).Err()
+}
+
+func (r *RedisStore) Get(ctx context.Context, key string) (string, bool, error) {
+	value, err := r.client.Get(ctx, key).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return value, true, nil
+}
+
+func (r *RedisStore) Delete(ctx context.Context, key string) error {
+	return r.client.Del(ctx, key).Err()
+}
+
+func (r *RedisStore) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	pipe := r.client.TxPipeline()
+	incr := pipe.Incr(ctx, key)
+	if ttl > 0 {
+		pipe.ExpireNX(ctx, key, ttl)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return incr.Val(), nil
+}
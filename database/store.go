@@ -0,0 +1,114 @@
+package database
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Store is a small key/value abstraction for short-lived state such as
+// revoked tokens. It is backed by Redis when REDIS_ADDR is set and by an
+// in-memory map otherwise.
+type Store interface {
+	Set(ctx context.Context, key, value string, ttl time.Duration) error
+	Get(ctx context.Context, key string) (string, bool, error)
+	Delete(ctx context.Context, key string) error
+	// Incr increments the counter at key, creating it with the given TTL if
+	// it does not exist yet. The TTL is not extended by later increments.
+	Incr(ctx context.Context, key string, ttl time.Duration) (int64, error)
+}
+
+// Cache defaults to an in-memory store so code paths that depend on it work
+// without calling ConnectRedis (e.g. in tests).
+var Cache Store = NewMemoryStore()
+
+type memoryEntry struct {
+	value     string
+	expiresAt time.Time
+}
+
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]memoryEntry)}
+}
+
+func (m *MemoryStore) Set(_ context.Context, key, value string, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry := memoryEntry{value: value}
+	if ttl > 0 {
+		entry.expiresAt = time.Now().Add(ttl)
+	}
+	m.entries[key] = entry
+	return nil
+}
+
+func (m *MemoryStore) Get(_ context.Context, key string) (string, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.lookup(key)
+	return entry.value, ok, nil
+}
+
+func (m *MemoryStore) Delete(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.entries, key)
+	return nil
+}
+
+func (m *MemoryStore) Incr(_ context.Context, key string, ttl time.Duration) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.lookup(key)
+	if !ok {
+		entry = memoryEntry{value: "0"}
+		if ttl > 0 {
+			entry.expiresAt = time.Now().Add(ttl)
+		}
+	}
+
+	n, err := strconv.ParseInt(entry.value, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	n++
+	entry.value = strconv.FormatInt(n, 10)
+	m.entries[key] = entry
+	return n, nil
+}
+
+// lookup must be called with m.mu held. Expired entries are evicted lazily.
+func (m *MemoryStore) lookup(key string) (memoryEntry, bool) {
+	entry, ok := m.entries[key]
+	if !ok {
+		return memoryEntry{}, false
+	}
+	if !entry.expiresAt.IsZero() && !time.Now().Before(entry.expiresAt) {
+		delete(m.entries, key)
+		return memoryEntry{}, false
+	}
+	return entry, true
+}
+
+// GetInt64 is a convenience wrapper for values stored as decimal integers.
+func GetInt64(ctx context.Context, s Store, key string) (int64, bool, error) {
+	value, ok, err := s.Get(ctx, key)
+	if err != nil || !ok {
+		return 0, ok, err
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, false, err
+	}
+	return n, true, nil
+}
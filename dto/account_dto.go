@@ -0,0 +1,73 @@
+package dto
+
+import (
+	"backend101/models"
+	"backend101/money"
+	"time"
+)
+
+type CreateAccountInput struct {
+	Name string `json:"name" binding:"required,max=100" example:"Everyday checking"`
+	Type string `json:"type" binding:"required,oneof=checking savings cash credit_card" example:"checking"`
+	// ISO 4217 code; defaults to the user's preferred currency.
+	Currency *string `json:"currency" binding:"omitempty,iso4217" example:"USD"`
+	// May be negative, e.g. for a credit card with an outstanding balance.
+	OpeningBalance money.Decimal `json:"opening_balance" swaggertype:"string" example:"1000.00"`
+}
+
+// UpdateAccountInput only changes the fields that are present. The currency
+// cannot change once transactions may refer to it.
+type UpdateAccountInput struct {
+	Name           *string        `json:"name" binding:"omitempty,min=1,max=100"`
+	Type           *string        `json:"type" binding:"omitempty,oneof=checking savings cash credit_card"`
+	OpeningBalance *money.Decimal `json:"opening_balance" swaggertype:"string" example:"1000.00"`
+}
+
+type AccountResponse struct {
+	ID             uint        `json:"id"`
+	Name           string      `json:"name"`
+	Type           string      `json:"type"`
+	Currency       string      `json:"currency"`
+	OpeningBalance interface{} `json:"opening_balance" swaggertype:"string" example:"1000.00"`
+	ArchivedAt     *time.Time  `json:"archived_at"`
+	CreatedAt      time.Time   `json:"created_at"`
+	UpdatedAt      time.Time   `json:"updated_at"`
+}
+
+func NewAccountResponse(a models.Account, version int) AccountResponse {
+	return AccountResponse{
+		ID:             a.ID,
+		Name:           a.Name,
+		Type:           a.Type,
+		Currency:       a.Currency,
+		OpeningBalance: Amount(a.OpeningBalanceMinor, a.Currency, version),
+		ArchivedAt:     a.ArchivedAt,
+		CreatedAt:      a.CreatedAt,
+		UpdatedAt:      a.UpdatedAt,
+	}
+}
+
+func NewAccountResponses(accounts []models.Account, version int) []AccountResponse {
+	out := make([]AccountResponse, len(accounts))
+	for i, a := range accounts {
+		out[i] = NewAccountResponse(a, version)
+	}
+	return out
+}
+
+type AccountBalance struct {
+	AccountResponse
+	// Opening balance plus income minus expenses, in the account currency.
+	Balance interface{} `json:"balance" swaggertype:"string" example:"1250.00"`
+	// Balance converted to the report currency at the latest rate, or null
+	// if no rate is available.
+	ConvertedBalance interface{} `json:"converted_balance" swaggertype:"string" example:"1150.00"`
+}
+
+type AccountBalances struct {
+	Currency     string           `json:"currency" example:"EUR"`
+	Total        interface{}      `json:"total" swaggertype:"string" example:"5230.10"`
+	Accounts     []AccountBalance `json:"accounts"`
+	RateSource   string           `json:"rate_source" example:"ecb"`
+	MissingRates []string         `json:"missing_rates"`
+}
@@ -0,0 +1,121 @@
+package dto
+
+import (
+	"backend101/models"
+	"backend101/money"
+	"time"
+)
+
+type CreateBudgetInput struct {
+	// Defaults to the category's or tag's name.
+	Name *string `json:"name" binding:"omitempty,min=1,max=100" example:"Groceries"`
+	// Budget an expense category, including its subcategories, or a tag.
+	CategoryID *uint         `json:"category_id" binding:"required_without=TagID,excluded_with=TagID" example:"5"`
+	TagID      *uint         `json:"tag_id" binding:"required_without=CategoryID" example:"3"`
+	Period     string        `json:"period" binding:"required,oneof=weekly monthly yearly" example:"monthly"`
+	Amount     money.Decimal `json:"amount" binding:"required" swaggertype:"string" example:"400.00"`
+	// ISO 4217 code; defaults to the user's preferred currency.
+	Currency *string `json:"currency" binding:"omitempty,iso4217" example:"USD"`
+	// Add what is left at the end of a period to the next one.
+	Rollover bool `json:"rollover" example:"false"`
+	// YYYY-MM-DD; defaults to today. The budget starts with the period
+	// containing this date.
+	StartDate *string `json:"start_date" example:"2026-10-01"`
+	EndDate   *string `json:"end_date" example:"2027-09-30"`
+}
+
+// UpdateBudgetInput only changes the fields that are present. The category
+// or tag and the period cannot change, since rolled over amounts depend on
+// them.
+type UpdateBudgetInput struct {
+	Name     *string        `json:"name" binding:"omitempty,min=1,max=100" example:"Groceries"`
+	Amount   *money.Decimal `json:"amount" swaggertype:"string" example:"450.00"`
+	Rollover *bool          `json:"rollover" example:"true"`
+	// An empty string removes the end date.
+	EndDate *string `json:"end_date" example:"2027-09-30"`
+}
+
+type BudgetResponse struct {
+	ID         uint        `json:"id"`
+	Name       string      `json:"name" example:"Groceries"`
+	CategoryID *uint       `json:"category_id"`
+	TagID      *uint       `json:"tag_id"`
+	Period     string      `json:"period" example:"monthly"`
+	Amount     interface{} `json:"amount" swaggertype:"string" example:"400.00"`
+	Currency   string      `json:"currency" example:"USD"`
+	Rollover   bool        `json:"rollover"`
+	StartDate  string      `json:"start_date" example:"2026-10-01"`
+	EndDate    *string     `json:"end_date" example:"2027-09-30"`
+	CreatedAt  time.Time   `json:"created_at"`
+	UpdatedAt  time.Time   `json:"updated_at"`
+}
+
+func NewBudgetResponse(b models.Budget, version int) BudgetResponse {
+	return BudgetResponse{
+		ID:         b.ID,
+		Name:       b.Name,
+		CategoryID: b.CategoryID,
+		TagID:      b.TagID,
+		Period:     b.Period,
+		Amount:     Amount(b.AmountMinor, b.Currency, version),
+		Currency:   b.Currency,
+		Rollover:   b.Rollover,
+		StartDate:  b.StartDate.Format(dateLayout),
+		EndDate:    formatDate(b.EndDate),
+		CreatedAt:  b.CreatedAt,
+		UpdatedAt:  b.UpdatedAt,
+	}
+}
+
+func NewBudgetResponses(budgets []models.Budget, version int) []BudgetResponse {
+	out := make([]BudgetResponse, len(budgets))
+	for i, b := range budgets {
+		out[i] = NewBudgetResponse(b, version)
+	}
+	return out
+}
+
+type BudgetProgressQuery struct {
+	// YYYY-MM-DD; report the period containing this date instead of the
+	// current one.
+	Date string `form:"date"`
+}
+
+// Budget statuses.
+const (
+	BudgetOnTrack = "on_track"
+	BudgetAtRisk  = "at_risk"
+	BudgetOver    = "over"
+)
+
+// BudgetProgressResponse is a budget's spending in one period, in the
+// budget's currency.
+type BudgetProgressResponse struct {
+	BudgetID uint      `json:"budget_id"`
+	Name     string    `json:"name" example:"Groceries"`
+	Currency string    `json:"currency" example:"USD"`
+	From     time.Time `json:"from"`
+	To       time.Time `json:"to"`
+	// The budget's amount per period.
+	Amount interface{} `json:"amount" swaggertype:"string" example:"400.00"`
+	// Left over from earlier periods; always zero without rollover.
+	RolledOver interface{} `json:"rolled_over" swaggertype:"string" example:"35.20"`
+	// Amount plus rolled over.
+	Available interface{} `json:"available" swaggertype:"string" example:"435.20"`
+	Spent     interface{} `json:"spent" swaggertype:"string" example:"212.75"`
+	// Negative once the budget is overspent.
+	Remaining interface{} `json:"remaining" swaggertype:"string" example:"222.45"`
+	// Spending by the end of the period if it continues at the pace so far.
+	Projected   interface{} `json:"projected" swaggertype:"string" example:"425.50"`
+	PercentUsed float64     `json:"percent_used" example:"48.9"`
+	// on_track, at_risk (projected to overspend) or over.
+	Status        string      `json:"status" example:"on_track"`
+	ExchangeRates interface{} `json:"exchange_rates"`
+}
+
+type BudgetSummaryResponse struct {
+	Budgets []BudgetProgressResponse `json:"budgets"`
+	OnTrack int                      `json:"on_track"`
+	AtRisk  int                      `json:"at_risk"`
+	Over    int                      `json:"over"`
+}
@@ -0,0 +1,24 @@
+package dto
+
+type CreateCategoryInput struct {
+	Name string `json:"name" binding:"required,min=2,max=30" example:"Groceries"`
+	Type string `json:"type" binding:"required,oneof=income expense" example:"expense"`
+	// Optional top-level category of the same type to nest under.
+	ParentID *uint  `json:"parent_id" example:"3"`
+	Color    string `json:"color" binding:"omitempty,hexcolor,max=7" example:"#FF9800"`
+	Icon     string `json:"icon" binding:"max=50" example:"cart"`
+}
+
+// UpdateCategoryInput only changes the fields that are present. A parent_id
+// of 0 makes the category top-level. The type cannot change.
+type UpdateCategoryInput struct {
+	Name     *string `json:"name" binding:"omitempty,min=2,max=30"`
+	ParentID *uint   `json:"parent_id" example:"3"`
+	Color    *string `json:"color" binding:"omitempty,hexcolor,max=7" example:"#FF9800"`
+	Icon     *string `json:"icon" binding:"omitempty,max=50" example:"cart"`
+}
+
+type MergeCategoryInput struct {
+	// Category that takes over the transactions and children.
+	IntoID uint `json:"into_id" binding:"required" example:"7"`
+}
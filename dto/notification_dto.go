@@ -0,0 +1,65 @@
+package dto
+
+import (
+	"backend101/models"
+	"strings"
+	"time"
+)
+
+type NotificationQuery struct {
+	Unread bool `form:"unread"`
+	Page   int  `form:"page" binding:"omitempty,min=1"`
+	Limit  int  `form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+type NotificationList struct {
+	Page        int                   `json:"page"`
+	Limit       int                   `json:"limit"`
+	Total       int64                 `json:"total"`
+	UnreadCount int64                 `json:"unread_count"`
+	Data        []models.Notification `json:"data"`
+}
+
+// UpdateChannelInput only changes the fields that are present.
+type UpdateChannelInput struct {
+	Enabled *bool `json:"enabled" example:"true"`
+	// Kinds to send on the channel; an empty list sends all kinds.
+	Kinds []string `json:"kinds" binding:"omitempty,dive,oneof=budget_threshold negative_balance large_transaction upcoming_bill" example:"budget_threshold,negative_balance"`
+	// Webhook only. Must be https (http is allowed with ENV=development)
+	// and reach a public address.
+	URL *string `json:"url" binding:"omitempty,url,startswith=http,max=2048" example:"https://example.com/hooks/expenses"`
+}
+
+type ChannelResponse struct {
+	Channel string `json:"channel" example:"webhook"`
+	Enabled bool   `json:"enabled"`
+	// Kinds sent on the channel; empty means all kinds.
+	Kinds []string `json:"kinds" example:"budget_threshold"`
+	URL   string   `json:"url,omitempty" example:"https://example.com/hooks/expenses"`
+	// Key for verifying the X-Signature header of webhook requests.
+	Secret    string    `json:"secret,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func NewChannelResponse(p models.NotificationPreference) ChannelResponse {
+	kinds := []string{}
+	if p.Kinds != "" {
+		kinds = strings.Split(p.Kinds, ",")
+	}
+	return ChannelResponse{
+		Channel:   p.Channel,
+		Enabled:   p.Enabled,
+		Kinds:     kinds,
+		URL:       p.URL,
+		Secret:    p.Secret,
+		UpdatedAt: p.UpdatedAt,
+	}
+}
+
+func NewChannelResponses(prefs []models.NotificationPreference) []ChannelResponse {
+	out := make([]ChannelResponse, len(prefs))
+	for i, p := range prefs {
+		out[i] = NewChannelResponse(p)
+	}
+	return out
+}
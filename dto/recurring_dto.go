@@ -0,0 +1,183 @@
+package dto
+
+import (
+	"backend101/models"
+	"backend101/money"
+	"strings"
+	"time"
+)
+
+const dateLayout = "2006-01-02"
+
+type CreateRecurringInput struct {
+	Amount money.Decimal `json:"amount" binding:"required" swaggertype:"string" example:"1200.00"`
+	// ISO 4217 code; defaults to the account's, then the preferred currency.
+	Currency    *string `json:"currency" binding:"omitempty,iso4217" example:"USD"`
+	Type        string  `json:"type" binding:"required,oneof=income expense" example:"expense"`
+	CategoryID  *uint   `json:"category_id" example:"4"`
+	Category    string  `json:"category" binding:"required_without=CategoryID" example:"Rent"`
+	Description string  `json:"description" binding:"required" example:"Monthly rent"`
+	AccountID   *uint   `json:"account_id" example:"1"`
+
+	Frequency string `json:"frequency" binding:"required,oneof=daily weekly monthly yearly" example:"monthly"`
+	// Defaults to 1.
+	Interval int `json:"interval" binding:"omitempty,min=1,max=1000" example:"1"`
+	// Weekdays (MO..SU) for weekly rules; with an ordinal such as 1MO or -1FR
+	// for monthly rules.
+	ByDay []string `json:"by_day" binding:"omitempty,max=7" example:"MO,TH"`
+	// Day of the month for monthly rules; -1 is the last day.
+	ByMonthDay *int `json:"by_month_day" example:"1"`
+	// YYYY-MM-DD in the user's time zone. Past occurrences are backfilled.
+	StartDate string  `json:"start_date" binding:"required" example:"2026-11-01"`
+	Until     *string `json:"until" binding:"omitempty,excluded_with=Count" example:"2027-10-31"`
+	Count     *int    `json:"count" binding:"omitempty,min=1,max=10000" example:"12"`
+}
+
+// UpdateRecurringInput only changes the fields that are present. Changes
+// apply to occurrences that have not been created yet. Changing the
+// schedule discards changes made to single upcoming occurrences.
+type UpdateRecurringInput struct {
+	Amount      *money.Decimal `json:"amount" swaggertype:"string" example:"1250.00"`
+	CategoryID  *uint          `json:"category_id" example:"4"`
+	Category    *string        `json:"category" example:"Rent"`
+	Description *string        `json:"description" binding:"omitempty,min=2" example:"Monthly rent"`
+	// 0 removes the account.
+	AccountID *uint `json:"account_id" example:"1"`
+
+	Frequency  *string  `json:"frequency" binding:"omitempty,oneof=daily weekly monthly yearly" example:"monthly"`
+	Interval   *int     `json:"interval" binding:"omitempty,min=1,max=1000" example:"1"`
+	ByDay      []string `json:"by_day" binding:"omitempty,max=7" example:"MO,TH"`
+	ByMonthDay *int     `json:"by_month_day" example:"1"`
+	StartDate  *string  `json:"start_date" example:"2026-11-01"`
+	// An empty string removes the end date.
+	Until *string `json:"until" example:"2027-10-31"`
+	// 0 removes the count.
+	Count *int `json:"count" binding:"omitempty,min=0,max=10000" example:"12"`
+}
+
+// UpdateOccurrenceInput skips one occurrence or changes its amount or
+// description.
+type UpdateOccurrenceInput struct {
+	Skip        *bool          `json:"skip" example:"true"`
+	Amount      *money.Decimal `json:"amount" swaggertype:"string" example:"1300.00"`
+	Description *string        `json:"description" binding:"omitempty,min=2"`
+}
+
+type OccurrenceQuery struct {
+	// Defaults to 10.
+	Limit int `form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+type RecurringResponse struct {
+	ID          uint        `json:"id"`
+	Amount      interface{} `json:"amount" swaggertype:"string" example:"1200.00"`
+	Currency    string      `json:"currency" example:"USD"`
+	Type        string      `json:"type"`
+	CategoryID  *uint       `json:"category_id"`
+	Category    string      `json:"category"`
+	Description string      `json:"description"`
+	AccountID   *uint       `json:"account_id"`
+	Frequency   string      `json:"frequency"`
+	Interval    int         `json:"interval"`
+	ByDay       []string    `json:"by_day"`
+	ByMonthDay  *int        `json:"by_month_day"`
+	StartDate   string      `json:"start_date" example:"2026-11-01"`
+	Until       *string     `json:"until" example:"2027-10-31"`
+	Count       *int        `json:"count"`
+	// Next occurrence to be created; null once the rule has ended.
+	NextDate  *string   `json:"next_date" example:"2026-11-01"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func formatDate(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+	s := t.Format(dateLayout)
+	return &s
+}
+
+func NewRecurringResponse(r models.RecurringRule, version int) RecurringResponse {
+	byDay := []string{}
+	if r.ByDay != "" {
+		byDay = strings.Split(r.ByDay, ",")
+	}
+	return RecurringResponse{
+		ID:          r.ID,
+		Amount:      Amount(r.AmountMinor, r.Currency, version),
+		Currency:    r.Currency,
+		Type:        r.Type,
+		CategoryID:  r.CategoryID,
+		Category:    r.Category,
+		Description: r.Description,
+		AccountID:   r.AccountID,
+		Frequency:   r.Frequency,
+		Interval:    r.Interval,
+		ByDay:       byDay,
+		ByMonthDay:  r.ByMonthDay,
+		StartDate:   r.StartDate.Format(dateLayout),
+		Until:       formatDate(r.Until),
+		Count:       r.Count,
+		NextDate:    formatDate(r.NextDate),
+		CreatedAt:   r.CreatedAt,
+		UpdatedAt:   r.UpdatedAt,
+	}
+}
+
+func NewRecurringResponses(rules []models.RecurringRule, version int) []RecurringResponse {
+	out := make([]RecurringResponse, len(rules))
+	for i, r := range rules {
+		out[i] = NewRecurringResponse(r, version)
+	}
+	return out
+}
+
+// Occurrence statuses.
+const (
+	OccurrenceScheduled = "scheduled"
+	OccurrenceModified  = "modified"
+	OccurrenceSkipped   = "skipped"
+	OccurrenceCreated   = "created"
+)
+
+type OccurrenceResponse struct {
+	Date string `json:"date" example:"2026-12-01"`
+	// scheduled, modified, skipped or created.
+	Status        string      `json:"status" example:"scheduled"`
+	Amount        interface{} `json:"amount" swaggertype:"string" example:"1200.00"`
+	Currency      string      `json:"currency" example:"USD"`
+	Description   string      `json:"description"`
+	TransactionID *uint       `json:"transaction_id"`
+}
+
+// NewOccurrenceResponse describes the occurrence of rule on date, applying
+// the overrides in o if there are any.
+func NewOccurrenceResponse(rule models.RecurringRule, date time.Time, o *models.RecurringOccurrence, version int) OccurrenceResponse {
+	r := OccurrenceResponse{
+		Date:        date.Format(dateLayout),
+		Status:      OccurrenceScheduled,
+		Currency:    rule.Currency,
+		Description: rule.Description,
+	}
+	amount := rule.AmountMinor
+	if o != nil {
+		if o.AmountMinor != nil {
+			amount = *o.AmountMinor
+		}
+		if o.Description != nil {
+			r.Description = *o.Description
+		}
+		switch {
+		case o.TransactionID != nil:
+			r.Status = OccurrenceCreated
+			r.TransactionID = o.TransactionID
+		case o.Skipped:
+			r.Status = OccurrenceSkipped
+		case o.AmountMinor != nil || o.Description != nil:
+			r.Status = OccurrenceModified
+		}
+	}
+	r.Amount = Amount(amount, rule.Currency, version)
+	return r
+}
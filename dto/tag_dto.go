@@ -0,0 +1,13 @@
+package dto
+
+type TagInput struct {
+	Name string `json:"name" binding:"required,max=50" example:"vacation-2026"`
+}
+
+// TagTotal is the income and expense of one tag's transactions.
+type TagTotal struct {
+	ID           uint        `json:"id"`
+	Name         string      `json:"name" example:"vacation-2026"`
+	IncomeTotal  interface{} `json:"income_total" swaggertype:"string" example:"0.00"`
+	ExpenseTotal interface{} `json:"expense_total" swaggertype:"string" example:"1840.25"`
+}
@@ -1,16 +1,142 @@
 package dto
 
+import (
+	"backend101/models"
+	"backend101/money"
+	"time"
+)
+
 type CreateTransactionInput struct {
-	Amount      float64 `json:"amount" binding:"required"`
-	Type        string  `json:"type" binding:"required,oneof=income expense"`
-	Category    string  `json:"category" binding:"required"`
-	Description string  `json:"description"`
+	// A JSON number or a decimal string; strings avoid float rounding.
+	Amount money.Decimal `json:"amount" binding:"required" swaggertype:"string" example:"150.50"`
+	// ISO 4217 code; defaults to the user's preferred currency.
+	Currency   *string `json:"currency" binding:"omitempty,iso4217" example:"USD"`
+	Type       string  `json:"type" binding:"required,oneof=income expense"`
+	CategoryID *uint   `json:"category_id" example:"4"`
+	// Category name, used when category_id is not given. Unknown names
+	// create a new category.
+	Category    string `json:"category" binding:"required_without=CategoryID"`
+	Description string `json:"description"`
+	// RFC 3339 or YYYY-MM-DD in the user's time zone; defaults to now.
+	Date *string `json:"date" example:"2025-05-17"`
+	// Optional; when set, currency defaults to the account's currency.
+	AccountID *uint `json:"account_id" example:"1"`
+	// Tag names; unknown tags are created.
+	Tags []string `json:"tags" binding:"omitempty,max=20,dive,max=50" example:"vacation-2026,reimbursable"`
 }
 
 type UpdateTransactionInput struct {
-	Amount      float64 `json:"amount" binding:"required"`
-	Type        string  `json:"type" binding:"required,oneof=income expense"`
-	Category    string  `json:"category" binding:"required"`
-	Description string  `json:"description"`
-	// Date        string  `json:"date"` // Optional if allowing custom dates
+	Amount money.Decimal `json:"amount" binding:"required" swaggertype:"string" example:"150.50"`
+	// Optional; the existing currency is kept when omitted.
+	Currency   *string `json:"currency" binding:"omitempty,iso4217" example:"USD"`
+	Type       string  `json:"type" binding:"required,oneof=income expense"`
+	CategoryID *uint   `json:"category_id" example:"4"`
+	// Category name, used when category_id is not given. Unknown names
+	// create a new category.
+	Category    string `json:"category" binding:"required_without=CategoryID"`
+	Description string `json:"description"`
+	// Optional; the existing date is kept when omitted.
+	Date *string `json:"date" example:"2025-05-17"`
+	// Optional; the existing account is kept when omitted and 0 removes it.
+	AccountID *uint `json:"account_id" example:"1"`
+	// Optional; the existing tags are kept when omitted and [] removes them.
+	Tags []string `json:"tags" binding:"omitempty,max=20,dive,max=50" example:"vacation-2026,reimbursable"`
+}
+
+// PatchTransactionInput only changes the fields that are present.
+type PatchTransactionInput struct {
+	Amount      *money.Decimal `json:"amount" swaggertype:"string" example:"150.50"`
+	Currency    *string        `json:"currency" binding:"omitempty,iso4217" example:"USD"`
+	Type        *string        `json:"type" binding:"omitempty,oneof=income expense"`
+	CategoryID  *uint          `json:"category_id" example:"4"`
+	Category    *string        `json:"category"`
+	Description *string        `json:"description"`
+	Date        *string        `json:"date" example:"2025-05-17"`
+	// 0 removes the transaction from its account.
+	AccountID *uint `json:"account_id" example:"1"`
+	// Replaces all tags when present; [] removes them.
+	Tags []string `json:"tags" binding:"omitempty,max=20,dive,max=50" example:"vacation-2026,reimbursable"`
+}
+
+// TransactionQuery holds the query parameters of GET /transactions.
+type TransactionQuery struct {
+	Type     string `form:"type" binding:"omitempty,oneof=income expense transfer_in transfer_out"`
+	Category string `form:"category"`
+	// Transactions in this category or its children.
+	CategoryID uint   `form:"category_id"`
+	Currency   string `form:"currency" binding:"omitempty,iso4217"`
+	AccountID  uint   `form:"account_id"`
+	// Repeat tag for several tags; TagMode any (default) or all.
+	Tags    []string `form:"tag" binding:"max=20"`
+	TagMode string   `form:"tag_mode" binding:"omitempty,oneof=any all"`
+	// Decimal amounts in Currency, or the user's preferred currency.
+	MinAmount string `form:"min_amount"`
+	MaxAmount string `form:"max_amount"`
+	From      string `form:"from"`
+	To        string `form:"to"`
+	Q         string `form:"q" binding:"max=100"`
+	Sort      string `form:"sort" binding:"omitempty,oneof=date -date amount -amount"`
+	Cursor    string `form:"cursor"`
+	Limit     int    `form:"limit" binding:"omitempty,min=1,max=200"`
+}
+
+// TransactionResponse is the JSON form of a transaction. Amount is a JSON
+// number in API version 1 and a decimal string from version 2 on.
+type TransactionResponse struct {
+	ID         uint  `json:"id"`
+	AccountID  *uint `json:"account_id"`
+	TransferID *uint `json:"transfer_id"`
+	// Rule that created the transaction, if any.
+	RecurringRuleID *uint       `json:"recurring_rule_id"`
+	Amount          interface{} `json:"amount" swaggertype:"string" example:"150.50"`
+	Currency        string      `json:"currency" example:"USD"`
+	CategoryID      *uint       `json:"category_id"`
+	Category        string      `json:"category"`
+	Description     string      `json:"description"`
+	Type            string      `json:"type"`
+	Tags            []string    `json:"tags" example:"vacation-2026"`
+	Date            time.Time   `json:"date"`
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+func NewTransactionResponse(tx models.Transaction, version int) TransactionResponse {
+	return TransactionResponse{
+		ID:              tx.ID,
+		AccountID:       tx.AccountID,
+		TransferID:      tx.TransferID,
+		RecurringRuleID: tx.RecurringRuleID,
+		Amount:          Amount(tx.AmountMinor, tx.Currency, version),
+		Currency:        tx.Currency,
+		CategoryID:      tx.CategoryID,
+		Category:        tx.Category,
+		Description:     tx.Description,
+		Type:            tx.Type,
+		Tags:            tagNames(tx.Tags),
+		Date:            tx.Date,
+		CreatedAt:       tx.CreatedAt,
+		UpdatedAt:       tx.UpdatedAt,
+	}
+}
+
+func tagNames(tags []models.Tag) []string {
+	names := make([]string, len(tags))
+	for i, t := range tags {
+		names[i] = t.Name
+	}
+	return names
+}
+
+func NewTransactionResponses(txs []models.Transaction, version int) []TransactionResponse {
+	out := make([]TransactionResponse, len(txs))
+	for i, tx := range txs {
+		out[i] = NewTransactionResponse(tx, version)
+	}
+	return out
+}
+
+type TransactionPage struct {
+	Data       []TransactionResponse `json:"data"`
+	NextCursor *string               `json:"next_cursor"`
+	Total      int64                 `json:"total"`
 }
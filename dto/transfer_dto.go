@@ -0,0 +1,92 @@
+package dto
+
+import (
+	"backend101/models"
+	"backend101/money"
+	"time"
+)
+
+// CreateTransferInput moves Amount, in the source account's currency, to
+// another account. Between currencies either Rate or ToAmount is required.
+type CreateTransferInput struct {
+	FromAccountID uint          `json:"from_account_id" binding:"required" example:"1"`
+	ToAccountID   uint          `json:"to_account_id" binding:"required" example:"2"`
+	Amount        money.Decimal `json:"amount" binding:"required" swaggertype:"string" example:"500.00"`
+	// Units of the destination currency per unit of the source currency.
+	Rate *money.Decimal `json:"rate" swaggertype:"string" example:"0.9127"`
+	// Amount received, in the destination account's currency.
+	ToAmount    *money.Decimal `json:"to_amount" swaggertype:"string" example:"456.35"`
+	Description string         `json:"description" binding:"max=255"`
+	// RFC 3339 or YYYY-MM-DD in the user's time zone; defaults to now.
+	Date *string `json:"date" example:"2025-05-17"`
+}
+
+// UpdateTransferInput only changes the fields that are present. When only
+// Amount changes between currencies, the existing rate is kept.
+type UpdateTransferInput struct {
+	Amount      *money.Decimal `json:"amount" swaggertype:"string" example:"500.00"`
+	Rate        *money.Decimal `json:"rate" swaggertype:"string" example:"0.9127"`
+	ToAmount    *money.Decimal `json:"to_amount" swaggertype:"string" example:"456.35"`
+	Description *string        `json:"description" binding:"omitempty,max=255"`
+	Date        *string        `json:"date" example:"2025-05-17"`
+}
+
+type TransferResponse struct {
+	ID            uint        `json:"id"`
+	FromAccountID uint        `json:"from_account_id"`
+	ToAccountID   uint        `json:"to_account_id"`
+	Amount        interface{} `json:"amount" swaggertype:"string" example:"500.00"`
+	Currency      string      `json:"currency" example:"USD"`
+	ToAmount      interface{} `json:"to_amount" swaggertype:"string" example:"456.35"`
+	ToCurrency    string      `json:"to_currency" example:"EUR"`
+	Rate          string      `json:"rate" example:"0.9127"`
+	Description   string      `json:"description"`
+	Date          time.Time   `json:"date"`
+	// IDs of the transfer_out and transfer_in transactions.
+	OutTransactionID uint      `json:"out_transaction_id"`
+	InTransactionID  uint      `json:"in_transaction_id"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// NewTransferResponse expects the transfer's legs to be loaded.
+func NewTransferResponse(t models.Transfer, version int) TransferResponse {
+	out, in := t.Leg(models.TransactionTransferOut), t.Leg(models.TransactionTransferIn)
+	r := TransferResponse{
+		ID:            t.ID,
+		FromAccountID: t.FromAccountID,
+		ToAccountID:   t.ToAccountID,
+		Rate:          t.Rate,
+		Description:   t.Description,
+		Date:          t.Date,
+		CreatedAt:     t.CreatedAt,
+		UpdatedAt:     t.UpdatedAt,
+	}
+	if out != nil {
+		r.Amount = Amount(out.AmountMinor, out.Currency, version)
+		r.Currency = out.Currency
+		r.OutTransactionID = out.ID
+	}
+	if in != nil {
+		r.ToAmount = Amount(in.AmountMinor, in.Currency, version)
+		r.ToCurrency = in.Currency
+		r.InTransactionID = in.ID
+	}
+	return r
+}
+
+func NewTransferResponses(transfers []models.Transfer, version int) []TransferResponse {
+	out := make([]TransferResponse, len(transfers))
+	for i, t := range transfers {
+		out[i] = NewTransferResponse(t, version)
+	}
+	return out
+}
+
+// TransferQuery holds the query parameters of GET /transfers.
+type TransferQuery struct {
+	// Transfers from or to this account.
+	AccountID uint   `form:"account_id"`
+	From      string `form:"from"`
+	To        string `form:"to"`
+}
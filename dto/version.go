@@ -0,0 +1,27 @@
+package dto
+
+import (
+	"backend101/money"
+	"encoding/json"
+)
+
+// API versions, selected with the X-API-Version request header.
+const (
+	// APIVersion1 is the default. Amounts are JSON numbers with the
+	// currency's fixed number of decimals, e.g. 150.50.
+	APIVersion1 = 1
+	// APIVersion2 serializes amounts as decimal strings, e.g. "150.50".
+	APIVersion2 = 2
+
+	LatestAPIVersion = APIVersion2
+)
+
+// Amount renders minor units for the given API version. Both forms are
+// exact; version 1 only differs in being an unquoted JSON number.
+func Amount(minor int64, currency string, version int) interface{} {
+	s := money.Format(minor, currency)
+	if version < APIVersion2 {
+		return json.Number(s)
+	}
+	return s
+}
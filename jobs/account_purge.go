@@ -0,0 +1,47 @@
+// Package jobs contains background work that runs inside the API process.
+package jobs
+
+import (
+	"backend101/config"
+	"backend101/services"
+	"context"
+	"log"
+	"time"
+)
+
+// StartAccountPurge erases accounts whose deletion grace period has ended,
+// once at startup and then every ACCOUNT_PURGE_INTERVAL_MINUTES, until ctx is
+// cancelled.
+func StartAccountPurge(ctx context.Context) {
+	interval := time.Minute * time.Duration(config.GetInt("ACCOUNT_PURGE_INTERVAL_MINUTES"))
+	if interval <= 0 {
+		log.Println("⚠️  Account purge is disabled")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			purgeAccounts()
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func purgeAccounts() {
+	purged, err := services.PurgeDueAccounts()
+	if err != nil {
+		log.Printf("❌ Account purge failed: %v", err)
+		return
+	}
+	if purged > 0 {
+		log.Printf("🗑️ Purged %d deleted account(s)", purged)
+	}
+}
@@ -0,0 +1,46 @@
+package jobs
+
+import (
+	"backend101/config"
+	"backend101/services"
+	"context"
+	"log"
+	"time"
+)
+
+// StartBillReminders notifies users of recurring expenses coming due, at
+// startup and then every BILL_REMINDER_INTERVAL_MINUTES, until ctx is
+// cancelled.
+func StartBillReminders(ctx context.Context) {
+	interval := time.Minute * time.Duration(config.GetInt("BILL_REMINDER_INTERVAL_MINUTES"))
+	if interval <= 0 {
+		log.Println("⚠️  Bill reminders are disabled")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			remindOfBills()
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func remindOfBills() {
+	reminded, err := services.NotifyUpcomingBills()
+	if err != nil {
+		log.Printf("❌ Bill reminders failed: %v", err)
+		return
+	}
+	if reminded > 0 {
+		log.Printf("🔔 Sent %d bill reminder(s)", reminded)
+	}
+}
@@ -0,0 +1,57 @@
+package jobs
+
+import (
+	"backend101/config"
+	"backend101/services"
+	"context"
+	"log"
+	"time"
+)
+
+// StartExchangeRateImport imports rates from FX_RATES_URL (a URL or file in
+// FX_RATES_FORMAT) at startup and then every FX_IMPORT_INTERVAL_HOURS. It
+// does nothing if FX_RATES_URL is not set.
+func StartExchangeRateImport(ctx context.Context) {
+	location := config.Get("FX_RATES_URL")
+	if location == "" {
+		return
+	}
+
+	provider, err := services.NewRateProvider(config.Get("FX_RATES_FORMAT"), config.Get("FX_RATE_SOURCE"), services.OpenRateLocation(location))
+	if err != nil {
+		log.Printf("❌ Exchange rate import disabled: %v", err)
+		return
+	}
+
+	interval := time.Hour * time.Duration(config.GetInt("FX_IMPORT_INTERVAL_HOURS"))
+	if interval <= 0 {
+		log.Println("⚠️  Exchange rate import interval is not positive, importing once")
+	}
+
+	go func() {
+		for {
+			importRates(ctx, provider)
+
+			if interval <= 0 {
+				return
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(interval):
+			}
+		}
+	}()
+}
+
+func importRates(ctx context.Context, provider services.RateProvider) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+
+	imported, err := services.ImportRates(ctx, provider)
+	if err != nil {
+		log.Printf("❌ Exchange rate import from %s failed: %v", provider.Name(), err)
+		return
+	}
+	log.Printf("💱 Imported %d exchange rates from %s", imported, provider.Name())
+}
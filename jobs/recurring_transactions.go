@@ -0,0 +1,47 @@
+package jobs
+
+import (
+	"backend101/config"
+	"backend101/services"
+	"context"
+	"log"
+	"time"
+)
+
+// StartRecurringTransactions creates the transactions of recurring rules
+// that have come due, at startup and then every RECURRING_INTERVAL_MINUTES,
+// until ctx is cancelled. Occurrences missed while the API was down are
+// created on the first run.
+func StartRecurringTransactions(ctx context.Context) {
+	interval := time.Minute * time.Duration(config.GetInt("RECURRING_INTERVAL_MINUTES"))
+	if interval <= 0 {
+		log.Println("⚠️  Recurring transactions are disabled")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			createRecurringTransactions()
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func createRecurringTransactions() {
+	created, err := services.MaterializeDueOccurrences()
+	if err != nil {
+		log.Printf("❌ Recurring transactions failed: %v", err)
+		return
+	}
+	if created > 0 {
+		log.Printf("🔁 Created %d recurring transaction(s)", created)
+	}
+}
@@ -0,0 +1,39 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync/atomic"
+	"time"
+)
+
+var unsafeFileChars = regexp.MustCompile(`[^a-zA-Z0-9@._-]`)
+
+// FileMailer stores every message as an .eml file in Dir so that local setups
+// and tests can inspect what would have been sent.
+type FileMailer struct {
+	Dir string
+	seq atomic.Uint64
+}
+
+func NewFileMailer(dir string) (*FileMailer, error) {
+	if dir == "" {
+		dir = "tmp/mail"
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileMailer{Dir: dir}, nil
+}
+
+func (m *FileMailer) Send(_ context.Context, msg Message) error {
+	name := fmt.Sprintf("%s-%04d-%s.eml",
+		time.Now().UTC().Format("20060102T150405.000000000"),
+		m.seq.Add(1)%10000,
+		unsafeFileChars.ReplaceAllString(msg.To, "_"),
+	)
+	return os.WriteFile(filepath.Join(m.Dir, name), buildMessage("", msg), 0o600)
+}
@@ -0,0 +1,66 @@
+package mailer
+
+import (
+	"backend101/config"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers plain-text emails. Implementations must be safe for
+// concurrent use.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Default is the mailer used by the application. It logs messages until
+// Setup selects a driver from MAIL_DRIVER.
+var Default Mailer = LogMailer{}
+
+func Setup() error {
+	driver := strings.ToLower(config.Get("MAIL_DRIVER"))
+
+	switch driver {
+	case "", "log":
+		Default = LogMailer{}
+	case "file":
+		m, err := NewFileMailer(config.Get("MAIL_FILE_DIR"))
+		if err != nil {
+			return err
+		}
+		Default = m
+	case "smtp":
+		Default = NewSMTPMailer(
+			config.Get("SMTP_HOST"),
+			config.Get("SMTP_PORT"),
+			config.Get("SMTP_USERNAME"),
+			config.Get("SMTP_PASSWORD"),
+			config.Get("MAIL_FROM"),
+		)
+	default:
+		return fmt.Errorf("unsupported MAIL_DRIVER %q", driver)
+	}
+
+	log.Printf("📧 Mail driver: %s", driver)
+	return nil
+}
+
+func Send(ctx context.Context, msg Message) error {
+	return Default.Send(ctx, msg)
+}
+
+// LogMailer writes messages to the application log. Meant for local
+// development only since the body may contain secrets such as reset links.
+type LogMailer struct{}
+
+func (LogMailer) Send(_ context.Context, msg Message) error {
+	log.Printf("📧 To: %s\nSubject: %s\n\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
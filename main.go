@@ -4,8 +4,15 @@ import (
 	"backend101/config"
 	"backend101/database"
 	"backend101/docs"
+	"backend101/jobs"
+	"backend101/mailer"
+	"backend101/middleware"
 	"backend101/routes"
+	"backend101/services"
+	"context"
 	"log"
+	// Time zone data for user preferences, independent of the host.
+	_ "time/tzdata"
 
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
@@ -15,8 +22,25 @@ import (
 func main() {
 	config.LoadConfig()
 	database.ConnectPostgres()
+	database.ConnectRedis()
+
+	if err := services.LoadSigningKeys(); err != nil {
+		log.Fatal("❌ Failed to load JWT signing keys: ", err)
+	}
+	if err := mailer.Setup(); err != nil {
+		log.Fatal("❌ Failed to set up mailer: ", err)
+	}
+	if err := services.BootstrapAdmins(); err != nil {
+		log.Fatal("❌ Failed to bootstrap admins: ", err)
+	}
+
+	jobs.StartAccountPurge(context.Background())
+	jobs.StartExchangeRateImport(context.Background())
+	jobs.StartRecurringTransactions(context.Background())
+	jobs.StartBillReminders(context.Background())
 
 	r := gin.Default()
+	r.Use(middleware.APIVersion())
 
 	//Swagger info
 	docs.SwaggerInfo.Title = "Expense Tracker APIs"
@@ -25,9 +49,19 @@ func main() {
 	docs.SwaggerInfo.Host = "localhost:8080"
 	docs.SwaggerInfo.BasePath = "/api"
 
+	routes.WellKnownRoutes(r)
 	routes.AuthRoutes(r)
 	routes.UserRoutes(r)
 	routes.TransactionRoutes(r)
+	routes.AccountRoutes(r)
+	routes.TransferRoutes(r)
+	routes.CategoryRoutes(r)
+	routes.TagRoutes(r)
+	routes.RecurringRoutes(r)
+	routes.BudgetRoutes(r)
+	routes.NotificationRoutes(r)
+	routes.ExchangeRateRoutes(r)
+	routes.AdminRoutes(r)
 
 	// Swagger Docs Route
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"backend101/dto"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIVersion reads the X-API-Version header into "apiVersion" (default 1)
+// and echoes the version used in the response.
+func APIVersion() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		version := dto.APIVersion1
+		if header := c.GetHeader("X-API-Version"); header != "" {
+			v, err := strconv.Atoi(header)
+			if err != nil || v < dto.APIVersion1 || v > dto.LatestAPIVersion {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+					"error": fmt.Sprintf("Unsupported API version; use 1 to %d", dto.LatestAPIVersion),
+				})
+				return
+			}
+			version = v
+		}
+
+		c.Set("apiVersion", version)
+		c.Header("X-API-Version", strconv.Itoa(version))
+		c.Next()
+	}
+}
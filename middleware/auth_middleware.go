@@ -1,56 +1,146 @@
 package middleware
 
 import (
-	"backend101/config"
+	"backend101/models"
+	"backend101/services"
+	"errors"
+	"log"
 	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 )
 
+const (
+	AuthMethodJWT    = "jwt"
+	AuthMethodAPIKey = "api_key"
+)
+
+// JWTMiddleware only accepts Bearer access tokens. Use it for account
+// management routes that API keys must not reach.
 func JWTMiddleware() gin.HandlerFunc {
+	return authenticate(false)
+}
+
+// AuthMiddleware accepts either a Bearer access token or an API key, sent as
+// "X-API-Key: <key>" or "Authorization: Bearer <key>". Combine it with
+// RequireScope to restrict what API keys may do.
+func AuthMiddleware() gin.HandlerFunc {
+	return authenticate(true)
+}
+
+func authenticate(allowAPIKeys bool) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
-			c.Abort()
-			return
+		credential := c.GetHeader("X-API-Key")
+		if credential == "" || !allowAPIKeys {
+			authHeader := c.GetHeader("Authorization")
+			if authHeader == "" {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
+				c.Abort()
+				return
+			}
+
+			parts := strings.Split(authHeader, " ")
+			if len(parts) != 2 || strings.ToLower((parts[0])) != "bearer" {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header format must be Bearer {token}"})
+				c.Abort()
+				return
+			}
+			credential = parts[1]
 		}
 
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || strings.ToLower((parts[0])) != "bearer" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header format must be Bearer {token}"})
-			c.Abort()
+		if services.IsAPIKey(credential) {
+			if !allowAPIKeys {
+				c.JSON(http.StatusForbidden, gin.H{"error": "API keys cannot be used for this endpoint"})
+				c.Abort()
+				return
+			}
+			authenticateAPIKey(c, credential)
 			return
 		}
 
-		tokenStr := parts[1]
-		secret := config.Get("JWT_SECRET")
+		authenticateJWT(c, credential)
+	}
+}
 
-		token, err := jwt.Parse(tokenStr, func(t *jwt.Token) (interface{}, error) {
-			if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, jwt.ErrSignatureInvalid
-			}
-			return []byte(secret), nil
-		})
+func authenticateJWT(c *gin.Context, tokenStr string) {
+	claims, err := services.ParseJWT(tokenStr)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+		c.Abort()
+		return
+	}
 
-		if err != nil || !token.Valid {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
-			c.Abort()
+	revoked, err := services.IsTokenRevoked(c.Request.Context(), claims)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify token"})
+		c.Abort()
+		return
+	}
+	if revoked {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked"})
+		c.Abort()
+		return
+	}
+
+	role := claims.Role
+	if role == "" {
+		role = models.RoleUser
+	}
+
+	c.Set("userID", claims.UserID)
+	c.Set("role", role)
+	c.Set("authMethod", AuthMethodJWT)
+	c.Set("tokenID", claims.ID)
+	c.Set("tokenExpiresAt", claims.ExpiresAt.Time)
+	c.Set("sessionID", claims.SessionID)
+
+	if claims.SessionID != "" {
+		if err := services.TouchSession(c.Request.Context(), claims.SessionID); err != nil {
+			log.Printf("❌ Failed to update session %s: %v", claims.SessionID, err)
+		}
+	}
+
+	c.Next()
+}
+
+func authenticateAPIKey(c *gin.Context, raw string) {
+	key, user, err := services.AuthenticateAPIKey(c.Request.Context(), raw)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidAPIKey) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or revoked API key"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify API key"})
+		}
+		c.Abort()
+		return
+	}
+
+	c.Set("userID", key.UserID)
+	c.Set("role", user.Role)
+	c.Set("authMethod", AuthMethodAPIKey)
+	c.Set("apiKeyID", key.ID)
+	c.Set("scopes", key.Scopes)
+
+	c.Next()
+}
+
+// RequireScope rejects API keys that were not granted scope. Access tokens
+// act on behalf of the user and carry every scope.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("authMethod") != AuthMethodAPIKey {
+			c.Next()
 			return
 		}
 
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
+		scopes, _ := c.Get("scopes")
+		if granted, ok := scopes.(models.Scopes); !ok || !granted.Has(scope) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "API key is missing the " + scope + " scope"})
 			c.Abort()
 			return
 		}
 
-		userID := uint(claims["user_id"].(float64))
-		c.Set("userID", userID)
-
 		c.Next()
 	}
 }
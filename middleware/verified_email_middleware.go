@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"backend101/config"
+	"backend101/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireVerifiedEmail blocks the request until the user has verified their
+// email address. It is a no-op unless REQUIRE_EMAIL_VERIFICATION is enabled.
+// Must run after JWTMiddleware or AuthMiddleware.
+func RequireVerifiedEmail() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !config.GetBool("REQUIRE_EMAIL_VERIFICATION") {
+			c.Next()
+			return
+		}
+
+		userID := c.MustGet("userID").(uint)
+		verified, err := services.IsEmailVerified(userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load user"})
+			c.Abort()
+			return
+		}
+		if !verified {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Email address must be verified"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
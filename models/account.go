@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+const (
+	AccountChecking   = "checking"
+	AccountSavings    = "savings"
+	AccountCash       = "cash"
+	AccountCreditCard = "credit_card"
+)
+
+// Account is a wallet that transactions can be booked against. Archived
+// accounts are hidden from listings but keep their transactions.
+type Account struct {
+	ID       uint   `gorm:"primaryKey" json:"id"`
+	UserID   uint   `gorm:"index;not null" json:"-"`
+	Name     string `gorm:"not null" json:"name"`
+	Type     string `gorm:"not null" json:"type"`
+	Currency string `gorm:"size:3;not null" json:"currency"`
+	// Balance before the first transaction, in minor units of Currency.
+	OpeningBalanceMinor int64      `gorm:"not null;default:0" json:"opening_balance_minor"`
+	ArchivedAt          *time.Time `json:"archived_at"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+}
@@ -0,0 +1,56 @@
+package models
+
+type AdminUserQuery struct {
+	Q      string `form:"q"`
+	Role   string `form:"role" binding:"omitempty,oneof=user admin auditor"`
+	Status string `form:"status" binding:"omitempty,oneof=active disabled"`
+	Page   int    `form:"page" binding:"omitempty,min=1"`
+	Limit  int    `form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+type AdminUserList struct {
+	Data  []User `json:"data"`
+	Total int64  `json:"total"`
+	Page  int    `json:"page"`
+	Limit int    `json:"limit"`
+}
+
+type UpdateRoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=user admin auditor" example:"auditor"`
+}
+
+// Amounts in the stats are decimal strings in the row's currency; totals in
+// different currencies are never added together.
+
+type CurrencyTotal struct {
+	Currency     string `json:"currency" example:"USD"`
+	IncomeTotal  string `json:"income_total" example:"1200.00"`
+	ExpenseTotal string `json:"expense_total" example:"950.00"`
+}
+
+type CategoryStat struct {
+	Type     string `json:"type"`
+	Category string `json:"category"`
+	Currency string `json:"currency"`
+	Count    int64  `json:"count"`
+	Total    string `json:"total"`
+}
+
+type MonthStat struct {
+	Month        string `json:"month" example:"2025-05"`
+	Currency     string `json:"currency"`
+	Count        int64  `json:"count"`
+	IncomeTotal  string `json:"income_total"`
+	ExpenseTotal string `json:"expense_total"`
+}
+
+// TransactionStats are system-wide aggregates. They never include
+// descriptions or anything that identifies individual users.
+type TransactionStats struct {
+	UserCount        int64           `json:"user_count"`
+	ActiveUserCount  int64           `json:"active_user_count"`
+	TransactionCount int64           `json:"transaction_count"`
+	Totals           []CurrencyTotal `json:"totals"`
+	ByCategory       []CategoryStat  `json:"by_category"`
+	ByMonth          []MonthStat     `json:"by_month"`
+}
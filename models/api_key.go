@@ -0,0 +1,100 @@
+package models
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	ScopeTransactionsRead   = "transactions:read"
+	ScopeTransactionsWrite  = "transactions:write"
+	ScopeAccountsRead       = "accounts:read"
+	ScopeAccountsWrite      = "accounts:write"
+	ScopeCategoriesRead     = "categories:read"
+	ScopeCategoriesWrite    = "categories:write"
+	ScopeTagsRead           = "tags:read"
+	ScopeTagsWrite          = "tags:write"
+	ScopeRecurringRead      = "recurring:read"
+	ScopeRecurringWrite     = "recurring:write"
+	ScopeBudgetsRead        = "budgets:read"
+	ScopeBudgetsWrite       = "budgets:write"
+	ScopeNotificationsRead  = "notifications:read"
+	ScopeNotificationsWrite = "notifications:write"
+)
+
+// APIKeyScopes lists every scope an API key can be granted.
+var APIKeyScopes = []string{
+	ScopeTransactionsRead,
+	ScopeTransactionsWrite,
+	ScopeAccountsRead,
+	ScopeAccountsWrite,
+	ScopeCategoriesRead,
+	ScopeCategoriesWrite,
+	ScopeTagsRead,
+	ScopeTagsWrite,
+	ScopeRecurringRead,
+	ScopeRecurringWrite,
+	ScopeBudgetsRead,
+	ScopeBudgetsWrite,
+	ScopeNotificationsRead,
+	ScopeNotificationsWrite,
+}
+
+// Scopes is stored as a space-separated string and serialized as a JSON array.
+type Scopes []string
+
+func (s Scopes) Value() (driver.Value, error) {
+	return strings.Join(s, " "), nil
+}
+
+func (s *Scopes) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case string:
+		*s = strings.Fields(v)
+	case []byte:
+		*s = strings.Fields(string(v))
+	case nil:
+		*s = nil
+	default:
+		return fmt.Errorf("cannot scan %T into Scopes", value)
+	}
+	return nil
+}
+
+func (s Scopes) Has(scope string) bool {
+	for _, granted := range s {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// APIKey is a personal access key for scripts and integrations. Only a hash
+// of the key is stored; Prefix identifies it in listings and lookups.
+type APIKey struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	UserID     uint       `gorm:"index;not null" json:"-"`
+	Name       string     `gorm:"not null" json:"name"`
+	Prefix     string     `gorm:"uniqueIndex;not null" json:"prefix"`
+	KeyHash    string     `gorm:"not null" json:"-"`
+	Scopes     Scopes     `gorm:"type:text;not null" json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name" binding:"required,max=100" example:"Import script"`
+	Scopes    []string   `json:"scopes" binding:"required,min=1" example:"transactions:read,transactions:write"`
+	ExpiresAt *time.Time `json:"expires_at" example:"2026-01-01T00:00:00Z"`
+}
+
+// CreateAPIKeyResponse is the only time the full key is returned.
+type CreateAPIKeyResponse struct {
+	APIKey
+	Key string `json:"key" example:"bk_3xq7m2ka_Zm9vYmFy..."`
+}
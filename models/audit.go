@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+const (
+	AuditLoginLocked = "login.locked"
+)
+
+// AuditEvent records a security-relevant event. Details holds a small JSON
+// object specific to the event.
+type AuditEvent struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    *uint     `gorm:"index" json:"user_id"`
+	Event     string    `gorm:"index;not null" json:"event"`
+	IP        string    `json:"ip"`
+	Details   string    `gorm:"type:text" json:"details"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+const (
+	BudgetWeekly  = "weekly"
+	BudgetMonthly = "monthly"
+	BudgetYearly  = "yearly"
+)
+
+// Budget caps the spending in a category (with its subcategories) or on a
+// tag per week, month or year. Periods follow the user's preferences, like
+// the balance. With Rollover, money left at the end of a period is added to
+// the next one.
+type Budget struct {
+	ID     uint   `gorm:"primaryKey" json:"id"`
+	UserID uint   `gorm:"index;not null" json:"-"`
+	Name   string `gorm:"not null" json:"name" validate:"required,max=100"`
+	// Exactly one of CategoryID and TagID is set.
+	CategoryID *uint  `gorm:"index" json:"category_id"`
+	TagID      *uint  `gorm:"index" json:"tag_id"`
+	Period     string `gorm:"not null" json:"period"`
+	// Amount per period in minor units of Currency.
+	AmountMinor int64  `gorm:"not null" json:"amount_minor" validate:"gt=0"`
+	Currency    string `gorm:"size:3;not null" json:"currency"`
+	Rollover    bool   `gorm:"not null;default:false" json:"rollover"`
+	// The budget covers the periods from the one containing StartDate to the
+	// one containing EndDate. Dates are in the user's time zone, stored as
+	// midnight UTC.
+	StartDate time.Time  `gorm:"type:date;not null" json:"start_date"`
+	EndDate   *time.Time `gorm:"type:date" json:"end_date"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// Category groups transactions of one type. Categories form a two-level
+// hierarchy: a top-level category may have children, which have none.
+// Names are unique per user, type and parent, ignoring case.
+type Category struct {
+	ID       uint   `gorm:"primaryKey" json:"id"`
+	UserID   uint   `gorm:"index;not null" json:"-"`
+	ParentID *uint  `gorm:"index" json:"parent_id"`
+	Name     string `gorm:"size:30;not null" json:"name" validate:"required,min=2,max=30"`
+	Type     string `gorm:"not null" json:"type" validate:"required,oneof=income expense"`
+	// Hex color such as #4CAF50, and an icon name for clients.
+	Color     string    `gorm:"size:7" json:"color" example:"#4CAF50"`
+	Icon      string    `gorm:"size:50" json:"icon" example:"cart"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// DefaultCategory is a category every new user starts with.
+type DefaultCategory struct {
+	Name     string
+	Type     string
+	Color    string
+	Icon     string
+	Children []string
+}
+
+var DefaultCategories = []DefaultCategory{
+	{Name: "Salary", Type: "income", Color: "#4CAF50", Icon: "briefcase"},
+	{Name: "Freelance", Type: "income", Color: "#8BC34A", Icon: "laptop"},
+	{Name: "Investments", Type: "income", Color: "#009688", Icon: "trending-up"},
+	{Name: "Gifts", Type: "income", Color: "#CDDC39", Icon: "gift"},
+	{Name: "Other income", Type: "income", Color: "#9E9E9E", Icon: "plus-circle"},
+	{Name: "Food", Type: "expense", Color: "#FF9800", Icon: "utensils", Children: []string{"Groceries", "Restaurants"}},
+	{Name: "Housing", Type: "expense", Color: "#795548", Icon: "home", Children: []string{"Rent", "Utilities"}},
+	{Name: "Transport", Type: "expense", Color: "#2196F3", Icon: "car", Children: []string{"Fuel", "Public transport"}},
+	{Name: "Health", Type: "expense", Color: "#F44336", Icon: "heart"},
+	{Name: "Entertainment", Type: "expense", Color: "#9C27B0", Icon: "film"},
+	{Name: "Shopping", Type: "expense", Color: "#E91E63", Icon: "shopping-bag"},
+	{Name: "Other", Type: "expense", Color: "#607D8B", Icon: "tag"},
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// ExchangeRate says that on Date, 1 unit of Base was worth Rate units of
+// Quote, according to Source (e.g. "ecb").
+type ExchangeRate struct {
+	ID     uint      `gorm:"primaryKey" json:"-"`
+	Source string    `gorm:"size:32;not null;uniqueIndex:idx_exchange_rates_key,priority:1" json:"source"`
+	Base   string    `gorm:"size:3;not null;uniqueIndex:idx_exchange_rates_key,priority:2" json:"base"`
+	Quote  string    `gorm:"size:3;not null;uniqueIndex:idx_exchange_rates_key,priority:3;index" json:"quote"`
+	Date   time.Time `gorm:"type:date;not null;uniqueIndex:idx_exchange_rates_key,priority:4" json:"date"`
+	// Decimal string, stored as NUMERIC so no precision is lost.
+	Rate      string    `gorm:"type:numeric(24,12);not null" json:"rate" example:"1.0956"`
+	CreatedAt time.Time `json:"-"`
+}
+
+// ExchangeRateImportResult is returned by the admin import endpoint.
+type ExchangeRateImportResult struct {
+	Source   string `json:"source"`
+	Imported int    `json:"imported"`
+}
@@ -0,0 +1,74 @@
+package models
+
+import "time"
+
+// Notification kinds.
+const (
+	NotificationBudgetThreshold  = "budget_threshold"
+	NotificationNegativeBalance  = "negative_balance"
+	NotificationLargeTransaction = "large_transaction"
+	NotificationUpcomingBill     = "upcoming_bill"
+)
+
+var NotificationKinds = []string{
+	NotificationBudgetThreshold,
+	NotificationNegativeBalance,
+	NotificationLargeTransaction,
+	NotificationUpcomingBill,
+}
+
+// Notification channels. In-app notifications are the ones listed under
+// /api/notifications.
+const (
+	ChannelInApp   = "in_app"
+	ChannelEmail   = "email"
+	ChannelWebhook = "webhook"
+)
+
+var NotificationChannels = []string{ChannelInApp, ChannelEmail, ChannelWebhook}
+
+type Notification struct {
+	ID     uint   `gorm:"primaryKey" json:"id"`
+	UserID uint   `gorm:"not null;uniqueIndex:idx_notifications_user_key,priority:1" json:"-"`
+	Kind   string `gorm:"not null" json:"kind" example:"budget_threshold"`
+	Title  string `gorm:"not null" json:"title" example:"Groceries budget 80% used"`
+	Body   string `gorm:"not null" json:"body"`
+	// What the notification is about, e.g. "budget" and its ID.
+	ResourceType string `json:"resource_type" example:"budget"`
+	ResourceID   *uint  `json:"resource_id" example:"5"`
+	// Identifies the event, so each one is notified only once.
+	Key string `gorm:"not null;uniqueIndex:idx_notifications_user_key,priority:2" json:"-"`
+	// Whether it is shown in the notification center; stored either way so
+	// the event is not notified again.
+	InApp     bool       `gorm:"not null;index" json:"-"`
+	ReadAt    *time.Time `json:"read_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// NotificationPreference is a user's setting for one channel. Users without
+// a row get DefaultNotificationPreference.
+type NotificationPreference struct {
+	UserID  uint   `gorm:"primaryKey" json:"-"`
+	Channel string `gorm:"primaryKey;size:20" json:"channel" example:"email"`
+	Enabled bool   `gorm:"not null" json:"enabled"`
+	// Comma-separated kinds sent on the channel; empty for all kinds.
+	Kinds string `gorm:"not null;default:''" json:"-"`
+	// Webhook only: where notifications are POSTed, and the key their
+	// X-Signature header is computed with.
+	URL       string    `json:"url,omitempty" example:"https://example.com/hooks/expenses"`
+	Secret    string    `json:"secret,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// DefaultNotificationPreference enables only in-app notifications.
+func DefaultNotificationPreference(userID uint, channel string) NotificationPreference {
+	return NotificationPreference{UserID: userID, Channel: channel, Enabled: channel == ChannelInApp}
+}
+
+// AlertState remembers what alerts depend on between checks.
+type AlertState struct {
+	UserID uint `gorm:"primaryKey"`
+	// When the balance last turned negative; nil while it is not negative.
+	NegativeSince *time.Time
+	UpdatedAt     time.Time
+}
@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+var Weekdays = []string{"sunday", "monday", "tuesday", "wednesday", "thursday", "friday", "saturday"}
+
+// UserPreference holds per-user settings for reports and formatting. Users
+// without a row get DefaultPreferences.
+type UserPreference struct {
+	UserID   uint   `gorm:"primaryKey" json:"-"`
+	Currency string `gorm:"size:3;not null;default:USD" json:"currency" example:"USD"`
+	// IANA time zone, used to decide where days, weeks and months begin.
+	Timezone string `gorm:"not null;default:UTC" json:"timezone" example:"Europe/Berlin"`
+	// BCP 47 tag for number and date formatting in clients.
+	Locale    string `gorm:"not null;default:en-US" json:"locale" example:"en-US"`
+	WeekStart string `gorm:"not null;default:monday" json:"week_start" example:"monday"`
+	// Day of the month a budgeting month starts on, e.g. payday.
+	MonthStartDay int       `gorm:"not null;default:1" json:"month_start_day" example:"1"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+func DefaultPreferences(userID uint) UserPreference {
+	return UserPreference{
+		UserID:        userID,
+		Currency:      "USD",
+		Timezone:      "UTC",
+		Locale:        "en-US",
+		WeekStart:     "monday",
+		MonthStartDay: 1,
+	}
+}
+
+type UpdatePreferencesRequest struct {
+	Currency      *string `json:"currency" binding:"omitempty,iso4217" example:"EUR"`
+	Timezone      *string `json:"timezone" binding:"omitempty,timezone" example:"Europe/Berlin"`
+	Locale        *string `json:"locale" binding:"omitempty,bcp47_language_tag" example:"de-DE"`
+	WeekStart     *string `json:"week_start" binding:"omitempty,oneof=sunday monday tuesday wednesday thursday friday saturday" example:"monday"`
+	MonthStartDay *int    `json:"month_start_day" binding:"omitempty,min=1,max=28" example:"25"`
+}
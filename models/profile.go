@@ -0,0 +1,25 @@
+package models
+
+type UpdateProfileRequest struct {
+	Name        *string                   `json:"name" binding:"omitempty,min=1,max=100" example:"Somebody Someone"`
+	Preferences *UpdatePreferencesRequest `json:"preferences"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required" example:"password123"`
+	NewPassword     string `json:"new_password" binding:"required,min=6" example:"newpassword123"`
+}
+
+type ChangeEmailRequest struct {
+	NewEmail string `json:"new_email" binding:"required,email" example:"somebody@elsewhere.com"`
+	Password string `json:"password" binding:"required" example:"password123"`
+}
+
+type DeleteAccountRequest struct {
+	// Required unless the account only signs in through a social login.
+	Password string `json:"password" example:"password123"`
+}
+
+type ConfirmEmailChangeRequest struct {
+	Token string `json:"token" binding:"required"`
+}
@@ -0,0 +1,60 @@
+package models
+
+import "time"
+
+const (
+	FrequencyDaily   = "daily"
+	FrequencyWeekly  = "weekly"
+	FrequencyMonthly = "monthly"
+	FrequencyYearly  = "yearly"
+)
+
+// RecurringRule creates a transaction on every occurrence of an RRULE-like
+// schedule. Dates are calendar dates in the user's time zone, stored as
+// midnight UTC.
+type RecurringRule struct {
+	ID          uint   `gorm:"primaryKey" json:"id"`
+	UserID      uint   `gorm:"index;not null" json:"-"`
+	AccountID   *uint  `gorm:"index" json:"account_id"`
+	AmountMinor int64  `gorm:"not null" json:"amount_minor"`
+	Currency    string `gorm:"size:3;not null" json:"currency"`
+	Type        string `gorm:"not null" json:"type"`
+	CategoryID  *uint  `gorm:"index" json:"category_id"`
+	Category    string `gorm:"not null" json:"category"`
+	Description string `gorm:"not null" json:"description"`
+
+	Frequency string `gorm:"not null" json:"frequency"`
+	Interval  int    `gorm:"not null;default:1" json:"interval"`
+	// Comma-separated weekdays such as "MO,WE" for weekly rules, or with an
+	// ordinal such as "1MO" or "-1FR" for monthly rules.
+	ByDay string `json:"by_day"`
+	// Day of the month for monthly rules; negative counts from the end.
+	ByMonthDay *int       `json:"by_month_day"`
+	StartDate  time.Time  `gorm:"type:date;not null" json:"start_date"`
+	Until      *time.Time `gorm:"type:date" json:"until"`
+	Count      *int       `json:"count"`
+	// Last possible occurrence given Until and Count, nil if open-ended.
+	EndsOn *time.Time `gorm:"type:date" json:"ends_on"`
+	// Next occurrence to create; nil once the rule has ended.
+	NextDate  *time.Time `gorm:"type:date;index" json:"next_date"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// RecurringOccurrence records what happened to one occurrence of a rule.
+// The row is created when the transaction is, or earlier when the user
+// skips or changes an upcoming occurrence. The unique rule and date make
+// creating an occurrence's transaction idempotent.
+type RecurringOccurrence struct {
+	ID     uint      `gorm:"primaryKey" json:"-"`
+	RuleID uint      `gorm:"not null;uniqueIndex:idx_recurring_occurrences_rule_date,priority:1" json:"rule_id"`
+	UserID uint      `gorm:"index;not null" json:"-"`
+	Date   time.Time `gorm:"type:date;not null;uniqueIndex:idx_recurring_occurrences_rule_date,priority:2" json:"date"`
+	// Overrides for this occurrence only.
+	Skipped       bool      `gorm:"not null;default:false" json:"skipped"`
+	AmountMinor   *int64    `json:"amount_minor"`
+	Description   *string   `json:"description"`
+	TransactionID *uint     `json:"transaction_id"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
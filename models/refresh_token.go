@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// RefreshToken is an opaque, server-side refresh token. Only the SHA-256 hash
+// of the token is stored. Every rotation creates a new row in the same family
+// so that replaying an already rotated token can revoke the whole chain.
+type RefreshToken struct {
+	ID           uint   `gorm:"primaryKey"`
+	UserID       uint   `gorm:"index;not null"`
+	FamilyID     string `gorm:"index;not null"`
+	TokenHash    string `gorm:"uniqueIndex;not null"`
+	ExpiresAt    time.Time
+	RevokedAt    *time.Time
+	ReplacedByID *uint
+	CreatedAt    time.Time
+}
@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+// SchemaMigration records a data migration that has been applied. Schema
+// changes that AutoMigrate can do on its own do not need one.
+type SchemaMigration struct {
+	Version   string `gorm:"primaryKey"`
+	AppliedAt time.Time
+}
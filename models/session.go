@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// Session is one sign-in on one device. Its ID is also the family ID of the
+// refresh tokens issued for it and the "sid" claim of its access tokens.
+type Session struct {
+	ID         string     `gorm:"primaryKey;size:32" json:"id"`
+	UserID     uint       `gorm:"index;not null" json:"-"`
+	UserAgent  string     `json:"user_agent"`
+	IP         string     `json:"ip"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"-"`
+	// Current marks the session the request was made with.
+	Current bool `gorm:"-" json:"current"`
+}
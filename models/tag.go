@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// Tag is a free-form label that can be put on any number of transactions,
+// across categories. Names are stored in lower case and unique per user.
+type Tag struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_tags_user_name,priority:1" json:"-"`
+	Name      string    `gorm:"size:50;not null;uniqueIndex:idx_tags_user_name,priority:2" json:"name" example:"vacation-2026"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
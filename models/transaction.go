@@ -5,13 +5,26 @@ import (
 )
 
 type Transaction struct {
-	ID          uint      `gorm:"primaryKey" json:"id"`
-	UserID      uint      `json:"-"`
-	Amount      float64   `json:"amount" validate:"required,gt=0"`
+	ID     uint `gorm:"primaryKey;index:idx_transactions_user_date,priority:3" json:"id"`
+	UserID uint `gorm:"index:idx_transactions_user_date,priority:1" json:"-"`
+	// Optional account the transaction is booked against.
+	AccountID *uint `gorm:"index" json:"account_id"`
+	// Set on the two legs of a transfer.
+	TransferID *uint `gorm:"index" json:"transfer_id"`
+	// Set on transactions created by a recurring rule.
+	RecurringRuleID *uint `gorm:"index" json:"recurring_rule_id"`
+	// Amount in minor units of Currency, e.g. cents; see package money.
+	AmountMinor int64  `gorm:"not null;default:0" json:"amount_minor" validate:"gt=0"`
+	Currency    string `gorm:"size:3;not null;default:USD" json:"currency" validate:"required,len=3"`
+	// Unset on transfer legs.
+	CategoryID *uint `gorm:"index" json:"category_id"`
+	// Name of the category, kept in step with CategoryID.
 	Category    string    `json:"category" validate:"required,min=2,max=30"`
 	Description string    `json:"description" validate:"required,min=2"`
-	Type        string    `json:"type" validate:"required,oneof=income expense"` // income or expense
-	Date        time.Time `json:"date"`
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
+	Type        string    `json:"type" validate:"required,oneof=income expense transfer_in transfer_out"` // income, expense or a transfer leg
+	Date        time.Time `gorm:"index:idx_transactions_user_date,priority:2" json:"date"`
+	// Links are removed together with the transaction or the tag.
+	Tags      []Tag `gorm:"many2many:transaction_tags;constraint:OnDelete:CASCADE" json:"tags"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
 }
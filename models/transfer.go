@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// Types of the two transactions a transfer is booked as.
+const (
+	TransactionTransferIn  = "transfer_in"
+	TransactionTransferOut = "transfer_out"
+)
+
+// Transfer moves money between two of a user's accounts. It is booked as a
+// transfer_out transaction on the source account and a transfer_in
+// transaction on the destination, which count towards account balances but
+// not towards income or expenses.
+type Transfer struct {
+	ID            uint `gorm:"primaryKey" json:"id"`
+	UserID        uint `gorm:"index;not null" json:"-"`
+	FromAccountID uint `gorm:"not null" json:"from_account_id"`
+	ToAccountID   uint `gorm:"not null" json:"to_account_id"`
+	// Units of the destination currency per unit of the source currency; 1
+	// between accounts in the same currency.
+	Rate        string        `gorm:"type:numeric(24,12);not null" json:"rate" example:"1.0956"`
+	Date        time.Time     `json:"date"`
+	Description string        `json:"description"`
+	Legs        []Transaction `gorm:"foreignKey:TransferID" json:"-"`
+	CreatedAt   time.Time     `json:"created_at"`
+	UpdatedAt   time.Time     `json:"updated_at"`
+}
+
+// Leg returns the transfer's transaction of the given type.
+func (t *Transfer) Leg(typ string) *Transaction {
+	for i := range t.Legs {
+		if t.Legs[i].Type == typ {
+			return &t.Legs[i]
+		}
+	}
+	return nil
+}
@@ -1,10 +1,40 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	RoleUser    = "user"
+	RoleAdmin   = "admin"
+	RoleAuditor = "auditor"
+)
+
+var Roles = []string{RoleUser, RoleAdmin, RoleAuditor}
 
 type User struct {
 	gorm.Model
-	Name     string `json:"name" gorm:"not null"`
-	Email    string `json:"email" gorm:"unique;not null"`
-	Password string `json:"-" gorm:"not null"` // We'll hash this before saving
+	Name            string     `json:"name" gorm:"not null"`
+	Email           string     `json:"email" gorm:"unique;not null"`
+	Password        string     `json:"-" gorm:"not null"` // We'll hash this before saving
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	Role            string     `json:"role" gorm:"not null;default:user"`
+	DisabledAt      *time.Time `json:"disabled_at"`
+	// Set by an admin; logins are refused until the password is reset.
+	PasswordResetRequired bool `json:"password_reset_required" gorm:"not null;default:false"`
+	// When set, the account and its data are erased at this time unless the
+	// user cancels.
+	DeletionScheduledAt *time.Time `json:"deletion_scheduled_at" gorm:"index"`
+
+	// TOTP secret, set once enrollment is confirmed.
+	MFASecret string `json:"-"`
+	// Secret waiting for the first valid code during enrollment.
+	MFAPendingSecret string     `json:"-"`
+	MFAEnabledAt     *time.Time `json:"mfa_enabled_at"`
+	// Last accepted TOTP time step; a code is never accepted twice.
+	MFALastUsedStep int64 `json:"-"`
+
+	Preferences *UserPreference `json:"preferences,omitempty"`
 }
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+const (
+	TokenPurposePasswordReset     = "password_reset"
+	TokenPurposeEmailVerification = "email_verification"
+	TokenPurposeEmailChange       = "email_change"
+)
+
+// UserToken is a single-use, expiring token sent to a user by email. Only the
+// SHA-256 hash of the token is stored.
+type UserToken struct {
+	ID        uint   `gorm:"primaryKey"`
+	UserID    uint   `gorm:"index;not null"`
+	Purpose   string `gorm:"index;not null"`
+	TokenHash string `gorm:"uniqueIndex;not null"`
+	// Purpose-specific data, e.g. the new address for an email change.
+	Payload   string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
@@ -0,0 +1,132 @@
+// Package money represents amounts exactly, as an integer number of minor
+// units (e.g. cents) of a currency.
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidAmount = errors.New("invalid amount")
+	ErrTooManyDigits = errors.New("amount has more decimal places than the currency allows")
+	ErrOutOfRange    = errors.New("amount is out of range")
+)
+
+// Currencies whose minor unit is not 1/100 of the major unit (ISO 4217).
+var exponents = map[string]int{
+	"BHD": 3, "BIF": 0, "CLF": 4, "CLP": 0, "DJF": 0, "GNF": 0, "IQD": 3,
+	"ISK": 0, "JOD": 3, "JPY": 0, "KMF": 0, "KRW": 0, "KWD": 3, "LYD": 3,
+	"OMR": 3, "PYG": 0, "RWF": 0, "TND": 3, "UGX": 0, "UYI": 0, "UYW": 4,
+	"VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+}
+
+// Exponent returns the number of decimal places of the currency's minor
+// unit.
+func Exponent(currency string) int {
+	if exp, ok := exponents[strings.ToUpper(currency)]; ok {
+		return exp
+	}
+	return 2
+}
+
+// Parse converts a decimal string such as "150.5" to minor units of the
+// currency. It never goes through float64, and rejects more decimal places
+// than the currency has instead of rounding.
+func Parse(s, currency string) (int64, error) {
+	s = strings.TrimSpace(s)
+	sign := ""
+	if s != "" && (s[0] == '-' || s[0] == '+') {
+		if s[0] == '-' {
+			sign = "-"
+		}
+		s = s[1:]
+	}
+
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" && frac == "" || !isDigits(whole) || !isDigits(frac) {
+		return 0, ErrInvalidAmount
+	}
+
+	exp := Exponent(currency)
+	frac = strings.TrimRight(frac, "0")
+	if len(frac) > exp {
+		return 0, ErrTooManyDigits
+	}
+	frac += strings.Repeat("0", exp-len(frac))
+
+	// Parse with the sign so math.MinInt64 is in range.
+	minor, err := strconv.ParseInt(sign+whole+frac, 10, 64)
+	if err != nil {
+		if errors.Is(err, strconv.ErrRange) {
+			return 0, ErrOutOfRange
+		}
+		return 0, ErrInvalidAmount
+	}
+	return minor, nil
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Format renders minor units with exactly the currency's number of decimal
+// places, e.g. Format(15050, "USD") == "150.50".
+func Format(minor int64, currency string) string {
+	exp := Exponent(currency)
+	negative := minor < 0
+	digits := strconv.FormatUint(absUint(minor), 10)
+
+	if exp > 0 {
+		if len(digits) <= exp {
+			digits = strings.Repeat("0", exp-len(digits)+1) + digits
+		}
+		digits = digits[:len(digits)-exp] + "." + digits[len(digits)-exp:]
+	}
+	if negative {
+		return "-" + digits
+	}
+	return digits
+}
+
+func absUint(v int64) uint64 {
+	if v == math.MinInt64 {
+		return uint64(math.MaxInt64) + 1
+	}
+	if v < 0 {
+		return uint64(-v)
+	}
+	return uint64(v)
+}
+
+// Decimal is a decimal amount as sent by a client. It accepts both a JSON
+// number and a JSON string and keeps the exact text, so no precision is
+// lost to float64 before Parse.
+type Decimal string
+
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*d = Decimal(s)
+		return nil
+	}
+
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err != nil {
+		return ErrInvalidAmount
+	}
+	*d = Decimal(n)
+	return nil
+}
+
+func (d Decimal) String() string {
+	return string(d)
+}
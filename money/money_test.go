@@ -0,0 +1,166 @@
+package money_test
+
+import (
+	"backend101/dto"
+	"backend101/money"
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in       string
+		currency string
+		want     int64
+		err      error
+	}{
+		{"150.50", "USD", 15050, nil},
+		{"150.5", "usd", 15050, nil},
+		{"150", "USD", 15000, nil},
+		{" 0.01 ", "USD", 1, nil},
+		{".5", "USD", 50, nil},
+		{"1.", "USD", 100, nil},
+		{"10.00000", "USD", 1000, nil},
+		{"+3", "USD", 300, nil},
+		{"-12.34", "USD", -1234, nil},
+		{"-0", "USD", 0, nil},
+		{"1000", "JPY", 1000, nil},
+		{"1000.0", "JPY", 1000, nil},
+		{"1.000", "KWD", 1000, nil},
+		{"1.5", "KWD", 1500, nil},
+		{"0.0001", "CLF", 1, nil},
+		{"92233720368547758.07", "USD", math.MaxInt64, nil},
+		{"-92233720368547758.08", "USD", math.MinInt64, nil},
+
+		{"10.005", "USD", 0, money.ErrTooManyDigits},
+		{"12.34", "JPY", 0, money.ErrTooManyDigits},
+		{"1.0001", "KWD", 0, money.ErrTooManyDigits},
+		{"92233720368547758.08", "USD", 0, money.ErrOutOfRange},
+		{"-92233720368547758.09", "USD", 0, money.ErrOutOfRange},
+
+		{"", "USD", 0, money.ErrInvalidAmount},
+		{".", "USD", 0, money.ErrInvalidAmount},
+		{"-", "USD", 0, money.ErrInvalidAmount},
+		{"1e5", "USD", 0, money.ErrInvalidAmount},
+		{"+-1", "USD", 0, money.ErrInvalidAmount},
+		{"-+1", "USD", 0, money.ErrInvalidAmount},
+		{"--1", "USD", 0, money.ErrInvalidAmount},
+		{"1,50", "USD", 0, money.ErrInvalidAmount},
+		{"1.2.3", "USD", 0, money.ErrInvalidAmount},
+		{"0x10", "USD", 0, money.ErrInvalidAmount},
+		{"NaN", "USD", 0, money.ErrInvalidAmount},
+	}
+
+	for _, tt := range tests {
+		got, err := money.Parse(tt.in, tt.currency)
+		if !errors.Is(err, tt.err) || got != tt.want {
+			t.Errorf("Parse(%q, %s) = %d, %v; want %d, %v", tt.in, tt.currency, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		minor    int64
+		currency string
+		want     string
+	}{
+		{15050, "USD", "150.50"},
+		{5, "USD", "0.05"},
+		{0, "USD", "0.00"},
+		{-1234, "USD", "-12.34"},
+		{-5, "USD", "-0.05"},
+		{1000, "JPY", "1000"},
+		{-1000, "JPY", "-1000"},
+		{1000, "KWD", "1.000"},
+		{1, "KWD", "0.001"},
+		{math.MaxInt64, "USD", "92233720368547758.07"},
+		{math.MinInt64, "USD", "-92233720368547758.08"},
+		{math.MinInt64, "JPY", "-9223372036854775808"},
+	}
+
+	for _, tt := range tests {
+		got := money.Format(tt.minor, tt.currency)
+		if got != tt.want {
+			t.Errorf("Format(%d, %s) = %q, want %q", tt.minor, tt.currency, got, tt.want)
+		}
+		if back, err := money.Parse(got, tt.currency); err != nil || back != tt.minor {
+			t.Errorf("Parse(Format(%d, %s)) = %d, %v", tt.minor, tt.currency, back, err)
+		}
+	}
+}
+
+func TestDecimalUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		json string
+		want money.Decimal
+	}{
+		{`150.50`, "150.50"},
+		{`"150.50"`, "150.50"},
+		{`0.1`, "0.1"},
+		{`"0.1"`, "0.1"},
+		{`-12`, "-12"},
+		{`"-12"`, "-12"},
+		{`1e5`, "1e5"}, // kept as sent; Parse refuses it
+	}
+
+	for _, tt := range tests {
+		var got struct {
+			Amount money.Decimal `json:"amount"`
+		}
+		if err := json.Unmarshal([]byte(`{"amount":`+tt.json+`}`), &got); err != nil {
+			t.Errorf("%s: %v", tt.json, err)
+			continue
+		}
+		if got.Amount != tt.want {
+			t.Errorf("%s: Decimal = %q, want %q", tt.json, got.Amount, tt.want)
+		}
+	}
+
+	// A number and a string with the same text parse to the same amount.
+	var number, str money.Decimal
+	if err := json.Unmarshal([]byte(`19.99`), &number); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(`"19.99"`), &str); err != nil {
+		t.Fatal(err)
+	}
+	if number != str {
+		t.Errorf("number %q and string %q differ", number, str)
+	}
+
+	for _, in := range []string{`true`, `{}`, `[1]`} {
+		var d money.Decimal
+		if err := json.Unmarshal([]byte(in), &d); err == nil {
+			t.Errorf("%s: decoded as %q, want an error", in, d)
+		}
+	}
+}
+
+func TestAmountVersions(t *testing.T) {
+	tests := []struct {
+		minor    int64
+		currency string
+		version  int
+		want     string
+	}{
+		{15050, "USD", dto.APIVersion1, `{"amount":150.50}`},
+		{15050, "USD", dto.APIVersion2, `{"amount":"150.50"}`},
+		{-5, "USD", dto.APIVersion1, `{"amount":-0.05}`},
+		{-5, "USD", dto.APIVersion2, `{"amount":"-0.05"}`},
+		{1000, "JPY", dto.APIVersion1, `{"amount":1000}`},
+		{1000, "KWD", dto.APIVersion2, `{"amount":"1.000"}`},
+	}
+
+	for _, tt := range tests {
+		data, err := json.Marshal(map[string]interface{}{"amount": dto.Amount(tt.minor, tt.currency, tt.version)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != tt.want {
+			t.Errorf("Amount(%d, %s, v%d) = %s, want %s", tt.minor, tt.currency, tt.version, data, tt.want)
+		}
+	}
+}
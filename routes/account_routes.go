@@ -0,0 +1,27 @@
+package routes
+
+import (
+	"backend101/controllers"
+	"backend101/middleware"
+	"backend101/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func AccountRoutes(router *gin.Engine) {
+	read := middleware.RequireScope(models.ScopeAccountsRead)
+	write := middleware.RequireScope(models.ScopeAccountsWrite)
+
+	accounts := router.Group("/api/accounts")
+	accounts.Use(middleware.AuthMiddleware())
+	{
+		accounts.POST("", write, middleware.RequireVerifiedEmail(), controllers.CreateAccount)
+		accounts.GET("", read, controllers.GetAccounts)
+		accounts.GET("/balances", read, controllers.GetAccountBalances)
+		accounts.GET("/:id", read, controllers.GetAccount)
+		accounts.PATCH("/:id", write, middleware.RequireVerifiedEmail(), controllers.UpdateAccount)
+		accounts.POST("/:id/archive", write, middleware.RequireVerifiedEmail(), controllers.ArchiveAccount)
+		accounts.POST("/:id/unarchive", write, middleware.RequireVerifiedEmail(), controllers.UnarchiveAccount)
+		accounts.DELETE("/:id", write, middleware.RequireVerifiedEmail(), controllers.DeleteAccount)
+	}
+}
@@ -0,0 +1,31 @@
+package routes
+
+import (
+	"backend101/controllers"
+	"backend101/middleware"
+	"backend101/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func AdminRoutes(router *gin.Engine) {
+	admin := router.Group("/api/admin")
+	admin.Use(middleware.JWTMiddleware(), middleware.RequireRole(models.RoleAdmin, models.RoleAuditor))
+	{
+		admin.GET("/users", controllers.AdminListUsers)
+		admin.GET("/users/:id", controllers.AdminGetUser)
+		admin.GET("/stats/transactions", controllers.AdminTransactionStats)
+	}
+
+	// Auditors can look but not touch.
+	write := admin.Group("")
+	write.Use(middleware.RequireRole(models.RoleAdmin))
+	{
+		write.POST("/users/:id/disable", controllers.AdminDisableUser)
+		write.POST("/users/:id/enable", controllers.AdminEnableUser)
+		write.PUT("/users/:id/role", controllers.AdminUpdateUserRole)
+		write.POST("/users/:id/force-password-reset", controllers.AdminForcePasswordReset)
+
+		write.POST("/exchange-rates/import", controllers.AdminImportExchangeRates)
+	}
+}
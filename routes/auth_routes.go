@@ -2,6 +2,7 @@ package routes
 
 import (
 	"backend101/controllers"
+	"backend101/middleware"
 
 	"github.com/gin-gonic/gin"
 )
@@ -11,5 +12,21 @@ func AuthRoutes(router *gin.Engine) {
 	{
 		authGroup.POST("/register", controllers.Register)
 		authGroup.POST("/login", controllers.Login)
+		authGroup.POST("/refresh", controllers.Refresh)
+		authGroup.POST("/forgot-password", controllers.ForgotPassword)
+		authGroup.POST("/reset-password", controllers.ResetPassword)
+		authGroup.POST("/verify-email", controllers.VerifyEmail)
+		authGroup.POST("/resend-verification", controllers.ResendVerification)
+		authGroup.POST("/confirm-email-change", controllers.ConfirmEmailChange)
+		authGroup.POST("/mfa/verify", controllers.VerifyMFA)
+		authGroup.GET("/oauth/:provider/login", controllers.OAuthLogin)
+		authGroup.GET("/oauth/:provider/callback", controllers.OAuthCallback)
+	}
+
+	protected := authGroup.Group("")
+	protected.Use(middleware.JWTMiddleware())
+	{
+		protected.POST("/logout", controllers.Logout)
+		protected.POST("/logout-all", controllers.LogoutAll)
 	}
 }
@@ -0,0 +1,26 @@
+package routes
+
+import (
+	"backend101/controllers"
+	"backend101/middleware"
+	"backend101/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func BudgetRoutes(router *gin.Engine) {
+	read := middleware.RequireScope(models.ScopeBudgetsRead)
+	write := middleware.RequireScope(models.ScopeBudgetsWrite)
+
+	budgets := router.Group("/api/budgets")
+	budgets.Use(middleware.AuthMiddleware())
+	{
+		budgets.POST("", write, middleware.RequireVerifiedEmail(), controllers.CreateBudget)
+		budgets.GET("", read, controllers.GetBudgets)
+		budgets.GET("/summary", read, controllers.GetBudgetSummary)
+		budgets.GET("/:id", read, controllers.GetBudget)
+		budgets.PATCH("/:id", write, middleware.RequireVerifiedEmail(), controllers.UpdateBudget)
+		budgets.DELETE("/:id", write, middleware.RequireVerifiedEmail(), controllers.DeleteBudget)
+		budgets.GET("/:id/progress", read, controllers.GetBudgetProgress)
+	}
+}
@@ -0,0 +1,25 @@
+package routes
+
+import (
+	"backend101/controllers"
+	"backend101/middleware"
+	"backend101/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func CategoryRoutes(router *gin.Engine) {
+	read := middleware.RequireScope(models.ScopeCategoriesRead)
+	write := middleware.RequireScope(models.ScopeCategoriesWrite)
+
+	categories := router.Group("/api/categories")
+	categories.Use(middleware.AuthMiddleware())
+	{
+		categories.POST("", write, middleware.RequireVerifiedEmail(), controllers.CreateCategory)
+		categories.GET("", read, controllers.GetCategories)
+		categories.GET("/:id", read, controllers.GetCategory)
+		categories.PATCH("/:id", write, middleware.RequireVerifiedEmail(), controllers.UpdateCategory)
+		categories.DELETE("/:id", write, middleware.RequireVerifiedEmail(), controllers.DeleteCategory)
+		categories.POST("/:id/merge", write, middleware.RequireVerifiedEmail(), controllers.MergeCategory)
+	}
+}
@@ -0,0 +1,16 @@
+package routes
+
+import (
+	"backend101/controllers"
+	"backend101/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func ExchangeRateRoutes(router *gin.Engine) {
+	rates := router.Group("/api/exchange-rates")
+	rates.Use(middleware.AuthMiddleware())
+	{
+		rates.GET("", controllers.GetExchangeRates)
+	}
+}
@@ -0,0 +1,26 @@
+package routes
+
+import (
+	"backend101/controllers"
+	"backend101/middleware"
+	"backend101/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func NotificationRoutes(router *gin.Engine) {
+	read := middleware.RequireScope(models.ScopeNotificationsRead)
+	write := middleware.RequireScope(models.ScopeNotificationsWrite)
+
+	notifications := router.Group("/api/notifications")
+	notifications.Use(middleware.AuthMiddleware())
+	{
+		notifications.GET("", read, controllers.GetNotifications)
+		notifications.POST("/read-all", write, controllers.MarkAllNotificationsRead)
+		notifications.GET("/channels", read, controllers.GetNotificationChannels)
+		notifications.PUT("/channels/:channel", write, middleware.RequireVerifiedEmail(), controllers.UpdateNotificationChannel)
+		notifications.POST("/:id/read", write, controllers.MarkNotificationRead)
+		notifications.POST("/:id/unread", write, controllers.MarkNotificationUnread)
+		notifications.DELETE("/:id", write, controllers.DeleteNotification)
+	}
+}
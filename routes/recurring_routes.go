@@ -0,0 +1,27 @@
+package routes
+
+import (
+	"backend101/controllers"
+	"backend101/middleware"
+	"backend101/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func RecurringRoutes(router *gin.Engine) {
+	read := middleware.RequireScope(models.ScopeRecurringRead)
+	write := middleware.RequireScope(models.ScopeRecurringWrite)
+
+	recurring := router.Group("/api/recurring")
+	recurring.Use(middleware.AuthMiddleware())
+	{
+		recurring.POST("", write, middleware.RequireVerifiedEmail(), controllers.CreateRecurringRule)
+		recurring.GET("", read, controllers.GetRecurringRules)
+		recurring.GET("/:id", read, controllers.GetRecurringRule)
+		recurring.PATCH("/:id", write, middleware.RequireVerifiedEmail(), controllers.UpdateRecurringRule)
+		recurring.DELETE("/:id", write, middleware.RequireVerifiedEmail(), controllers.DeleteRecurringRule)
+		recurring.GET("/:id/occurrences", read, controllers.GetOccurrences)
+		recurring.PATCH("/:id/occurrences/:date", write, middleware.RequireVerifiedEmail(), controllers.UpdateOccurrence)
+		recurring.DELETE("/:id/occurrences/:date", write, middleware.RequireVerifiedEmail(), controllers.ResetOccurrence)
+	}
+}
@@ -0,0 +1,25 @@
+package routes
+
+import (
+	"backend101/controllers"
+	"backend101/middleware"
+	"backend101/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TagRoutes(router *gin.Engine) {
+	read := middleware.RequireScope(models.ScopeTagsRead)
+	write := middleware.RequireScope(models.ScopeTagsWrite)
+
+	tags := router.Group("/api/tags")
+	tags.Use(middleware.AuthMiddleware())
+	{
+		tags.POST("", write, middleware.RequireVerifiedEmail(), controllers.CreateTag)
+		tags.GET("", read, controllers.GetTags)
+		tags.GET("/totals", read, controllers.GetTagTotals)
+		tags.GET("/:id", read, controllers.GetTag)
+		tags.PATCH("/:id", write, middleware.RequireVerifiedEmail(), controllers.RenameTag)
+		tags.DELETE("/:id", write, middleware.RequireVerifiedEmail(), controllers.DeleteTag)
+	}
+}
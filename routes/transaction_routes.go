@@ -3,18 +3,23 @@ package routes
 import (
 	"backend101/controllers"
 	"backend101/middleware"
+	"backend101/models"
 
 	"github.com/gin-gonic/gin"
 )
 
 func TransactionRoutes(router *gin.Engine) {
+	read := middleware.RequireScope(models.ScopeTransactionsRead)
+	write := middleware.RequireScope(models.ScopeTransactionsWrite)
+
 	tx := router.Group("/api/transactions")
-	tx.Use(middleware.JWTMiddleware())
+	tx.Use(middleware.AuthMiddleware())
 	{
-		tx.POST("/", controllers.CreateTransaction)
-		tx.GET("/", controllers.GetTransactions)
-		tx.PUT("/:id", controllers.UpdateTransaction)
-		tx.DELETE(("/:id"), controllers.DeleteTransaction)
-		tx.GET("/balance", controllers.GetBalance)
+		tx.POST("/", write, middleware.RequireVerifiedEmail(), controllers.CreateTransaction)
+		tx.GET("/", read, controllers.GetTransactions)
+		tx.PUT("/:id", write, middleware.RequireVerifiedEmail(), controllers.UpdateTransaction)
+		tx.PATCH("/:id", write, middleware.RequireVerifiedEmail(), controllers.PatchTransaction)
+		tx.DELETE(("/:id"), write, middleware.RequireVerifiedEmail(), controllers.DeleteTransaction)
+		tx.GET("/balance", read, controllers.GetBalance)
 	}
 }
@@ -0,0 +1,25 @@
+package routes
+
+import (
+	"backend101/controllers"
+	"backend101/middleware"
+	"backend101/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Transfers are pairs of transactions, so they share the transaction scopes.
+func TransferRoutes(router *gin.Engine) {
+	read := middleware.RequireScope(models.ScopeTransactionsRead)
+	write := middleware.RequireScope(models.ScopeTransactionsWrite)
+
+	transfers := router.Group("/api/transfers")
+	transfers.Use(middleware.AuthMiddleware())
+	{
+		transfers.POST("", write, middleware.RequireVerifiedEmail(), controllers.CreateTransfer)
+		transfers.GET("", read, controllers.GetTransfers)
+		transfers.GET("/:id", read, controllers.GetTransfer)
+		transfers.PATCH("/:id", write, middleware.RequireVerifiedEmail(), controllers.UpdateTransfer)
+		transfers.DELETE("/:id", write, middleware.RequireVerifiedEmail(), controllers.DeleteTransfer)
+	}
+}
@@ -12,5 +12,29 @@ func UserRoutes(router *gin.Engine) {
 	user.Use(middleware.JWTMiddleware())
 	{
 		user.GET("/me", controllers.Me)
+		user.PATCH("/me", controllers.UpdateMe)
+		user.DELETE("/me", controllers.DeleteMe)
+		user.POST("/me/cancel-deletion", controllers.CancelDeletion)
+		user.GET("/export", controllers.ExportData)
+
+		user.GET("/preferences", controllers.GetPreferences)
+		user.PATCH("/preferences", controllers.UpdatePreferences)
+		user.POST("/password", controllers.ChangePassword)
+		user.POST("/email", controllers.ChangeEmail)
+
+		user.GET("/sessions", controllers.GetSessions)
+		user.DELETE("/sessions/:id", controllers.DeleteSession)
+
+		user.POST("/mfa/enroll", controllers.EnrollMFA)
+		user.POST("/mfa/confirm", controllers.ConfirmMFA)
+		user.POST("/mfa/disable", controllers.DisableMFA)
+		user.POST("/mfa/recovery-codes", controllers.RegenerateRecoveryCodes)
+
+		user.GET("/identities", controllers.GetIdentities)
+		user.DELETE("/identities/:id", controllers.DeleteIdentity)
+
+		user.POST("/api-keys", controllers.CreateAPIKey)
+		user.GET("/api-keys", controllers.GetAPIKeys)
+		user.DELETE("/api-keys/:id", controllers.RevokeAPIKey)
 	}
 }
@@ -0,0 +1,14 @@
+package routes
+
+import (
+	"backend101/controllers"
+
+	"github.com/gin-gonic/gin"
+)
+
+func WellKnownRoutes(router *gin.Engine) {
+	wellKnown := router.Group("/.well-known")
+	{
+		wellKnown.GET("/jwks.json", controllers.JWKS)
+	}
+}
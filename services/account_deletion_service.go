@@ -0,0 +1,154 @@
+package services
+
+import (
+	"backend101/config"
+	"backend101/database"
+	"backend101/mailer"
+	"backend101/models"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrDeletionAlreadyScheduled = errors.New("account deletion is already scheduled")
+	ErrDeletionNotScheduled     = errors.New("account deletion is not scheduled")
+)
+
+// userDataModels are hard-deleted together with the user. Every table with
+// a user_id belongs here, except the audit log, which is anonymized instead.
+var userDataModels = []interface{}{
+	&models.Notification{},
+	&models.NotificationPreference{},
+	&models.AlertState{},
+	&models.Transaction{},
+	&models.Transfer{},
+	&models.RecurringOccurrence{},
+	&models.RecurringRule{},
+	&models.Budget{},
+	&models.Category{},
+	&models.Tag{},
+	&models.Account{},
+	&models.RefreshToken{},
+	&models.Session{},
+	&models.UserToken{},
+	&models.RecoveryCode{},
+	&models.UserIdentity{},
+	&models.APIKey{},
+	&models.UserPreference{},
+}
+
+func accountDeletionGracePeriod() time.Duration {
+	return 24 * time.Hour * time.Duration(config.GetInt("ACCOUNT_DELETION_GRACE_DAYS"))
+}
+
+// ScheduleAccountDeletion marks the account for erasure after the grace
+// period and signs out every other session. Users with a password must
+// confirm it.
+func ScheduleAccountDeletion(ctx context.Context, userID uint, keepSessionID, password string) (time.Time, error) {
+	user, err := GetProfile(userID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if user.DeletionScheduledAt != nil {
+		return time.Time{}, ErrDeletionAlreadyScheduled
+	}
+	if user.Password != "" && !VerifyUserPassword(user, password) {
+		return time.Time{}, ErrInvalidPassword
+	}
+
+	deleteAt := Now().Add(accountDeletionGracePeriod())
+	if err := database.DB.Model(user).Update("deletion_scheduled_at", deleteAt).Error; err != nil {
+		return time.Time{}, err
+	}
+
+	sendMailAsync(mailer.Message{
+		To:      user.Email,
+		Subject: "Your account will be deleted",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"Your account and all of its data will be permanently deleted on %s.\n\n"+
+			"Changed your mind? Sign in before then and cancel the deletion from your account settings.\n",
+			user.Name, deleteAt.UTC().Format("2 January 2006 15:04 MST")),
+	})
+
+	if err := RevokeOtherSessions(ctx, userID, keepSessionID); err != nil {
+		return time.Time{}, err
+	}
+	return deleteAt, nil
+}
+
+func CancelAccountDeletion(userID uint) error {
+	result := database.DB.Model(&models.User{}).
+		Where("id = ? AND deletion_scheduled_at IS NOT NULL", userID).
+		Update("deletion_scheduled_at", nil)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrDeletionNotScheduled
+	}
+	return nil
+}
+
+// PurgeDueAccounts erases every account whose grace period has ended and
+// returns how many were erased. A failure on one account does not stop the
+// others.
+func PurgeDueAccounts() (int, error) {
+	var ids []uint
+	if err := database.DB.Model(&models.User{}).Unscoped().
+		Where("deletion_scheduled_at <= ?", Now()).
+		Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, id := range ids {
+		if err := purgeAccount(id); err != nil {
+			log.Printf("❌ Failed to purge account %d: %v", id, err)
+			continue
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// purgeAccount hard-deletes the user and their data. Audit events are kept
+// for security investigations but no longer point at the user.
+func purgeAccount(userID uint) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		// Lock the user and re-check, in case the deletion was cancelled or
+		// another instance got here first.
+		var user models.User
+		err := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("id = ? AND deletion_scheduled_at <= ?", userID, Now()).
+			First(&user).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		for _, model := range userDataModels {
+			if err := tx.Unscoped().Where("user_id = ?", userID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Model(&models.AuditEvent{}).Where("user_id = ?", userID).Updates(map[string]interface{}{
+			"user_id": nil,
+			"ip":      "",
+			// Details may contain the email address.
+			"details": "{}",
+		}).Error; err != nil {
+			return err
+		}
+
+		return tx.Unscoped().Delete(&user).Error
+	})
+}
@@ -0,0 +1,239 @@
+package services
+
+import (
+	"backend101/database"
+	"backend101/dto"
+	"backend101/models"
+	"backend101/money"
+	"errors"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrAccountNotFound = errors.New("account not found")
+	ErrAccountArchived = errors.New("account is archived")
+	ErrAccountInUse    = errors.New("account has transactions")
+)
+
+func CreateAccount(userID uint, input dto.CreateAccountInput) (*models.Account, error) {
+	currency := ""
+	if input.Currency != nil {
+		currency = strings.ToUpper(*input.Currency)
+	} else {
+		prefs, err := GetPreferences(userID)
+		if err != nil {
+			return nil, err
+		}
+		currency = prefs.Currency
+	}
+
+	var opening int64
+	if input.OpeningBalance != "" {
+		var err error
+		if opening, err = money.Parse(input.OpeningBalance.String(), currency); err != nil {
+			return nil, err
+		}
+	}
+
+	account := models.Account{
+		UserID:              userID,
+		Name:                strings.TrimSpace(input.Name),
+		Type:                input.Type,
+		Currency:            currency,
+		OpeningBalanceMinor: opening,
+	}
+	if err := database.DB.Create(&account).Error; err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// ListAccounts returns the user's accounts by name. Archived accounts are
+// only included on request.
+func ListAccounts(userID uint, includeArchived bool) ([]models.Account, error) {
+	db := database.DB.Where("user_id = ?", userID)
+	if !includeArchived {
+		db = db.Where("archived_at IS NULL")
+	}
+
+	accounts := []models.Account{}
+	err := db.Order("name, id").Find(&accounts).Error
+	return accounts, err
+}
+
+func GetAccount(userID uint, id interface{}) (*models.Account, error) {
+	var account models.Account
+	if err := database.DB.Where("id = ? AND user_id = ?", id, userID).First(&account).Error; err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// activeAccount returns an account transactions can be booked against.
+func activeAccount(userID, id uint) (*models.Account, error) {
+	account, err := GetAccount(userID, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrAccountNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if account.ArchivedAt != nil {
+		return nil, ErrAccountArchived
+	}
+	return account, nil
+}
+
+// UpdateAccount applies the fields that are set in input.
+func UpdateAccount(userID uint, id string, input dto.UpdateAccountInput) (*models.Account, error) {
+	account, err := GetAccount(userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if input.Name != nil {
+		account.Name = strings.TrimSpace(*input.Name)
+	}
+	if input.Type != nil {
+		account.Type = *input.Type
+	}
+	if input.OpeningBalance != nil {
+		if account.OpeningBalanceMinor, err = money.Parse(input.OpeningBalance.String(), account.Currency); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := database.DB.Save(account).Error; err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// SetAccountArchived archives or restores an account. Its transactions are
+// untouched either way.
+func SetAccountArchived(userID uint, id string, archived bool) (*models.Account, error) {
+	account, err := GetAccount(userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if archived && account.ArchivedAt == nil {
+		now := Now()
+		account.ArchivedAt = &now
+	} else if !archived {
+		account.ArchivedAt = nil
+	}
+
+	if err := database.DB.Model(account).Update("archived_at", account.ArchivedAt).Error; err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// DeleteAccount deletes an account without transactions. Accounts with
+// history should be archived instead.
+func DeleteAccount(userID uint, id string) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		var account models.Account
+		if err := tx.Where("id = ? AND user_id = ?", id, userID).First(&account).Error; err != nil {
+			return err
+		}
+
+		for _, model := range []interface{}{&models.Transaction{}, &models.RecurringRule{}} {
+			var count int64
+			if err := tx.Model(model).Where("account_id = ?", account.ID).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				return ErrAccountInUse
+			}
+		}
+
+		return tx.Delete(&account).Error
+	})
+}
+
+// AccountBalance is an account with its current balance in minor units of
+// the account currency. Converted is in the report currency, nil if no rate
+// was available.
+type AccountBalance struct {
+	Account   models.Account
+	Balance   int64
+	Converted *int64
+}
+
+// AccountBalances is the result of GetAccountBalances.
+type AccountBalances struct {
+	Currency     string
+	Total        int64
+	Accounts     []AccountBalance
+	RateSource   string
+	MissingRates []string
+}
+
+// GetAccountBalances computes every account's balance: its opening balance
+// plus income and incoming transfers minus expenses and outgoing transfers,
+// with transactions in other currencies
+// converted at the rate on their date. Balances are then converted to
+// currency at the latest rate and added up.
+func GetAccountBalances(userID uint, currency string, includeArchived bool) (*AccountBalances, error) {
+	accounts, err := ListAccounts(userID, includeArchived)
+	if err != nil {
+		return nil, err
+	}
+	prefs, err := GetPreferences(userID)
+	if err != nil {
+		return nil, err
+	}
+	loc := UserLocation(prefs)
+
+	result := &AccountBalances{Currency: currency, RateSource: RateSource(), MissingRates: []string{}}
+	missing := map[string]bool{}
+
+	currencies := []string{currency}
+	for _, a := range accounts {
+		currencies = append(currencies, a.Currency)
+	}
+	now := Now().In(loc)
+	today := dateOf(now)
+	table, err := loadRateTable(result.RateSource, currencies, today, today)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, account := range accounts {
+		accountID := account.ID
+		scope := func(db *gorm.DB) *gorm.DB {
+			return db.Where("user_id = ? AND account_id = ?", userID, accountID)
+		}
+		totals, conversion, err := SumTransactions(scope, account.Currency, loc)
+		if err != nil {
+			return nil, err
+		}
+		for _, code := range conversion.MissingRates {
+			missing[code] = true
+		}
+
+		balance := AccountBalance{
+			Account: account,
+			Balance: account.OpeningBalanceMinor + totals.Income - totals.Expense + totals.TransferIn - totals.TransferOut,
+		}
+		if rate := table.rate(account.Currency, currency, today); rate != nil {
+			converted := convertMinor(balance.Balance, account.Currency, currency, rate)
+			balance.Converted = &converted
+			result.Total += converted
+		} else {
+			missing[account.Currency] = true
+		}
+		result.Accounts = append(result.Accounts, balance)
+	}
+
+	for code := range missing {
+		result.MissingRates = append(result.MissingRates, code)
+	}
+	sort.Strings(result.MissingRates)
+	return result, nil
+}
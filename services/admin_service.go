@@ -0,0 +1,228 @@
+package services
+
+import (
+	"backend101/config"
+	"backend101/database"
+	"backend101/models"
+	"backend101/money"
+	"context"
+	"errors"
+	"log"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var ErrCannotModifySelf = errors.New("admins cannot disable or demote themselves")
+
+// BootstrapAdmins promotes the users listed in ADMIN_EMAILS so a fresh
+// deployment has someone who can reach the admin API.
+func BootstrapAdmins() error {
+	for _, email := range strings.Split(config.Get("ADMIN_EMAILS"), ",") {
+		email = strings.TrimSpace(email)
+		if email == "" {
+			continue
+		}
+
+		res := database.DB.Model(&models.User{}).
+			Where("email = ? AND role <> ?", email, models.RoleAdmin).
+			Update("role", models.RoleAdmin)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected > 0 {
+			log.Printf("👑 Promoted %s to admin", email)
+		}
+	}
+	return nil
+}
+
+func SearchUsers(q models.AdminUserQuery) (*models.AdminUserList, error) {
+	if q.Page == 0 {
+		q.Page = 1
+	}
+	if q.Limit == 0 {
+		q.Limit = 20
+	}
+
+	db := database.DB.Model(&models.User{})
+	if q.Q != "" {
+		like := "%" + strings.ToLower(q.Q) + "%"
+		db = db.Where("LOWER(name) LIKE ? OR LOWER(email) LIKE ?", like, like)
+	}
+	if q.Role != "" {
+		db = db.Where("role = ?", q.Role)
+	}
+	switch q.Status {
+	case "active":
+		db = db.Where("disabled_at IS NULL")
+	case "disabled":
+		db = db.Where("disabled_at IS NOT NULL")
+	}
+
+	list := &models.AdminUserList{Page: q.Page, Limit: q.Limit, Data: []models.User{}}
+	if err := db.Count(&list.Total).Error; err != nil {
+		return nil, err
+	}
+	err := db.Order("id").Offset((q.Page - 1) * q.Limit).Limit(q.Limit).Find(&list.Data).Error
+	return list, err
+}
+
+func GetUser(userID uint) (*models.User, error) {
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// SetUserDisabled disables or re-enables an account. Disabling signs the user
+// out everywhere.
+func SetUserDisabled(ctx context.Context, actorID, userID uint, disabled bool) error {
+	if disabled && actorID == userID {
+		return ErrCannotModifySelf
+	}
+
+	var value interface{}
+	if disabled {
+		value = Now()
+	}
+
+	res := database.DB.Model(&models.User{}).Where("id = ?", userID).Update("disabled_at", value)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	if disabled {
+		return RevokeAllUserTokens(ctx, userID)
+	}
+	return nil
+}
+
+// SetUserRole changes a role and revokes existing tokens so the new role is
+// in effect immediately rather than at the next refresh.
+func SetUserRole(ctx context.Context, actorID, userID uint, role string) error {
+	if actorID == userID && role != models.RoleAdmin {
+		return ErrCannotModifySelf
+	}
+
+	res := database.DB.Model(&models.User{}).Where("id = ?", userID).Update("role", role)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	return RevokeAllUserTokens(ctx, userID)
+}
+
+// ForcePasswordReset blocks every kind of login until the user resets their
+// password, signs them out and emails them a reset link.
+func ForcePasswordReset(ctx context.Context, userID uint) error {
+	user, err := GetUser(userID)
+	if err != nil {
+		return err
+	}
+
+	if err := database.DB.Model(user).Update("password_reset_required", true).Error; err != nil {
+		return err
+	}
+	if err := RevokeAllUserTokens(ctx, userID); err != nil {
+		return err
+	}
+	return RequestPasswordReset(user.Email)
+}
+
+func GetTransactionStats() (*models.TransactionStats, error) {
+	stats := &models.TransactionStats{ByCategory: []models.CategoryStat{}, ByMonth: []models.MonthStat{}}
+	db := database.DB
+
+	if err := db.Model(&models.User{}).Count(&stats.UserCount).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Model(&models.User{}).Where("disabled_at IS NULL").Count(&stats.ActiveUserCount).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Model(&models.Transaction{}).Count(&stats.TransactionCount).Error; err != nil {
+		return nil, err
+	}
+
+	var totals []struct {
+		Currency     string
+		IncomeTotal  int64
+		ExpenseTotal int64
+	}
+	if err := db.Model(&models.Transaction{}).
+		Select("currency, " +
+			"COALESCE(SUM(CASE WHEN type = 'income' THEN amount_minor END), 0) AS income_total, " +
+			"COALESCE(SUM(CASE WHEN type = 'expense' THEN amount_minor END), 0) AS expense_total").
+		Group("currency").
+		Order("currency").
+		Scan(&totals).Error; err != nil {
+		return nil, err
+	}
+	stats.Totals = make([]models.CurrencyTotal, len(totals))
+	for i, t := range totals {
+		stats.Totals[i] = models.CurrencyTotal{
+			Currency:     t.Currency,
+			IncomeTotal:  money.Format(t.IncomeTotal, t.Currency),
+			ExpenseTotal: money.Format(t.ExpenseTotal, t.Currency),
+		}
+	}
+
+	var categories []struct {
+		Type     string
+		Category string
+		Currency string
+		Count    int64
+		Total    int64
+	}
+	if err := db.Model(&models.Transaction{}).
+		Select("type, category, currency, COUNT(*) AS count, COALESCE(SUM(amount_minor), 0) AS total").
+		Group("type, category, currency").
+		Order("total DESC").
+		Scan(&categories).Error; err != nil {
+		return nil, err
+	}
+	for _, c := range categories {
+		stats.ByCategory = append(stats.ByCategory, models.CategoryStat{
+			Type:     c.Type,
+			Category: c.Category,
+			Currency: c.Currency,
+			Count:    c.Count,
+			Total:    money.Format(c.Total, c.Currency),
+		})
+	}
+
+	var months []struct {
+		Month        string
+		Currency     string
+		Count        int64
+		IncomeTotal  int64
+		ExpenseTotal int64
+	}
+	if err := db.Model(&models.Transaction{}).
+		Select("TO_CHAR(date, 'YYYY-MM') AS month, currency, COUNT(*) AS count, " +
+			"COALESCE(SUM(CASE WHEN type = 'income' THEN amount_minor END), 0) AS income_total, " +
+			"COALESCE(SUM(CASE WHEN type = 'expense' THEN amount_minor END), 0) AS expense_total").
+		Group("month, currency").
+		Order("month, currency").
+		Scan(&months).Error; err != nil {
+		return nil, err
+	}
+	for _, m := range months {
+		stats.ByMonth = append(stats.ByMonth, models.MonthStat{
+			Month:        m.Month,
+			Currency:     m.Currency,
+			Count:        m.Count,
+			IncomeTotal:  money.Format(m.IncomeTotal, m.Currency),
+			ExpenseTotal: money.Format(m.ExpenseTotal, m.Currency),
+		})
+	}
+
+	return stats, nil
+}
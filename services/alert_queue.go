@@ -0,0 +1,138 @@
+package services
+
+import (
+	"backend101/models"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	// Checks run this long after the first change, so a burst of changes by
+	// one user is checked once.
+	alertCheckDelay = 2 * time.Second
+	alertWorkers    = 4
+	// Changed transactions remembered per user for the large-transaction
+	// check. Bulk changes beyond this are not checked one by one.
+	maxPendingTransactions = 100
+)
+
+// alerts runs the checks requested by checkAlertsAsync.
+var alerts = newAlertQueue(alertWorkers, alertCheckDelay, func(userID uint, changed []models.Transaction) {
+	if err := CheckAlerts(userID, changed); err != nil {
+		log.Printf("❌ Failed to check alerts for user %d: %v", userID, err)
+	}
+})
+
+// checkAlertsAsync looks for notifications caused by a change to the user's
+// transactions in the background, so the request does not wait for it. tx
+// is the created or changed transaction, nil after a deletion.
+func checkAlertsAsync(userID uint, tx *models.Transaction) {
+	alerts.add(userID, tx)
+}
+
+type pendingAlerts struct {
+	due     time.Time
+	changed map[uint]models.Transaction
+}
+
+// alertQueue coalesces alert checks per user: requests for a user that is
+// already waiting are merged into one check. A fixed number of workers run
+// the checks in the order they were first requested.
+type alertQueue struct {
+	workers int
+	delay   time.Duration
+	check   func(userID uint, changed []models.Transaction)
+
+	start   sync.Once
+	mu      sync.Mutex
+	pending map[uint]*pendingAlerts
+	order   []uint
+	wake    chan struct{}
+}
+
+func newAlertQueue(workers int, delay time.Duration, check func(uint, []models.Transaction)) *alertQueue {
+	return &alertQueue{
+		workers: workers,
+		delay:   delay,
+		check:   check,
+		pending: map[uint]*pendingAlerts{},
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+func (q *alertQueue) add(userID uint, tx *models.Transaction) {
+	q.start.Do(func() {
+		for i := 0; i < q.workers; i++ {
+			go q.work()
+		}
+	})
+
+	q.mu.Lock()
+	p := q.pending[userID]
+	if p == nil {
+		p = &pendingAlerts{due: time.Now().Add(q.delay), changed: map[uint]models.Transaction{}}
+		q.pending[userID] = p
+		q.order = append(q.order, userID)
+	}
+	if tx != nil {
+		if _, ok := p.changed[tx.ID]; ok || len(p.changed) < maxPendingTransactions {
+			p.changed[tx.ID] = *tx
+		}
+	}
+	q.mu.Unlock()
+
+	q.signal()
+}
+
+func (q *alertQueue) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (q *alertQueue) work() {
+	for {
+		userID, changed, wait := q.next()
+		switch {
+		case wait < 0:
+			<-q.wake
+		case wait > 0:
+			select {
+			case <-q.wake:
+			case <-time.After(wait):
+			}
+		default:
+			q.check(userID, changed)
+		}
+	}
+}
+
+// next takes the oldest check that is due. Otherwise wait is how long until
+// it is due, or negative if the queue is empty.
+func (q *alertQueue) next() (userID uint, changed []models.Transaction, wait time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.order) == 0 {
+		return 0, nil, -1
+	}
+	userID = q.order[0]
+	p := q.pending[userID]
+	if wait := time.Until(p.due); wait > 0 {
+		return 0, nil, wait
+	}
+
+	q.order = q.order[1:]
+	delete(q.pending, userID)
+	if len(q.order) > 0 {
+		q.signal()
+	}
+
+	changed = make([]models.Transaction, 0, len(p.changed))
+	for _, tx := range p.changed {
+		changed = append(changed, tx)
+	}
+	return userID, changed, 0
+}
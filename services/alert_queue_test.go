@@ -0,0 +1,155 @@
+package services
+
+import (
+	"backend101/models"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordedCheck struct {
+	userID  uint
+	changed []uint
+}
+
+// recordingQueue returns a queue that records its checks and a function
+// that waits for n of them.
+func recordingQueue(t *testing.T, workers int, delay time.Duration) (*alertQueue, func(n int) []recordedCheck) {
+	t.Helper()
+
+	var mu sync.Mutex
+	var checks []recordedCheck
+	done := make(chan struct{}, 100)
+
+	q := newAlertQueue(workers, delay, func(userID uint, changed []models.Transaction) {
+		ids := []uint{}
+		for _, tx := range changed {
+			ids = append(ids, tx.ID)
+		}
+		slices.Sort(ids)
+
+		mu.Lock()
+		checks = append(checks, recordedCheck{userID, ids})
+		mu.Unlock()
+		done <- struct{}{}
+	})
+
+	wait := func(n int) []recordedCheck {
+		t.Helper()
+		for i := 0; i < n; i++ {
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatalf("only %d of %d checks ran", i, n)
+			}
+		}
+		// Give extra checks a chance to show up.
+		time.Sleep(3 * delay)
+
+		mu.Lock()
+		defer mu.Unlock()
+		if len(checks) != n {
+			t.Fatalf("%d checks ran, want %d: %+v", len(checks), n, checks)
+		}
+		out := checks
+		checks = nil
+		return out
+	}
+	return q, wait
+}
+
+func TestAlertQueueCoalescesPerUser(t *testing.T) {
+	q, wait := recordingQueue(t, 2, 20*time.Millisecond)
+
+	for _, id := range []uint{3, 1, 2, 1} {
+		q.add(7, &models.Transaction{ID: id, UserID: 7})
+	}
+	q.add(7, nil)
+	q.add(8, nil)
+
+	checks := wait(2)
+	slices.SortFunc(checks, func(a, b recordedCheck) int { return int(a.userID) - int(b.userID) })
+	if checks[0].userID != 7 || !slices.Equal(checks[0].changed, []uint{1, 2, 3}) {
+		t.Errorf("user 7 check = %+v, want one check of transactions 1, 2 and 3", checks[0])
+	}
+	if checks[1].userID != 8 || len(checks[1].changed) != 0 {
+		t.Errorf("user 8 check = %+v, want one check without transactions", checks[1])
+	}
+
+	// A change after the check ran is checked again.
+	q.add(7, &models.Transaction{ID: 4, UserID: 7})
+	checks = wait(1)
+	if !slices.Equal(checks[0].changed, []uint{4}) {
+		t.Errorf("second check = %+v, want transaction 4", checks[0])
+	}
+}
+
+func TestAlertQueueKeepsLatestVersion(t *testing.T) {
+	var got []models.Transaction
+	done := make(chan struct{})
+	q := newAlertQueue(1, 10*time.Millisecond, func(_ uint, changed []models.Transaction) {
+		got = changed
+		close(done)
+	})
+
+	q.add(1, &models.Transaction{ID: 5, AmountMinor: 100})
+	q.add(1, &models.Transaction{ID: 5, AmountMinor: 900})
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("check did not run")
+	}
+	if len(got) != 1 || got[0].AmountMinor != 900 {
+		t.Errorf("changed = %+v, want the last version of transaction 5", got)
+	}
+}
+
+func TestAlertQueueBoundsPendingTransactions(t *testing.T) {
+	q, wait := recordingQueue(t, 1, 20*time.Millisecond)
+
+	for id := uint(1); id <= maxPendingTransactions+50; id++ {
+		q.add(1, &models.Transaction{ID: id})
+	}
+	if checks := wait(1); len(checks[0].changed) != maxPendingTransactions {
+		t.Errorf("%d transactions checked, want %d", len(checks[0].changed), maxPendingTransactions)
+	}
+}
+
+func TestAlertQueueBoundsConcurrency(t *testing.T) {
+	const workers = 2
+
+	var mu sync.Mutex
+	running, peak := 0, 0
+	done := make(chan struct{}, 10)
+	q := newAlertQueue(workers, time.Millisecond, func(uint, []models.Transaction) {
+		mu.Lock()
+		running++
+		peak = max(peak, running)
+		mu.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+
+		mu.Lock()
+		running--
+		mu.Unlock()
+		done <- struct{}{}
+	})
+
+	for user := uint(1); user <= 6; user++ {
+		q.add(user, nil)
+	}
+	for i := 0; i < 6; i++ {
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("only %d of 6 checks ran", i)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if peak > workers {
+		t.Errorf("%d checks ran at once, want at most %d", peak, workers)
+	}
+}
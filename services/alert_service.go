@@ -0,0 +1,278 @@
+package services
+
+import (
+	"backend101/config"
+	"backend101/database"
+	"backend101/models"
+	"backend101/money"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Budgets notify when spending reaches these percentages of the available
+// amount, highest first.
+var budgetThresholds = []int64{100, 80}
+
+// CheckAlerts notifies the user of budgets reaching a threshold, the
+// balance turning negative and any of the changed transactions being
+// unusually large.
+func CheckAlerts(userID uint, changed []models.Transaction) error {
+	for i := range changed {
+		if err := checkLargeTransaction(&changed[i]); err != nil {
+			return err
+		}
+	}
+	if err := checkBudgets(userID); err != nil {
+		return err
+	}
+	return checkBalance(userID)
+}
+
+func checkBudgets(userID uint) error {
+	summary, err := GetBudgetSummary(userID)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range summary {
+		available := p.Available()
+		threshold := crossedThreshold(p.Spent, available)
+		if threshold == 0 {
+			continue
+		}
+
+		title := fmt.Sprintf("%s budget %d%% used", p.Budget.Name, threshold)
+		if threshold >= 100 {
+			title = fmt.Sprintf("%s budget used up", p.Budget.Name)
+		}
+		_, err := notify(models.Notification{
+			UserID: userID,
+			Kind:   models.NotificationBudgetThreshold,
+			Title:  title,
+			Body: fmt.Sprintf("You have spent %s %s of %s %s available from %s to %s.",
+				money.Format(p.Spent, p.Budget.Currency), p.Budget.Currency,
+				money.Format(available, p.Budget.Currency), p.Budget.Currency,
+				p.Period.From.Format(dateOnlyLayout), p.Period.To.AddDate(0, 0, -1).Format(dateOnlyLayout)),
+			ResourceType: "budget",
+			ResourceID:   &p.Budget.ID,
+			Key:          fmt.Sprintf("budget:%d:%s:%d", p.Budget.ID, p.Period.From.Format(dateOnlyLayout), threshold),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// crossedThreshold returns the highest of budgetThresholds that spent has
+// reached, or 0 if none.
+func crossedThreshold(spent, available int64) int64 {
+	if available <= 0 {
+		return 0
+	}
+	for _, threshold := range budgetThresholds {
+		if spent*100 >= available*threshold {
+			return threshold
+		}
+	}
+	return 0
+}
+
+// checkBalance notifies when the all-time balance of GetBalance turns from
+// positive to negative. It notifies again only after the balance has been
+// positive in between. The user's alert state is locked before the balance
+// is summed, so checks for one user run one after another and each sees the
+// transactions committed before it.
+func checkBalance(userID uint) error {
+	prefs, err := GetPreferences(userID)
+	if err != nil {
+		return err
+	}
+	scope := func(db *gorm.DB) *gorm.DB {
+		return db.Where("user_id = ?", userID)
+	}
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		state := models.AlertState{UserID: userID}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&state).Error; err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&state, "user_id = ?", userID).Error; err != nil {
+			return err
+		}
+
+		totals, _, err := sumTransactions(tx, scope, prefs.Currency, UserLocation(prefs))
+		if err != nil {
+			return err
+		}
+		balance := totals.Income - totals.Expense
+
+		switch {
+		case balance < 0 && state.NegativeSince == nil:
+			now := Now()
+			if err := tx.Model(&state).Update("negative_since", now).Error; err != nil {
+				return err
+			}
+			_, err := notify(models.Notification{
+				UserID: userID,
+				Kind:   models.NotificationNegativeBalance,
+				Title:  "Your balance is negative",
+				Body: fmt.Sprintf("Your expenses now exceed your income by %s %s.",
+					money.Format(-balance, prefs.Currency), prefs.Currency),
+				Key: "negative_balance:" + strconv.FormatInt(now.Unix(), 10),
+			})
+			return err
+		case balance >= 0 && state.NegativeSince != nil:
+			return tx.Model(&state).Update("negative_since", nil).Error
+		}
+		return nil
+	})
+}
+
+// checkLargeTransaction notifies when an expense is more than
+// LARGE_TRANSACTION_FACTOR times the average of the user's expenses in the
+// same category and currency over the 90 days before it. Categories with
+// fewer than LARGE_TRANSACTION_MIN_HISTORY such expenses are not checked.
+func checkLargeTransaction(t *models.Transaction) error {
+	factor := int64(config.GetInt("LARGE_TRANSACTION_FACTOR"))
+	if t.Type != "expense" || factor <= 0 {
+		return nil
+	}
+
+	var history struct {
+		Count   int64
+		Average float64
+	}
+	db := database.DB.Model(&models.Transaction{}).
+		Select("COUNT(*) AS count, COALESCE(AVG(amount_minor), 0) AS average").
+		Where("user_id = ? AND type = ? AND currency = ? AND id <> ? AND date >= ? AND date < ?",
+			t.UserID, "expense", t.Currency, t.ID, t.Date.AddDate(0, 0, -90), t.Date)
+	if t.CategoryID != nil {
+		db = db.Where("category_id = ?", *t.CategoryID)
+	}
+	if err := db.Scan(&history).Error; err != nil {
+		return err
+	}
+	if !isLarge(t.AmountMinor, history.Count, history.Average, factor) {
+		return nil
+	}
+
+	_, err := notify(models.Notification{
+		UserID: t.UserID,
+		Kind:   models.NotificationLargeTransaction,
+		Title:  fmt.Sprintf("Unusually large expense: %s %s", money.Format(t.AmountMinor, t.Currency), t.Currency),
+		Body: fmt.Sprintf("%q in %s is %.1f times your average %s expense over the previous 90 days.",
+			t.Description, t.Category, float64(t.AmountMinor)/history.Average, t.Category),
+		ResourceType: "transaction",
+		ResourceID:   &t.ID,
+		Key:          "large_transaction:" + strconv.FormatUint(uint64(t.ID), 10),
+	})
+	return err
+}
+
+// isLarge reports whether amount is more than factor times the average of
+// count earlier expenses, given enough of them to compare with.
+func isLarge(amount, count int64, average float64, factor int64) bool {
+	return count >= int64(config.GetInt("LARGE_TRANSACTION_MIN_HISTORY")) &&
+		float64(amount) > float64(factor)*average
+}
+
+// NotifyUpcomingBills reminds users of recurring expenses due within
+// BILL_REMINDER_DAYS, once per occurrence, and returns how many reminders
+// were sent. Skipped occurrences are left out.
+func NotifyUpcomingBills() (int, error) {
+	days := config.GetInt("BILL_REMINDER_DAYS")
+	if days < 0 {
+		return 0, nil
+	}
+
+	// A day of slack covers users in time zones ahead of UTC; each rule is
+	// checked against its owner's date below.
+	var rules []models.RecurringRule
+	if err := database.DB.
+		Where("type = ? AND next_date IS NOT NULL AND next_date <= ?", "expense", dateOf(Now().UTC()).AddDate(0, 0, days+1)).
+		Find(&rules).Error; err != nil {
+		return 0, err
+	}
+
+	reminded := 0
+	for _, rule := range rules {
+		n, err := notifyUpcomingBill(&rule, days)
+		if err != nil {
+			log.Printf("❌ Failed to remind of recurring rule %d: %v", rule.ID, err)
+			continue
+		}
+		reminded += n
+	}
+	return reminded, nil
+}
+
+func notifyUpcomingBill(rule *models.RecurringRule, days int) (int, error) {
+	prefs, err := GetPreferences(rule.UserID)
+	if err != nil {
+		return 0, err
+	}
+	horizon := today(UserLocation(prefs)).AddDate(0, 0, days)
+
+	_, occurrences, err := UpcomingOccurrences(rule.UserID, strconv.FormatUint(uint64(rule.ID), 10), days+1)
+	if err != nil {
+		return 0, err
+	}
+
+	reminded := 0
+	for _, b := range upcomingBills(rule, occurrences, horizon) {
+		sent, err := notify(models.Notification{
+			UserID: rule.UserID,
+			Kind:   models.NotificationUpcomingBill,
+			Title:  fmt.Sprintf("Upcoming: %s", b.Description),
+			Body: fmt.Sprintf("%s %s for %s is due on %s.",
+				money.Format(b.AmountMinor, rule.Currency), rule.Currency, b.Description, b.Date.Format(dateOnlyLayout)),
+			ResourceType: "recurring_rule",
+			ResourceID:   &rule.ID,
+			Key:          fmt.Sprintf("upcoming_bill:%d:%s", rule.ID, b.Date.Format(dateOnlyLayout)),
+		})
+		if err != nil {
+			return reminded, err
+		}
+		if sent {
+			reminded++
+		}
+	}
+	return reminded, nil
+}
+
+type upcomingBill struct {
+	Date        time.Time
+	AmountMinor int64
+	Description string
+}
+
+// upcomingBills applies the changes to single occurrences up to horizon.
+// Skipped occurrences are left out.
+func upcomingBills(rule *models.RecurringRule, occurrences []Occurrence, horizon time.Time) []upcomingBill {
+	var bills []upcomingBill
+	for _, o := range occurrences {
+		if o.Date.After(horizon) {
+			break
+		}
+		b := upcomingBill{Date: o.Date, AmountMinor: rule.AmountMinor, Description: rule.Description}
+		if o.Override != nil {
+			if o.Override.Skipped {
+				continue
+			}
+			if o.Override.AmountMinor != nil {
+				b.AmountMinor = *o.Override.AmountMinor
+			}
+			if o.Override.Description != nil {
+				b.Description = *o.Override.Description
+			}
+		}
+		bills = append(bills, b)
+	}
+	return bills
+}
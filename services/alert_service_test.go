@@ -0,0 +1,351 @@
+package services
+
+import (
+	"backend101/database"
+	"backend101/dto"
+	"backend101/models"
+	"backend101/money"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestCrossedThreshold(t *testing.T) {
+	tests := []struct {
+		spent, available, want int64
+	}{
+		{0, 10000, 0},
+		{7999, 10000, 0},
+		{8000, 10000, 80},
+		{9999, 10000, 80},
+		{10000, 10000, 100},
+		{25000, 10000, 100},
+		{500, 0, 0},
+		{500, -100, 0},
+	}
+
+	for _, tt := range tests {
+		if got := crossedThreshold(tt.spent, tt.available); got != tt.want {
+			t.Errorf("crossedThreshold(%d, %d) = %d, want %d", tt.spent, tt.available, got, tt.want)
+		}
+	}
+}
+
+func TestIsLarge(t *testing.T) {
+	setConfig(t, "LARGE_TRANSACTION_MIN_HISTORY", 5)
+
+	tests := []struct {
+		name    string
+		amount  int64
+		count   int64
+		average float64
+		want    bool
+	}{
+		{"no history", 100000, 0, 0, false},
+		{"too little history", 100000, 4, 1000, false},
+		{"enough history", 100000, 5, 1000, true},
+		{"exactly the factor", 3000, 5, 1000, false},
+		{"just above the factor", 3001, 5, 1000, true},
+		{"usual amount", 1200, 20, 1000, false},
+	}
+
+	for _, tt := range tests {
+		if got := isLarge(tt.amount, tt.count, tt.average, 3); got != tt.want {
+			t.Errorf("%s: isLarge(%d, %d, %.0f, 3) = %v, want %v", tt.name, tt.amount, tt.count, tt.average, got, tt.want)
+		}
+	}
+}
+
+func TestUpcomingBills(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2026, 10, d, 0, 0, 0, 0, time.UTC) }
+	amount, description := int64(4200), "Phone plan"
+	rule := &models.RecurringRule{AmountMinor: 1500, Description: "Phone"}
+
+	occurrences := []Occurrence{
+		{Date: day(16)},
+		{Date: day(17), Override: &models.RecurringOccurrence{Skipped: true}},
+		{Date: day(18), Override: &models.RecurringOccurrence{AmountMinor: &amount, Description: &description}},
+		{Date: day(19)},
+	}
+
+	got := upcomingBills(rule, occurrences, day(18))
+	want := []upcomingBill{
+		{Date: day(16), AmountMinor: 1500, Description: "Phone"},
+		{Date: day(18), AmountMinor: 4200, Description: "Phone plan"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("upcomingBills() = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("bill %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func createTestUser(t *testing.T, email string) *models.User {
+	t.Helper()
+
+	now := Now()
+	user := models.User{Name: "Test", Email: email, Password: "hash", EmailVerifiedAt: &now}
+	if err := database.DB.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	return &user
+}
+
+func createTestCategory(t *testing.T, userID uint, name string) *models.Category {
+	t.Helper()
+
+	category := models.Category{UserID: userID, Name: name, Type: "expense"}
+	if err := database.DB.Create(&category).Error; err != nil {
+		t.Fatal(err)
+	}
+	return &category
+}
+
+func addTestTransaction(t *testing.T, userID uint, typ string, amount int64, category *models.Category, date time.Time) models.Transaction {
+	t.Helper()
+
+	tx := models.Transaction{UserID: userID, Type: typ, AmountMinor: amount, Currency: "USD", Description: "test", Date: date}
+	if category != nil {
+		tx.CategoryID, tx.Category = &category.ID, category.Name
+	} else {
+		tx.Category = typ
+	}
+	if err := database.DB.Create(&tx).Error; err != nil {
+		t.Fatal(err)
+	}
+	return tx
+}
+
+func notificationsOf(t *testing.T, userID uint, kind string) []models.Notification {
+	t.Helper()
+
+	var out []models.Notification
+	if err := database.DB.Where("user_id = ? AND kind = ?", userID, kind).Order("id").Find(&out).Error; err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestNotifyDeduplicatesByKey(t *testing.T) {
+	useTestDB(t)
+	user := createTestUser(t, "dedup@example.com")
+
+	n := models.Notification{UserID: user.ID, Kind: models.NotificationNegativeBalance, Title: "t", Key: "k"}
+	for i, want := range []bool{true, false, false} {
+		sent, err := notify(n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sent != want {
+			t.Errorf("notify #%d = %v, want %v", i+1, sent, want)
+		}
+	}
+
+	// Keys are per user.
+	other := createTestUser(t, "other@example.com")
+	n.UserID = other.ID
+	if sent, err := notify(n); err != nil || !sent {
+		t.Errorf("notify for another user = %v, %v; want sent", sent, err)
+	}
+
+	if got := notificationsOf(t, user.ID, models.NotificationNegativeBalance); len(got) != 1 {
+		t.Errorf("%d notifications, want 1", len(got))
+	}
+}
+
+func TestCheckBudgetsThresholds(t *testing.T) {
+	useTestDB(t)
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	setClock(t, now)
+	user := createTestUser(t, "budget@example.com")
+	food := createTestCategory(t, user.ID, "Food test")
+
+	budget := models.Budget{
+		UserID: user.ID, Name: "Food", CategoryID: &food.ID, Period: models.BudgetMonthly,
+		AmountMinor: 10000, Currency: "USD", StartDate: time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC),
+	}
+	if err := database.DB.Create(&budget).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		spend  int64
+		titles []string
+	}{
+		{7900, nil},
+		{100, []string{"Food budget 80% used"}},
+		{1000, []string{"Food budget 80% used"}},
+		{1000, []string{"Food budget 80% used", "Food budget used up"}},
+		{5000, []string{"Food budget 80% used", "Food budget used up"}},
+	}
+
+	for i, step := range steps {
+		addTestTransaction(t, user.ID, "expense", step.spend, food, now.AddDate(0, 0, -1))
+		if err := checkBudgets(user.ID); err != nil {
+			t.Fatal(err)
+		}
+
+		got := notificationsOf(t, user.ID, models.NotificationBudgetThreshold)
+		titles := []string{}
+		for _, n := range got {
+			titles = append(titles, n.Title)
+		}
+		if len(titles) != len(step.titles) {
+			t.Fatalf("after step %d: notifications %q, want %q", i+1, titles, step.titles)
+		}
+		for j := range titles {
+			if titles[j] != step.titles[j] {
+				t.Errorf("after step %d: notifications %q, want %q", i+1, titles, step.titles)
+			}
+		}
+	}
+}
+
+func TestCheckBalanceNegativeFlip(t *testing.T) {
+	useTestDB(t)
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	advance := setClock(t, now)
+	user := createTestUser(t, "balance@example.com")
+
+	steps := []struct {
+		typ      string
+		amount   int64
+		negative bool
+		notified int
+	}{
+		{"income", 10000, false, 0},
+		{"expense", 15000, true, 1}, // turns negative
+		{"expense", 1000, true, 1},  // still negative: no new alert
+		{"income", 20000, false, 1}, // positive again: reset
+		{"expense", 20000, true, 2}, // negative again
+		{"income", 6000, false, 2},  // exactly zero counts as not negative
+		{"expense", 1, true, 3},
+	}
+
+	for i, step := range steps {
+		advance(now.Add(time.Duration(i) * time.Minute))
+		addTestTransaction(t, user.ID, step.typ, step.amount, nil, now.AddDate(0, 0, -1))
+		if err := checkBalance(user.ID); err != nil {
+			t.Fatal(err)
+		}
+
+		var state models.AlertState
+		if err := database.DB.First(&state, "user_id = ?", user.ID).Error; err != nil {
+			t.Fatal(err)
+		}
+		if (state.NegativeSince != nil) != step.negative {
+			t.Errorf("after step %d: negative_since = %v, want negative %v", i+1, state.NegativeSince, step.negative)
+		}
+		if got := notificationsOf(t, user.ID, models.NotificationNegativeBalance); len(got) != step.notified {
+			t.Errorf("after step %d: %d alerts, want %d", i+1, len(got), step.notified)
+		}
+	}
+}
+
+func TestCheckLargeTransactionMinHistory(t *testing.T) {
+	useTestDB(t)
+	setConfig(t, "LARGE_TRANSACTION_FACTOR", 3)
+	setConfig(t, "LARGE_TRANSACTION_MIN_HISTORY", 5)
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	setClock(t, now)
+	user := createTestUser(t, "large@example.com")
+	fuel := createTestCategory(t, user.ID, "Fuel test")
+	other := createTestCategory(t, user.ID, "Other test")
+
+	// Four earlier expenses of 10.00 in the category, one in another
+	// category and one older than 90 days.
+	for i := 1; i <= 4; i++ {
+		addTestTransaction(t, user.ID, "expense", 1000, fuel, now.AddDate(0, 0, -i))
+	}
+	addTestTransaction(t, user.ID, "expense", 1000, other, now.AddDate(0, 0, -1))
+	addTestTransaction(t, user.ID, "expense", 1000, fuel, now.AddDate(0, 0, -91))
+
+	large := addTestTransaction(t, user.ID, "expense", 5000, fuel, now)
+	if err := checkLargeTransaction(&large); err != nil {
+		t.Fatal(err)
+	}
+	if got := notificationsOf(t, user.ID, models.NotificationLargeTransaction); len(got) != 0 {
+		t.Fatalf("alerted with 4 expenses of history: %+v", got)
+	}
+
+	// The fifth comparable expense makes the history long enough.
+	addTestTransaction(t, user.ID, "expense", 1000, fuel, now.AddDate(0, 0, -5))
+	for i := 0; i < 2; i++ {
+		if err := checkLargeTransaction(&large); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got := notificationsOf(t, user.ID, models.NotificationLargeTransaction)
+	if len(got) != 1 || got[0].ResourceID == nil || *got[0].ResourceID != large.ID {
+		t.Fatalf("alerts = %+v, want one for transaction %d", got, large.ID)
+	}
+
+	// Income is never checked.
+	income := addTestTransaction(t, user.ID, "income", 500000, fuel, now)
+	if err := checkLargeTransaction(&income); err != nil {
+		t.Fatal(err)
+	}
+	if got := notificationsOf(t, user.ID, models.NotificationLargeTransaction); len(got) != 1 {
+		t.Errorf("%d alerts after an income, want 1", len(got))
+	}
+}
+
+func TestNotifyUpcomingBillsSkipsOccurrences(t *testing.T) {
+	useTestDB(t)
+	setConfig(t, "BILL_REMINDER_DAYS", 3)
+	setClock(t, time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC))
+	user := createTestUser(t, "bills@example.com")
+
+	rule, err := CreateRecurringRule(user.ID, dto.CreateRecurringInput{
+		Amount:      money.Decimal("15.00"),
+		Type:        "expense",
+		Category:    "Phone",
+		Description: "Phone",
+		Frequency:   "daily",
+		StartDate:   "2026-10-16",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := strconv.FormatUint(uint64(rule.ID), 10)
+
+	skip := true
+	if _, _, err := UpdateOccurrence(user.ID, id, "2026-10-17", dto.UpdateOccurrenceInput{Skip: &skip}); err != nil {
+		t.Fatal(err)
+	}
+	amount := money.Decimal("42.00")
+	if _, _, err := UpdateOccurrence(user.ID, id, "2026-10-18", dto.UpdateOccurrenceInput{Amount: &amount}); err != nil {
+		t.Fatal(err)
+	}
+
+	sent, err := NotifyUpcomingBills()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The 16th and the 18th; the 17th is skipped.
+	if sent != 2 {
+		t.Errorf("NotifyUpcomingBills() = %d, want 2", sent)
+	}
+
+	got := notificationsOf(t, user.ID, models.NotificationUpcomingBill)
+	bodies := map[string]bool{}
+	for _, n := range got {
+		bodies[n.Body] = true
+	}
+	for _, want := range []string{
+		"15.00 USD for Phone is due on 2026-10-16.",
+		"42.00 USD for Phone is due on 2026-10-18.",
+	} {
+		if !bodies[want] {
+			t.Errorf("no reminder %q in %+v", want, got)
+		}
+	}
+
+	// Reminders are sent once per occurrence.
+	if sent, err := NotifyUpcomingBills(); err != nil || sent != 0 {
+		t.Errorf("second NotifyUpcomingBills() = %d, %v; want 0", sent, err)
+	}
+}
@@ -0,0 +1,131 @@
+package services
+
+import (
+	"backend101/database"
+	"backend101/models"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	apiKeyPrefix = "bk_"
+	// "bk_" plus 8 random characters; the secret follows after another "_".
+	apiKeyPrefixLen = len(apiKeyPrefix) + 8
+	// LastUsedAt is only written when older than this, to avoid a database
+	// write on every request.
+	apiKeyTouchInterval = time.Minute
+)
+
+var (
+	ErrInvalidAPIKey = errors.New("invalid api key")
+	ErrInvalidScope  = errors.New("invalid scope")
+)
+
+// IsAPIKey reports whether a credential looks like one of our API keys.
+func IsAPIKey(raw string) bool {
+	return strings.HasPrefix(raw, apiKeyPrefix)
+}
+
+func CreateAPIKey(userID uint, req models.CreateAPIKeyRequest) (*models.CreateAPIKeyResponse, error) {
+	scopes := models.Scopes{}
+	for _, scope := range req.Scopes {
+		if !models.Scopes(models.APIKeyScopes).Has(scope) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidScope, scope)
+		}
+		if !scopes.Has(scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	b := make([]byte, 5)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	prefix := apiKeyPrefix + recoveryCodeAlphabet.EncodeToString(b)
+
+	secret, err := generateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	raw := prefix + "_" + secret
+
+	key := models.APIKey{
+		UserID:    userID,
+		Name:      req.Name,
+		Prefix:    prefix,
+		KeyHash:   hashToken(raw),
+		Scopes:    scopes,
+		ExpiresAt: req.ExpiresAt,
+	}
+	if err := database.DB.Create(&key).Error; err != nil {
+		return nil, err
+	}
+
+	return &models.CreateAPIKeyResponse{APIKey: key, Key: raw}, nil
+}
+
+func ListAPIKeys(userID uint) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	err := database.DB.Where("user_id = ? AND revoked_at IS NULL", userID).Order("created_at DESC").Find(&keys).Error
+	return keys, err
+}
+
+func RevokeAPIKey(userID, keyID uint) error {
+	res := database.DB.Model(&models.APIKey{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", keyID, userID).
+		Update("revoked_at", Now())
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// AuthenticateAPIKey resolves a raw key to an active APIKey and its owner.
+func AuthenticateAPIKey(_ context.Context, raw string) (*models.APIKey, *models.User, error) {
+	if !IsAPIKey(raw) || len(raw) <= apiKeyPrefixLen+1 || raw[apiKeyPrefixLen] != '_' {
+		return nil, nil, ErrInvalidAPIKey
+	}
+
+	var key models.APIKey
+	err := database.DB.Where("prefix = ?", raw[:apiKeyPrefixLen]).First(&key).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashToken(raw)), []byte(key.KeyHash)) != 1 {
+		return nil, nil, ErrInvalidAPIKey
+	}
+	now := Now()
+	if key.RevokedAt != nil || (key.ExpiresAt != nil && !now.Before(*key.ExpiresAt)) {
+		return nil, nil, ErrInvalidAPIKey
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, key.UserID).Error; err != nil {
+		return nil, nil, err
+	}
+	if user.DisabledAt != nil {
+		return nil, nil, ErrInvalidAPIKey
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= apiKeyTouchInterval {
+		if err := database.DB.Model(&key).Update("last_used_at", now).Error; err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return &key, &user, nil
+}
@@ -0,0 +1,35 @@
+package services
+
+import (
+	"backend101/database"
+	"backend101/models"
+	"encoding/json"
+	"log"
+)
+
+// RecordAuditEvent stores an audit event. Failures are logged rather than
+// returned so auditing never breaks the request that triggered it.
+func RecordAuditEvent(event string, userID *uint, ip string, details map[string]interface{}) {
+	data, err := json.Marshal(details)
+	if err != nil {
+		data = []byte("{}")
+	}
+
+	log.Printf("🔐 audit %s user=%v ip=%s %s", event, derefUint(userID), ip, data)
+
+	if err := database.DB.Create(&models.AuditEvent{
+		UserID:  userID,
+		Event:   event,
+		IP:      ip,
+		Details: string(data),
+	}).Error; err != nil {
+		log.Printf("❌ Failed to store audit event %s: %v", event, err)
+	}
+}
+
+func derefUint(v *uint) interface{} {
+	if v == nil {
+		return nil
+	}
+	return *v
+}
@@ -1,12 +1,35 @@
 package services
 
-import "golang.org/x/crypto/bcrypt"
+import (
+	"backend101/models"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const bcryptCost = 14
 
 func HashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost)
 	return string(bytes), err
 }
 
+var dummyPasswordHash = sync.OnceValue(func() string {
+	hash, _ := HashPassword("dummy password for constant-time login")
+	return hash
+})
+
+// VerifyUserPassword always performs a full bcrypt comparison, even when
+// there is no user or the user has no password, so that response times do
+// not reveal which emails are registered.
+func VerifyUserPassword(user *models.User, password string) bool {
+	if user == nil || user.Password == "" {
+		CheckPasswordHash(password, dummyPasswordHash())
+		return false
+	}
+	return CheckPasswordHash(password, user.Password)
+}
+
 func CheckPasswordHash(password, hash string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 	return err == nil
@@ -0,0 +1,348 @@
+package services
+
+import (
+	"backend101/database"
+	"backend101/dto"
+	"backend101/models"
+	"backend101/money"
+	"backend101/utils"
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrBudgetDates = errors.New("end_date is before start_date")
+
+// budgetPeriods maps a budget's period to the period names of CurrentPeriod.
+var budgetPeriods = map[string]string{
+	models.BudgetWeekly:  PeriodWeek,
+	models.BudgetMonthly: PeriodMonth,
+	models.BudgetYearly:  PeriodYear,
+}
+
+func validateBudget(budget *models.Budget) error {
+	if fields := utils.ValidateStruct(budget); fields != nil {
+		return &ValidationError{Fields: fields}
+	}
+	if budget.EndDate != nil && budget.EndDate.Before(budget.StartDate) {
+		return ErrBudgetDates
+	}
+	return nil
+}
+
+// CreateBudget stores a budget for an expense category or a tag. Its name
+// defaults to the category's or tag's.
+func CreateBudget(userID uint, input dto.CreateBudgetInput) (*models.Budget, error) {
+	prefs, err := GetPreferences(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	budget := models.Budget{
+		UserID:     userID,
+		CategoryID: input.CategoryID,
+		TagID:      input.TagID,
+		Period:     input.Period,
+		Currency:   prefs.Currency,
+		Rollover:   input.Rollover,
+		StartDate:  today(UserLocation(prefs)),
+	}
+	if input.Currency != nil {
+		budget.Currency = strings.ToUpper(*input.Currency)
+	}
+	if budget.AmountMinor, err = money.Parse(input.Amount.String(), budget.Currency); err != nil {
+		return nil, err
+	}
+
+	if input.CategoryID != nil {
+		category, err := GetCategory(userID, *input.CategoryID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCategoryNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		if category.Type != "expense" {
+			return nil, ErrCategoryTypeMismatch
+		}
+		budget.Name = category.Name
+	} else {
+		tag, err := GetTag(userID, *input.TagID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTagNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		budget.Name = tag.Name
+	}
+	if input.Name != nil {
+		budget.Name = strings.TrimSpace(*input.Name)
+	}
+
+	if input.StartDate != nil {
+		if budget.StartDate, err = parseDate(*input.StartDate); err != nil {
+			return nil, err
+		}
+	}
+	if input.EndDate != nil {
+		end, err := parseDate(*input.EndDate)
+		if err != nil {
+			return nil, err
+		}
+		budget.EndDate = &end
+	}
+
+	if err := validateBudget(&budget); err != nil {
+		return nil, err
+	}
+	if err := database.DB.Create(&budget).Error; err != nil {
+		return nil, err
+	}
+	return &budget, nil
+}
+
+func ListBudgets(userID uint) ([]models.Budget, error) {
+	budgets := []models.Budget{}
+	err := database.DB.Where("user_id = ?", userID).Order("name, id").Find(&budgets).Error
+	return budgets, err
+}
+
+func GetBudget(userID uint, id interface{}) (*models.Budget, error) {
+	var budget models.Budget
+	if err := database.DB.Where("id = ? AND user_id = ?", id, userID).First(&budget).Error; err != nil {
+		return nil, err
+	}
+	return &budget, nil
+}
+
+// UpdateBudget changes the fields that are set in input. A new amount also
+// applies to earlier periods when working out what rolls over.
+func UpdateBudget(userID uint, id string, input dto.UpdateBudgetInput) (*models.Budget, error) {
+	budget, err := GetBudget(userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if input.Name != nil {
+		budget.Name = strings.TrimSpace(*input.Name)
+	}
+	if input.Amount != nil {
+		if budget.AmountMinor, err = money.Parse(input.Amount.String(), budget.Currency); err != nil {
+			return nil, err
+		}
+	}
+	if input.Rollover != nil {
+		budget.Rollover = *input.Rollover
+	}
+	if input.EndDate != nil {
+		budget.EndDate = nil
+		if *input.EndDate != "" {
+			end, err := parseDate(*input.EndDate)
+			if err != nil {
+				return nil, err
+			}
+			budget.EndDate = &end
+		}
+	}
+
+	if err := validateBudget(budget); err != nil {
+		return nil, err
+	}
+	if err := database.DB.Save(budget).Error; err != nil {
+		return nil, err
+	}
+	return budget, nil
+}
+
+func DeleteBudget(userID uint, id string) error {
+	res := database.DB.Where("id = ? AND user_id = ?", id, userID).Delete(&models.Budget{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// BudgetProgress is the spending against a budget in one period, in minor
+// units of the budget's currency.
+type BudgetProgress struct {
+	Budget models.Budget
+	Period *Period
+	// Left over from earlier periods; zero without rollover.
+	RolledOver int64
+	Spent      int64
+	// Spending by the end of the period if it continues at the pace so far.
+	Projected  int64
+	Conversion *Conversion
+}
+
+func (p *BudgetProgress) Available() int64 {
+	return p.Budget.AmountMinor + p.RolledOver
+}
+
+func (p *BudgetProgress) Remaining() int64 {
+	return p.Available() - p.Spent
+}
+
+func (p *BudgetProgress) Status() string {
+	switch {
+	case p.Spent > p.Available():
+		return dto.BudgetOver
+	case p.Projected > p.Available():
+		return dto.BudgetAtRisk
+	default:
+		return dto.BudgetOnTrack
+	}
+}
+
+// GetBudgetProgress reports the budget's current period, or the period
+// containing date if it is not empty.
+func GetBudgetProgress(userID uint, id, date string) (*BudgetProgress, error) {
+	budget, err := GetBudget(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	prefs, err := GetPreferences(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	at := Now()
+	if date != "" {
+		d, err := parseDate(date)
+		if err != nil {
+			return nil, err
+		}
+		at = inLocation(d, UserLocation(prefs))
+	}
+	return budgetProgress(budget, prefs, at)
+}
+
+// GetBudgetSummary reports the current period of every budget that covers
+// it.
+func GetBudgetSummary(userID uint) ([]BudgetProgress, error) {
+	budgets, err := ListBudgets(userID)
+	if err != nil {
+		return nil, err
+	}
+	prefs, err := GetPreferences(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := []BudgetProgress{}
+	for i := range budgets {
+		active, err := budgetActive(&budgets[i], prefs, Now())
+		if err != nil {
+			return nil, err
+		}
+		if !active {
+			continue
+		}
+		progress, err := budgetProgress(&budgets[i], prefs, Now())
+		if err != nil {
+			return nil, err
+		}
+		summary = append(summary, *progress)
+	}
+	return summary, nil
+}
+
+// budgetActive reports whether the period containing at lies between the
+// budget's first and last period.
+func budgetActive(budget *models.Budget, prefs *models.UserPreference, at time.Time) (bool, error) {
+	loc := UserLocation(prefs)
+	period, err := CurrentPeriod(budgetPeriods[budget.Period], prefs, at)
+	if err != nil {
+		return false, err
+	}
+	if !inLocation(budget.StartDate, loc).Before(period.To) {
+		return false, nil
+	}
+	return budget.EndDate == nil || !inLocation(*budget.EndDate, loc).Before(period.From), nil
+}
+
+// budgetScope selects the budget's expenses between from and to.
+func budgetScope(budget *models.Budget, from, to time.Time) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		db = db.Where("transactions.user_id = ? AND transactions.type = ? AND transactions.date >= ? AND transactions.date < ?",
+			budget.UserID, "expense", from, to)
+		if budget.TagID != nil {
+			return db.Joins("JOIN transaction_tags tt ON tt.transaction_id = transactions.id AND tt.tag_id = ?", *budget.TagID)
+		}
+		return db.Where("transactions.category_id IN (?)", database.DB.Model(&models.Category{}).Select("id").
+			Where("user_id = ? AND (id = ? OR parent_id = ?)", budget.UserID, *budget.CategoryID, *budget.CategoryID))
+	}
+}
+
+// budgetProgress works out the period containing at. With rollover, the
+// spending of every period since the budget started is summed by day in one
+// query, and what is left of each period is carried into the next.
+func budgetProgress(budget *models.Budget, prefs *models.UserPreference, at time.Time) (*BudgetProgress, error) {
+	loc := UserLocation(prefs)
+	name := budgetPeriods[budget.Period]
+	current, err := CurrentPeriod(name, prefs, at)
+	if err != nil {
+		return nil, err
+	}
+	first := current
+	if budget.Rollover {
+		start, err := CurrentPeriod(name, prefs, inLocation(budget.StartDate, loc))
+		if err != nil {
+			return nil, err
+		}
+		if start.From.Before(current.From) {
+			first = start
+		}
+	}
+
+	// Days since the first period began.
+	day := "((transactions.date AT TIME ZONE ?)::date - CAST(? AS date))"
+	byDay, conversion, err := sumTransactionsBy(database.DB, budgetScope(budget, first.From, current.To), day, budget.Currency, loc,
+		loc.String(), first.From.Format(dateOnlyLayout))
+	if err != nil {
+		return nil, err
+	}
+	spent := func(from, to time.Time) int64 {
+		var total int64
+		for offset, totals := range byDay {
+			d := first.From.AddDate(0, 0, int(offset))
+			if !d.Before(from) && d.Before(to) {
+				total += totals.Expense
+			}
+		}
+		return total
+	}
+
+	progress := &BudgetProgress{Budget: *budget, Period: current, Conversion: conversion}
+	for p := first; p.From.Before(current.From); {
+		progress.RolledOver = max(0, progress.Available()-spent(p.From, p.To))
+		if p, err = CurrentPeriod(name, prefs, p.To); err != nil {
+			return nil, err
+		}
+	}
+	progress.Spent = spent(current.From, current.To)
+
+	// Spending so far continues at its daily rate; transactions dated later
+	// in the period are counted as they are.
+	now := Now().In(loc)
+	tomorrow := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, loc)
+	progress.Projected = progress.Spent
+	if tomorrow.After(current.From) && tomorrow.Before(current.To) {
+		elapsed, total := calendarDays(current.From, tomorrow), calendarDays(current.From, current.To)
+		sofar := spent(current.From, tomorrow)
+		progress.Projected = sofar*int64(total)/int64(elapsed) + progress.Spent - sofar
+	}
+	return progress, nil
+}
+
+// calendarDays is the number of days from from to to, both midnight in the
+// same location.
+func calendarDays(from, to time.Time) int {
+	return int(dateOf(to).Sub(dateOf(from)).Hours() / 24)
+}
@@ -0,0 +1,328 @@
+package services
+
+import (
+	"backend101/database"
+	"backend101/dto"
+	"backend101/models"
+	"backend101/utils"
+	"errors"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrCategoryNotFound     = errors.New("category not found")
+	ErrCategoryExists       = errors.New("a category with this name already exists")
+	ErrCategoryDepth        = errors.New("categories can only be nested one level deep")
+	ErrCategoryTypeMismatch = errors.New("category type does not match")
+	ErrCategoryInUse        = errors.New("category has transactions or children")
+	ErrMergeIntoSelf        = errors.New("cannot merge a category into itself or its child")
+)
+
+// SeedDefaultCategories gives a new user the default categories.
+func SeedDefaultCategories(tx *gorm.DB, userID uint) error {
+	for _, d := range models.DefaultCategories {
+		parent := models.Category{UserID: userID, Name: d.Name, Type: d.Type, Color: d.Color, Icon: d.Icon}
+		if err := tx.Create(&parent).Error; err != nil {
+			return err
+		}
+		for _, name := range d.Children {
+			child := models.Category{UserID: userID, ParentID: &parent.ID, Name: name, Type: d.Type, Color: d.Color, Icon: d.Icon}
+			if err := tx.Create(&child).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ListCategories returns the user's categories, optionally of one type.
+func ListCategories(userID uint, typ string) ([]models.Category, error) {
+	db := database.DB.Where("user_id = ?", userID)
+	if typ != "" {
+		db = db.Where("type = ?", typ)
+	}
+
+	categories := []models.Category{}
+	err := db.Order("type, name, id").Find(&categories).Error
+	return categories, err
+}
+
+func GetCategory(userID uint, id interface{}) (*models.Category, error) {
+	return getCategory(database.DB, userID, id)
+}
+
+func getCategory(tx *gorm.DB, userID uint, id interface{}) (*models.Category, error) {
+	var category models.Category
+	if err := tx.Where("id = ? AND user_id = ?", id, userID).First(&category).Error; err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
+
+// checkParent makes sure category can be nested under parentID.
+func checkParent(tx *gorm.DB, category *models.Category, parentID uint) error {
+	if parentID == category.ID {
+		return ErrCategoryDepth
+	}
+	parent, err := getCategory(tx, category.UserID, parentID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrCategoryNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if parent.ParentID != nil {
+		return ErrCategoryDepth
+	}
+	if parent.Type != category.Type {
+		return ErrCategoryTypeMismatch
+	}
+
+	if category.ID != 0 {
+		var children int64
+		if err := tx.Model(&models.Category{}).Where("parent_id = ?", category.ID).Count(&children).Error; err != nil {
+			return err
+		}
+		if children > 0 {
+			return ErrCategoryDepth
+		}
+	}
+	return nil
+}
+
+// saveCategory maps a violation of the unique name index to
+// ErrCategoryExists.
+func saveCategory(tx *gorm.DB, category *models.Category) error {
+	err := tx.Save(category).Error
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return ErrCategoryExists
+	}
+	return err
+}
+
+func CreateCategory(userID uint, input dto.CreateCategoryInput) (*models.Category, error) {
+	category := models.Category{
+		UserID:   userID,
+		ParentID: input.ParentID,
+		Name:     strings.TrimSpace(input.Name),
+		Type:     input.Type,
+		Color:    strings.ToUpper(input.Color),
+		Icon:     input.Icon,
+	}
+	if err := validateCategory(&category); err != nil {
+		return nil, err
+	}
+	if category.ParentID != nil {
+		if err := checkParent(database.DB, &category, *category.ParentID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := saveCategory(database.DB, &category); err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
+
+func validateCategory(category *models.Category) error {
+	if fields := utils.ValidateStruct(category); fields != nil {
+		return &ValidationError{Fields: fields}
+	}
+	return nil
+}
+
+// UpdateCategory applies the fields that are set in input. Renaming a
+// category renames it on its transactions too.
+func UpdateCategory(userID uint, id string, input dto.UpdateCategoryInput) (*models.Category, error) {
+	var category *models.Category
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if category, err = getCategory(tx, userID, id); err != nil {
+			return err
+		}
+
+		if input.Name != nil {
+			category.Name = strings.TrimSpace(*input.Name)
+		}
+		if input.Color != nil {
+			category.Color = strings.ToUpper(*input.Color)
+		}
+		if input.Icon != nil {
+			category.Icon = *input.Icon
+		}
+		if input.ParentID != nil {
+			if *input.ParentID == 0 {
+				category.ParentID = nil
+			} else {
+				if err := checkParent(tx, category, *input.ParentID); err != nil {
+					return err
+				}
+				category.ParentID = input.ParentID
+			}
+		}
+
+		if err := validateCategory(category); err != nil {
+			return err
+		}
+		if err := saveCategory(tx, category); err != nil {
+			return err
+		}
+		for _, model := range categorizedModels {
+			if err := tx.Model(model).
+				Where("category_id = ?", category.ID).
+				Update("category", category.Name).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return category, nil
+}
+
+// categorizedModels are the tables that keep a category's ID and name.
+var categorizedModels = []interface{}{&models.Transaction{}, &models.RecurringRule{}}
+
+// DeleteCategory deletes a category that has no transactions, recurring
+// transactions, budgets or children. Others have to be merged into another category instead.
+func DeleteCategory(userID uint, id string) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		category, err := getCategory(tx, userID, id)
+		if err != nil {
+			return err
+		}
+
+		var count int64
+		for _, model := range append(categorizedModels, &models.Budget{}) {
+			if count == 0 {
+				if err := tx.Model(model).Where("category_id = ?", category.ID).Count(&count).Error; err != nil {
+					return err
+				}
+			}
+		}
+		if count == 0 {
+			if err := tx.Model(&models.Category{}).Where("parent_id = ?", category.ID).Count(&count).Error; err != nil {
+				return err
+			}
+		}
+		if count > 0 {
+			return ErrCategoryInUse
+		}
+
+		return tx.Delete(category).Error
+	})
+}
+
+// MergeCategories moves the transactions and budgets of category id to
+// category intoID, re-parents its children under intoID's top-level category
+// and deletes it.
+func MergeCategories(userID uint, id string, intoID uint) (*models.Category, error) {
+	var into *models.Category
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		source, err := getCategory(tx, userID, id)
+		if err != nil {
+			return err
+		}
+		into, err = getCategory(tx, userID, intoID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrCategoryNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		if into.ID == source.ID || (into.ParentID != nil && *into.ParentID == source.ID) {
+			return ErrMergeIntoSelf
+		}
+		if into.Type != source.Type {
+			return ErrCategoryTypeMismatch
+		}
+
+		for _, model := range categorizedModels {
+			if err := tx.Model(model).
+				Where("category_id = ?", source.ID).
+				Updates(map[string]interface{}{"category_id": into.ID, "category": into.Name}).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Model(&models.Budget{}).
+			Where("category_id = ?", source.ID).
+			Update("category_id", into.ID).Error; err != nil {
+			return err
+		}
+
+		parentID := into.ID
+		if into.ParentID != nil {
+			parentID = *into.ParentID
+		}
+		err = tx.Model(&models.Category{}).Where("parent_id = ?", source.ID).Update("parent_id", parentID).Error
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return ErrCategoryExists
+		}
+		if err != nil {
+			return err
+		}
+
+		return tx.Delete(source).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return into, nil
+}
+
+// resolveTransactionCategory finds the category for a transaction of type
+// typ, by ID or else by name. Names match case-insensitively, ignoring
+// surrounding spaces; an unknown name creates a top-level category, so
+// clients that send free-text categories keep working.
+func resolveTransactionCategory(tx *gorm.DB, userID uint, id *uint, name, typ string) (*models.Category, error) {
+	if id != nil {
+		category, err := getCategory(tx, userID, *id)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCategoryNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		if category.Type != typ {
+			return nil, ErrCategoryTypeMismatch
+		}
+		return category, nil
+	}
+
+	name = strings.TrimSpace(name)
+	find := func() (*models.Category, error) {
+		var category models.Category
+		err := tx.Where("user_id = ? AND type = ? AND LOWER(name) = LOWER(?)", userID, typ, name).
+			Order("parent_id NULLS FIRST, id").
+			First(&category).Error
+		return &category, err
+	}
+
+	category, err := find()
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return category, err
+	}
+
+	category = &models.Category{UserID: userID, Name: name, Type: typ}
+	if fields := utils.ValidateStruct(category); fields != nil {
+		return nil, &ValidationError{Fields: map[string]string{"Category": fields["Name"]}}
+	}
+	// Another request may have just created it; use theirs. The savepoint
+	// keeps the surrounding transaction usable after the conflict.
+	err = tx.Transaction(func(tx *gorm.DB) error {
+		return tx.Create(category).Error
+	})
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return find()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return category, nil
+}
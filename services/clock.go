@@ -0,0 +1,7 @@
+package services
+
+import "time"
+
+// Now is the clock used throughout the services package. Tests can swap it
+// for a fixed or stepping clock.
+var Now = time.Now
@@ -0,0 +1,48 @@
+package services
+
+import (
+	"errors"
+	"time"
+)
+
+const dateOnlyLayout = "2006-01-02"
+
+var ErrInvalidDate = errors.New("invalid date: use RFC 3339 or YYYY-MM-DD")
+
+// ParseUserDate parses an RFC 3339 timestamp or a YYYY-MM-DD date. A plain
+// date means midnight in loc, and dateOnly is true.
+func ParseUserDate(s string, loc *time.Location) (t time.Time, dateOnly bool, err error) {
+	if t, err := time.ParseInLocation(dateOnlyLayout, s, loc); err == nil {
+		return t, true, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, false, nil
+	}
+	return time.Time{}, false, ErrInvalidDate
+}
+
+// untilCondition turns an inclusive `to` filter into a condition on the date
+// column: a plain date covers that whole day in loc, a timestamp includes
+// the instant itself.
+func untilCondition(s string, loc *time.Location) (query string, t time.Time, err error) {
+	t, dateOnly, err := ParseUserDate(s, loc)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if dateOnly {
+		return "date < ?", t.AddDate(0, 0, 1), nil
+	}
+	return "date <= ?", t, nil
+}
+
+// dateOf returns t's calendar date as midnight UTC, the form DATE columns
+// are read back in.
+func dateOf(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// inLocation returns midnight in loc of the calendar date d, which is
+// midnight UTC as read from a DATE column.
+func inLocation(d time.Time, loc *time.Location) time.Time {
+	return time.Date(d.Year(), d.Month(), d.Day(), 0, 0, 0, 0, loc)
+}
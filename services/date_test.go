@@ -0,0 +1,38 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestUntilCondition(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip(err)
+	}
+
+	tests := []struct {
+		to    string
+		query string
+		t     time.Time
+	}{
+		{"2026-10-18", "date < ?", time.Date(2026, 10, 19, 0, 0, 0, 0, berlin)},
+		{"2026-10-18T12:30:00Z", "date <= ?", time.Date(2026, 10, 18, 12, 30, 0, 0, time.UTC)},
+		{"2026-10-18T12:30:00+02:00", "date <= ?", time.Date(2026, 10, 18, 10, 30, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		query, got, err := untilCondition(tt.to, berlin)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.to, err)
+		}
+		if query != tt.query || !got.Equal(tt.t) {
+			t.Errorf("untilCondition(%s) = %q, %s; want %q, %s", tt.to, query, got, tt.query, tt.t)
+		}
+	}
+
+	if _, _, err := untilCondition("18/10/2026", berlin); !errors.Is(err, ErrInvalidDate) {
+		t.Errorf("error = %v, want ErrInvalidDate", err)
+	}
+}
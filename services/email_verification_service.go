@@ -0,0 +1,76 @@
+package services
+
+import (
+	"backend101/config"
+	"backend101/database"
+	"backend101/mailer"
+	"backend101/models"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func emailVerificationTTL() time.Duration {
+	return time.Hour * time.Duration(config.GetInt("EMAIL_VERIFICATION_EXPIRE_HOURS"))
+}
+
+func SendVerificationEmail(user *models.User) error {
+	token, err := IssueUserToken(user.ID, models.TokenPurposeEmailVerification, emailVerificationTTL())
+	if err != nil {
+		return err
+	}
+
+	sendMailAsync(mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"Please confirm your email address by opening the link below:\n\n"+
+			"%s\n\n"+
+			"If you did not create an account you can ignore this email.\n",
+			user.Name, appLink("/verify-email", token)),
+	})
+	return nil
+}
+
+// ResendVerificationEmail sends a new link to an unverified address. Unknown
+// and already verified addresses are ignored so the endpoint cannot be used
+// to probe for accounts.
+func ResendVerificationEmail(email string) error {
+	var user models.User
+	err := database.DB.Where("email = ?", email).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if user.EmailVerifiedAt != nil {
+		return nil
+	}
+
+	return SendVerificationEmail(&user)
+}
+
+func VerifyEmail(rawToken string) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		token, err := consumeUserToken(tx, rawToken, models.TokenPurposeEmailVerification)
+		if err != nil {
+			return err
+		}
+
+		return tx.Model(&models.User{}).
+			Where("id = ? AND email_verified_at IS NULL", token.UserID).
+			Update("email_verified_at", Now()).Error
+	})
+}
+
+// IsEmailVerified reports whether the user's current address is verified.
+func IsEmailVerified(userID uint) (bool, error) {
+	var user models.User
+	if err := database.DB.Select("id", "email_verified_at").First(&user, userID).Error; err != nil {
+		return false, err
+	}
+	return user.EmailVerifiedAt != nil, nil
+}
@@ -0,0 +1,181 @@
+package services
+
+import (
+	"backend101/models"
+	"context"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+var ErrInvalidRates = errors.New("invalid exchange rate data")
+
+// RateProvider supplies exchange rates. Imported rates are stored under the
+// provider's name, which is what responses report as the rate source.
+type RateProvider interface {
+	Name() string
+	Rates(ctx context.Context) ([]models.ExchangeRate, error)
+}
+
+// RateOpener opens the raw data a provider parses.
+type RateOpener func(ctx context.Context) (io.ReadCloser, error)
+
+// OpenRateLocation opens an http(s) URL or a local file.
+func OpenRateLocation(location string) RateOpener {
+	return func(ctx context.Context) (io.ReadCloser, error) {
+		if !strings.HasPrefix(location, "http://") && !strings.HasPrefix(location, "https://") {
+			return os.Open(location)
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("fetching %s: %s", location, resp.Status)
+		}
+		return resp.Body, nil
+	}
+}
+
+// NewRateProvider returns the provider for a format: "ecb" or "csv".
+func NewRateProvider(format, name string, open RateOpener) (RateProvider, error) {
+	switch format {
+	case "ecb":
+		if name == "" {
+			name = "ecb"
+		}
+		return &ECBRateProvider{name: name, open: open}, nil
+	case "csv":
+		if name == "" {
+			name = "csv"
+		}
+		return &CSVRateProvider{name: name, open: open}, nil
+	}
+	return nil, fmt.Errorf("unknown exchange rate format %q", format)
+}
+
+// ECBRateProvider reads the European Central Bank's euro reference rates
+// (eurofxref-daily.xml or eurofxref-hist.xml). All rates have base EUR.
+type ECBRateProvider struct {
+	name string
+	open RateOpener
+}
+
+func (p *ECBRateProvider) Name() string { return p.name }
+
+func (p *ECBRateProvider) Rates(ctx context.Context) ([]models.ExchangeRate, error) {
+	r, err := p.open(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	// Elements are matched by local name, so the gesmes and eurofxref
+	// namespaces need no special handling.
+	var doc struct {
+		Days []struct {
+			Time  string `xml:"time,attr"`
+			Rates []struct {
+				Currency string `xml:"currency,attr"`
+				Rate     string `xml:"rate,attr"`
+			} `xml:"Cube"`
+		} `xml:"Cube>Cube"`
+	}
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRates, err)
+	}
+
+	var rates []models.ExchangeRate
+	for _, day := range doc.Days {
+		for _, rate := range day.Rates {
+			parsed, err := newExchangeRate(p.name, day.Time, "EUR", rate.Currency, rate.Rate)
+			if err != nil {
+				return nil, err
+			}
+			rates = append(rates, *parsed)
+		}
+	}
+	return rates, nil
+}
+
+// CSVRateProvider reads rows of date,base,quote,rate with a header line,
+// e.g. "2025-05-16,USD,KES,129.25".
+type CSVRateProvider struct {
+	name string
+	open RateOpener
+}
+
+func (p *CSVRateProvider) Name() string { return p.name }
+
+func (p *CSVRateProvider) Rates(ctx context.Context) ([]models.ExchangeRate, error) {
+	r, err := p.open(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 4
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRates, err)
+	}
+	if strings.ToLower(strings.Join(header, ",")) != "date,base,quote,rate" {
+		return nil, fmt.Errorf("%w: header must be date,base,quote,rate", ErrInvalidRates)
+	}
+
+	var rates []models.ExchangeRate
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidRates, err)
+		}
+		rate, err := newExchangeRate(p.name, record[0], record[1], record[2], record[3])
+		if err != nil {
+			return nil, err
+		}
+		rates = append(rates, *rate)
+	}
+	return rates, nil
+}
+
+func newExchangeRate(source, date, base, quote, rate string) (*models.ExchangeRate, error) {
+	day, err := time.Parse(dateOnlyLayout, strings.TrimSpace(date))
+	if err != nil {
+		return nil, fmt.Errorf("%w: bad date %q", ErrInvalidRates, date)
+	}
+	base, quote = strings.ToUpper(strings.TrimSpace(base)), strings.ToUpper(strings.TrimSpace(quote))
+	if len(base) != 3 || len(quote) != 3 || base == quote {
+		return nil, fmt.Errorf("%w: bad currency pair %s/%s", ErrInvalidRates, base, quote)
+	}
+	value, ok := new(big.Rat).SetString(strings.TrimSpace(rate))
+	if !ok || value.Sign() <= 0 {
+		return nil, fmt.Errorf("%w: bad rate %q", ErrInvalidRates, rate)
+	}
+
+	return &models.ExchangeRate{
+		Source: source,
+		Base:   base,
+		Quote:  quote,
+		Date:   day,
+		Rate:   value.FloatString(12),
+	}, nil
+}
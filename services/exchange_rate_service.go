@@ -0,0 +1,335 @@
+package services
+
+import (
+	"backend101/config"
+	"backend101/database"
+	"backend101/models"
+	"backend101/money"
+	"context"
+	"math/big"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const rateImportBatchSize = 500
+
+// RateSource is the provider whose rates are used for conversions.
+func RateSource() string {
+	return config.Get("FX_RATE_SOURCE")
+}
+
+// maxRateAge is how old the latest rate may be, e.g. across weekends and
+// bank holidays when no rates are published.
+func maxRateAge() int {
+	return config.GetInt("FX_MAX_RATE_AGE_DAYS")
+}
+
+// ImportRates stores every rate from p, replacing existing rates for the
+// same source, pair and day. If p lists a rate more than once, the last one
+// wins.
+func ImportRates(ctx context.Context, p RateProvider) (int, error) {
+	rates, err := p.Rates(ctx)
+	if err != nil {
+		return 0, err
+	}
+	rates = dedupeRates(rates)
+	if len(rates) == 0 {
+		return 0, nil
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "source"}, {Name: "base"}, {Name: "quote"}, {Name: "date"}},
+			DoUpdates: clause.AssignmentColumns([]string{"rate"}),
+		}).CreateInBatches(&rates, rateImportBatchSize).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(rates), nil
+}
+
+// dedupeRates keeps the last of the rates for the same source, pair and day,
+// in the position of the first. Postgres refuses to update the same row
+// twice in one INSERT ... ON CONFLICT.
+func dedupeRates(rates []models.ExchangeRate) []models.ExchangeRate {
+	type key struct{ source, base, quote, date string }
+	index := make(map[key]int, len(rates))
+	out := make([]models.ExchangeRate, 0, len(rates))
+	for _, r := range rates {
+		k := key{r.Source, r.Base, r.Quote, r.Date.Format(dateOnlyLayout)}
+		if i, ok := index[k]; ok {
+			out[i] = r
+			continue
+		}
+		index[k] = len(out)
+		out = append(out, r)
+	}
+	return out
+}
+
+type ratePoint struct {
+	day  time.Time
+	rate *big.Rat
+}
+
+// rateTable holds the rates of one source needed for a conversion, per
+// base/quote pair and sorted by day.
+type rateTable struct {
+	pairs map[[2]string][]ratePoint
+	// Bases to try for cross rates: the source's own base (the one most
+	// rates are quoted against, e.g. EUR for the ECB) first, then by code,
+	// so the same request always picks the same rate.
+	bases []string
+}
+
+func loadRateTable(source string, currencies []string, from, to time.Time) (*rateTable, error) {
+	var rows []models.ExchangeRate
+	err := database.DB.
+		Where("source = ? AND date BETWEEN ? AND ?", source, from.AddDate(0, 0, -maxRateAge()), to).
+		Where("base IN ? OR quote IN ?", currencies, currencies).
+		Order("date").
+		Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	return newRateTable(rows), nil
+}
+
+// newRateTable builds a table from rows sorted by date.
+func newRateTable(rows []models.ExchangeRate) *rateTable {
+	table := &rateTable{pairs: map[[2]string][]ratePoint{}}
+	counts := map[string]int{}
+	for _, row := range rows {
+		rate, ok := new(big.Rat).SetString(row.Rate)
+		if !ok {
+			continue
+		}
+		key := [2]string{row.Base, row.Quote}
+		table.pairs[key] = append(table.pairs[key], ratePoint{day: row.Date, rate: rate})
+		if counts[row.Base] == 0 {
+			table.bases = append(table.bases, row.Base)
+		}
+		counts[row.Base]++
+	}
+	sort.Slice(table.bases, func(i, j int) bool {
+		a, b := table.bases[i], table.bases[j]
+		if counts[a] != counts[b] {
+			return counts[a] > counts[b]
+		}
+		return a < b
+	})
+	return table
+}
+
+// at returns the latest base/quote rate on or before day.
+func (t *rateTable) at(base, quote string, day time.Time) *big.Rat {
+	points := t.pairs[[2]string{base, quote}]
+	i := sort.Search(len(points), func(i int) bool { return points[i].day.After(day) })
+	if i == 0 {
+		return nil
+	}
+	p := points[i-1]
+	if day.Sub(p.day) > time.Duration(maxRateAge())*24*time.Hour {
+		return nil
+	}
+	return p.rate
+}
+
+// rate returns how many units of to one unit of from was worth on day,
+// using a direct, inverse or cross rate (e.g. USD→KES through EUR).
+func (t *rateTable) rate(from, to string, day time.Time) *big.Rat {
+	if from == to {
+		return big.NewRat(1, 1)
+	}
+	if r := t.at(from, to, day); r != nil {
+		return r
+	}
+	if r := t.at(to, from, day); r != nil {
+		return new(big.Rat).Inv(r)
+	}
+	for _, base := range t.bases {
+		fromRate, toRate := t.at(base, from, day), t.at(base, to, day)
+		if base == from {
+			fromRate = big.NewRat(1, 1)
+		}
+		if base == to {
+			toRate = big.NewRat(1, 1)
+		}
+		if fromRate != nil && toRate != nil {
+			return new(big.Rat).Quo(toRate, fromRate)
+		}
+	}
+	return nil
+}
+
+// convertMinor converts minor units of from into minor units of to at rate,
+// rounding half away from zero.
+func convertMinor(amount int64, from, to string, rate *big.Rat) int64 {
+	v := new(big.Rat).SetInt64(amount)
+	v.Mul(v, rate)
+	v.Mul(v, new(big.Rat).SetFrac(pow10(money.Exponent(to)), pow10(money.Exponent(from))))
+
+	num, den := v.Num(), v.Denom()
+	q, r := new(big.Int).QuoRem(num, den, new(big.Int))
+	// Round half away from zero: compare 2|r| with the denominator.
+	if r.Abs(r).Lsh(r, 1).Cmp(den) >= 0 {
+		if num.Sign() < 0 {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	return q.Int64()
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// Conversion describes how amounts in other currencies were converted.
+type Conversion struct {
+	Currency   string `json:"currency" example:"EUR"`
+	RateSource string `json:"rate_source" example:"ecb"`
+	// Rates are the latest published on or before each transaction's date.
+	RatePolicy string `json:"rate_policy" example:"transaction_date"`
+	// Number of transactions that were converted.
+	Converted int64 `json:"converted"`
+	// Currencies without a usable rate. Their transactions are left out of
+	// the totals, not counted at some other rate, so totals are incomplete
+	// while this is not empty.
+	MissingRates []string `json:"missing_rates"`
+}
+
+// Totals are income and expense sums in minor units of one currency.
+// Transfer legs are summed separately since they are neither.
+type Totals struct {
+	Income      int64
+	Expense     int64
+	TransferIn  int64
+	TransferOut int64
+}
+
+// SumTransactions totals the transactions selected by scope in currency.
+// Transactions in other currencies are converted at the rate on their date,
+// where the date is taken in loc.
+func SumTransactions(scope func(*gorm.DB) *gorm.DB, currency string, loc *time.Location) (*Totals, *Conversion, error) {
+	return sumTransactions(database.DB, scope, currency, loc)
+}
+
+// sumTransactions is SumTransactions on db, so it can run inside a
+// transaction.
+func sumTransactions(db *gorm.DB, scope func(*gorm.DB) *gorm.DB, currency string, loc *time.Location) (*Totals, *Conversion, error) {
+	totals, conversion, err := sumTransactionsBy(db, scope, "0", currency, loc)
+	if err != nil {
+		return nil, nil, err
+	}
+	if totals[0] == nil {
+		return &Totals{}, conversion, nil
+	}
+	return totals[0], conversion, nil
+}
+
+// sumTransactionsBy is SumTransactions with separate totals for each value
+// of the integer SQL expression key, whose ? placeholders are bound to
+// keyArgs.
+func sumTransactionsBy(db *gorm.DB, scope func(*gorm.DB) *gorm.DB, key, currency string, loc *time.Location, keyArgs ...interface{}) (map[uint]*Totals, *Conversion, error) {
+	var groups []struct {
+		GroupKey uint
+		Currency string
+		Type     string
+		Day      *time.Time
+		Count    int64
+		Total    int64
+	}
+	// Rows already in currency are summed in one group; others per day so
+	// each day can use its own rate.
+	err := db.Model(&models.Transaction{}).Scopes(scope).
+		Select(key+" AS group_key, currency, type, "+
+			"CASE WHEN currency = ? THEN NULL ELSE (date AT TIME ZONE ?)::date END AS day, "+
+			"COUNT(*) AS count, COALESCE(SUM(amount_minor), 0) AS total", append(keyArgs, currency, loc.String())...).
+		Group("group_key, currency, type, day").
+		Scan(&groups).Error
+	if err != nil {
+		return nil, nil, err
+	}
+
+	conversion := &Conversion{Currency: currency, RateSource: RateSource(), RatePolicy: "transaction_date", MissingRates: []string{}}
+	byKey := map[uint]*Totals{}
+
+	var currencies []string
+	var first, last time.Time
+	for _, g := range groups {
+		if g.Day == nil {
+			continue
+		}
+		currencies = append(currencies, g.Currency)
+		if first.IsZero() || g.Day.Before(first) {
+			first = *g.Day
+		}
+		if g.Day.After(last) {
+			last = *g.Day
+		}
+	}
+
+	var table *rateTable
+	if len(currencies) > 0 {
+		if table, err = loadRateTable(conversion.RateSource, append(currencies, currency), first, last); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	missing := map[string]bool{}
+	for _, g := range groups {
+		amount := g.Total
+		if g.Day != nil {
+			rate := table.rate(g.Currency, currency, *g.Day)
+			if rate == nil {
+				missing[g.Currency] = true
+				continue
+			}
+			amount = convertMinor(g.Total, g.Currency, currency, rate)
+			conversion.Converted += g.Count
+		}
+
+		totals := byKey[g.GroupKey]
+		if totals == nil {
+			totals = &Totals{}
+			byKey[g.GroupKey] = totals
+		}
+		switch g.Type {
+		case "income":
+			totals.Income += amount
+		case "expense":
+			totals.Expense += amount
+		case models.TransactionTransferIn:
+			totals.TransferIn += amount
+		case models.TransactionTransferOut:
+			totals.TransferOut += amount
+		}
+	}
+
+	for code := range missing {
+		conversion.MissingRates = append(conversion.MissingRates, code)
+	}
+	sort.Strings(conversion.MissingRates)
+	return byKey, conversion, nil
+}
+
+// ListExchangeRates returns the rates of a source for a day, or for the
+// latest day with rates if day is zero.
+func ListExchangeRates(source string, day time.Time) ([]models.ExchangeRate, error) {
+	db := database.DB.Where("source = ?", source)
+	if day.IsZero() {
+		db = db.Where("date = (?)", database.DB.Model(&models.ExchangeRate{}).Select("MAX(date)").Where("source = ?", source))
+	} else {
+		db = db.Where("date = ?", day)
+	}
+
+	rates := []models.ExchangeRate{}
+	err := db.Order("base, quote").Find(&rates).Error
+	return rates, err
+}
@@ -0,0 +1,89 @@
+package services
+
+import (
+	"backend101/models"
+	"testing"
+	"time"
+)
+
+func TestDedupeRates(t *testing.T) {
+	day := time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)
+	rate := func(source, quote string, date time.Time, value string) models.ExchangeRate {
+		return models.ExchangeRate{Source: source, Base: "EUR", Quote: quote, Date: date, Rate: value}
+	}
+
+	got := dedupeRates([]models.ExchangeRate{
+		rate("ecb", "USD", day, "1.10"),
+		rate("ecb", "GBP", day, "0.85"),
+		rate("ecb", "USD", day, "1.11"),
+		rate("ecb", "USD", day.AddDate(0, 0, 1), "1.12"),
+		rate("csv", "USD", day, "1.09"),
+		rate("ecb", "USD", day.Add(3*time.Hour), "1.13"), // same day
+	})
+
+	want := []models.ExchangeRate{
+		rate("ecb", "USD", day.Add(3*time.Hour), "1.13"),
+		rate("ecb", "GBP", day, "0.85"),
+		rate("ecb", "USD", day.AddDate(0, 0, 1), "1.12"),
+		rate("csv", "USD", day, "1.09"),
+	}
+	if len(got) != len(want) {
+		t.Fatalf("dedupeRates() = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i].Source != want[i].Source || got[i].Quote != want[i].Quote || !got[i].Date.Equal(want[i].Date) || got[i].Rate != want[i].Rate {
+			t.Errorf("rate %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestRateTableCrossRateIsDeterministic(t *testing.T) {
+	day := time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)
+	rate := func(base, quote, value string) models.ExchangeRate {
+		return models.ExchangeRate{Source: "test", Base: base, Quote: quote, Date: day, Rate: value}
+	}
+
+	// EUR is the source's own base. AUD and CHF also bridge GBP→JPY and
+	// SEK→NOK, at slightly different rates.
+	table := newRateTable([]models.ExchangeRate{
+		rate("AUD", "GBP", "0.5"),
+		rate("AUD", "JPY", "90"),
+		rate("AUD", "SEK", "7"),
+		rate("AUD", "NOK", "7.7"),
+		rate("CHF", "SEK", "12"),
+		rate("CHF", "NOK", "12.6"),
+		rate("CHF", "USD", "1.25"),
+		rate("EUR", "GBP", "0.8"),
+		rate("EUR", "JPY", "160"),
+		rate("EUR", "USD", "1.1"),
+		rate("EUR", "CHF", "0.9"),
+		rate("EUR", "KES", "140"),
+	})
+
+	tests := []struct {
+		from, to string
+		want     string
+	}{
+		{"EUR", "USD", "11/10"},   // direct
+		{"USD", "EUR", "10/11"},   // inverse
+		{"GBP", "JPY", "200"},     // through EUR, not AUD (180)
+		{"USD", "KES", "1400/11"}, // through EUR
+		{"SEK", "NOK", "11/10"},   // AUD and CHF tie; AUD sorts first, CHF would give 21/20
+		{"KES", "XYZ", ""},
+	}
+
+	for _, tt := range tests {
+		for i := 0; i < 20; i++ {
+			got := table.rate(tt.from, tt.to, day)
+			if tt.want == "" {
+				if got != nil {
+					t.Fatalf("rate(%s, %s) = %s, want none", tt.from, tt.to, got.RatString())
+				}
+				continue
+			}
+			if got == nil || got.RatString() != tt.want {
+				t.Fatalf("rate(%s, %s) = %v, want %s", tt.from, tt.to, got, tt.want)
+			}
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package services
+
+import (
+	"archive/zip"
+	"backend101/database"
+	"backend101/dto"
+	"backend101/models"
+	"backend101/money"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const exportBatchSize = 500
+
+// WriteUserExport writes a ZIP archive with everything stored about the user,
+// as returned by GetProfile, to w. Transactions are read in batches so large
+// histories are streamed rather than loaded at once.
+func WriteUserExport(w io.Writer, user *models.User) error {
+	userID := user.ID
+	archive := zip.NewWriter(w)
+
+	if err := writeJSONFile(archive, "profile.json", user); err != nil {
+		return err
+	}
+
+	var identities []models.UserIdentity
+	if err := database.DB.Where("user_id = ?", userID).Find(&identities).Error; err != nil {
+		return err
+	}
+	if err := writeJSONFile(archive, "identities.json", identities); err != nil {
+		return err
+	}
+
+	accounts, err := ListAccounts(userID, true)
+	if err != nil {
+		return err
+	}
+	if err := writeJSONFile(archive, "accounts.json", dto.NewAccountResponses(accounts, dto.LatestAPIVersion)); err != nil {
+		return err
+	}
+
+	categories, err := ListCategories(userID, "")
+	if err != nil {
+		return err
+	}
+	if err := writeJSONFile(archive, "categories.json", categories); err != nil {
+		return err
+	}
+
+	tags, err := ListTags(userID)
+	if err != nil {
+		return err
+	}
+	if err := writeJSONFile(archive, "tags.json", tags); err != nil {
+		return err
+	}
+
+	transfers, err := ListTransfers(userID, dto.TransferQuery{})
+	if err != nil {
+		return err
+	}
+	if err := writeJSONFile(archive, "transfers.json", dto.NewTransferResponses(transfers, dto.LatestAPIVersion)); err != nil {
+		return err
+	}
+
+	channels, err := GetNotificationPreferences(userID)
+	if err != nil {
+		return err
+	}
+	if err := writeJSONFile(archive, "notification_channels.json", dto.NewChannelResponses(channels)); err != nil {
+		return err
+	}
+
+	var notifications []models.Notification
+	if err := database.DB.Where("user_id = ?", userID).Order("id").Find(&notifications).Error; err != nil {
+		return err
+	}
+	if err := writeJSONFile(archive, "notifications.json", notifications); err != nil {
+		return err
+	}
+
+	budgets, err := ListBudgets(userID)
+	if err != nil {
+		return err
+	}
+	if err := writeJSONFile(archive, "budgets.json", dto.NewBudgetResponses(budgets, dto.LatestAPIVersion)); err != nil {
+		return err
+	}
+
+	rules, err := ListRecurringRules(userID)
+	if err != nil {
+		return err
+	}
+	if err := writeJSONFile(archive, "recurring.json", dto.NewRecurringResponses(rules, dto.LatestAPIVersion)); err != nil {
+		return err
+	}
+
+	var occurrences []models.RecurringOccurrence
+	if err := database.DB.Where("user_id = ?", userID).Order("rule_id, date").Find(&occurrences).Error; err != nil {
+		return err
+	}
+	if err := writeJSONFile(archive, "recurring_occurrences.json", occurrences); err != nil {
+		return err
+	}
+
+	if err := writeTransactionsJSON(archive, userID); err != nil {
+		return err
+	}
+	if err := writeTransactionsCSV(archive, userID); err != nil {
+		return err
+	}
+
+	return archive.Close()
+}
+
+func writeJSONFile(archive *zip.Writer, name string, v interface{}) error {
+	f, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// eachTransactionBatch calls fn with the user's transactions in ID order.
+func eachTransactionBatch(userID uint, fn func([]models.Transaction) error) error {
+	var batch []models.Transaction
+	return database.DB.Preload("Tags").Where("user_id = ?", userID).Order("id").
+		FindInBatches(&batch, exportBatchSize, func(tx *gorm.DB, _ int) error {
+			return fn(batch)
+		}).Error
+}
+
+func writeTransactionsJSON(archive *zip.Writer, userID uint) error {
+	f, err := archive.Create("transactions.json")
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.WriteString(f, "["); err != nil {
+		return err
+	}
+	first := true
+	err = eachTransactionBatch(userID, func(batch []models.Transaction) error {
+		for _, t := range batch {
+			data, err := json.Marshal(dto.NewTransactionResponse(t, dto.LatestAPIVersion))
+			if err != nil {
+				return err
+			}
+			if !first {
+				if _, err := io.WriteString(f, ","); err != nil {
+					return err
+				}
+			}
+			first = false
+			if _, err := io.WriteString(f, "\n  "); err != nil {
+				return err
+			}
+			if _, err := f.Write(data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(f, "\n]\n")
+	return err
+}
+
+func idColumn(id *uint) string {
+	if id == nil {
+		return ""
+	}
+	return strconv.FormatUint(uint64(*id), 10)
+}
+
+func tagsColumn(tags []models.Tag) string {
+	names := make([]string, len(tags))
+	for i, t := range tags {
+		names[i] = t.Name
+	}
+	return textColumn(strings.Join(names, ";"))
+}
+
+// textColumn prefixes user-entered text that a spreadsheet would run as a
+// formula with a quote, so opening the export cannot execute it.
+func textColumn(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+func writeTransactionsCSV(archive *zip.Writer, userID uint) error {
+	f, err := archive.Create("transactions.csv")
+	if err != nil {
+		return err
+	}
+
+	w := csv.NewWriter(f)
+	if err := w.Write([]string{"id", "date", "type", "category", "category_id", "description", "amount", "currency", "account_id", "transfer_id", "recurring_rule_id", "tags", "created_at", "updated_at"}); err != nil {
+		return err
+	}
+	err = eachTransactionBatch(userID, func(batch []models.Transaction) error {
+		for _, t := range batch {
+			if err := w.Write([]string{
+				strconv.FormatUint(uint64(t.ID), 10),
+				t.Date.Format(time.RFC3339),
+				t.Type,
+				textColumn(t.Category),
+				idColumn(t.CategoryID),
+				textColumn(t.Description),
+				money.Format(t.AmountMinor, t.Currency),
+				t.Currency,
+				idColumn(t.AccountID),
+				idColumn(t.TransferID),
+				idColumn(t.RecurringRuleID),
+				tagsColumn(t.Tags),
+				t.CreatedAt.Format(time.RFC3339),
+				t.UpdatedAt.Format(time.RFC3339),
+			}); err != nil {
+				return err
+			}
+		}
+		w.Flush()
+		return w.Error()
+	})
+	if err != nil {
+		return err
+	}
+	w.Flush()
+	return w.Error()
+}
@@ -0,0 +1,33 @@
+package services
+
+import (
+	"backend101/models"
+	"testing"
+)
+
+func TestTextColumn(t *testing.T) {
+	tests := map[string]string{
+		"":                         "",
+		"Groceries":                "Groceries",
+		"=HYPERLINK(\"http://x\")": "'=HYPERLINK(\"http://x\")",
+		"+1+1":                     "'+1+1",
+		"-2+3":                     "'-2+3",
+		"@SUM(A1:A2)":              "'@SUM(A1:A2)",
+		"\tcmd":                    "'\tcmd",
+		"\rcmd":                    "'\rcmd",
+		"a=b":                      "a=b",
+	}
+
+	for in, want := range tests {
+		if got := textColumn(in); got != want {
+			t.Errorf("textColumn(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestTagsColumnIsEscaped(t *testing.T) {
+	tags := []models.Tag{{Name: "=cmd"}, {Name: "trip"}}
+	if got := tagsColumn(tags); got != "'=cmd;trip" {
+		t.Errorf("tagsColumn() = %q, want %q", got, "'=cmd;trip")
+	}
+}
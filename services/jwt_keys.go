@@ -0,0 +1,314 @@
+package services
+
+import (
+	"backend101/config"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const defaultJWTSecret = "supersecret"
+
+// JWK is a public key in JSON Web Key format (RFC 7517).
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+type verificationKey struct {
+	method jwt.SigningMethod
+	key    interface{}
+}
+
+// jwtKeySet holds the key new tokens are signed with and every key that is
+// still accepted for verification. During a rotation the previous public keys
+// stay in verify until all tokens signed with them have expired.
+type jwtKeySet struct {
+	kid     string
+	method  jwt.SigningMethod
+	signKey interface{}
+	verify  map[string]verificationKey
+	public  []JWK
+}
+
+var (
+	jwtKeysMu sync.Mutex
+	jwtKeys   *jwtKeySet
+)
+
+// LoadSigningKeys reads the signing configuration. It is called once at
+// startup so that a broken key file stops the server instead of failing every
+// login.
+func LoadSigningKeys() error {
+	ks, err := loadKeySet()
+	if err != nil {
+		return err
+	}
+
+	jwtKeysMu.Lock()
+	jwtKeys = ks
+	jwtKeysMu.Unlock()
+	return nil
+}
+
+func currentKeySet() (*jwtKeySet, error) {
+	jwtKeysMu.Lock()
+	defer jwtKeysMu.Unlock()
+
+	if jwtKeys == nil {
+		ks, err := loadKeySet()
+		if err != nil {
+			return nil, err
+		}
+		jwtKeys = ks
+	}
+	return jwtKeys, nil
+}
+
+// PublicJWKS returns the asymmetric verification keys. It is empty when
+// tokens are signed with a shared HMAC secret.
+func PublicJWKS() (*JWKSet, error) {
+	ks, err := currentKeySet()
+	if err != nil {
+		return nil, err
+	}
+	return &JWKSet{Keys: append([]JWK{}, ks.public...)}, nil
+}
+
+func loadKeySet() (*jwtKeySet, error) {
+	method := strings.ToUpper(config.Get("JWT_SIGNING_METHOD"))
+
+	switch method {
+	case "", "HS256":
+		secret := config.Get("JWT_SECRET")
+		if secret == "" {
+			return nil, errors.New("JWT_SECRET must be set for HS256")
+		}
+		if secret == defaultJWTSecret && config.Get("ENV") == "production" {
+			return nil, errors.New("refusing to use the default JWT_SECRET in production")
+		}
+		kid := config.Get("JWT_KEY_ID")
+		return &jwtKeySet{
+			kid:     kid,
+			method:  jwt.SigningMethodHS256,
+			signKey: []byte(secret),
+			verify: map[string]verificationKey{
+				kid: {method: jwt.SigningMethodHS256, key: []byte(secret)},
+			},
+		}, nil
+
+	case "RS256", "EDDSA":
+		return loadAsymmetricKeySet(method)
+
+	default:
+		return nil, fmt.Errorf("unsupported JWT_SIGNING_METHOD %q", method)
+	}
+}
+
+func loadAsymmetricKeySet(method string) (*jwtKeySet, error) {
+	path := config.Get("JWT_PRIVATE_KEY_FILE")
+	if path == "" {
+		return nil, fmt.Errorf("JWT_PRIVATE_KEY_FILE is required for %s", method)
+	}
+
+	signKey, err := readPrivateKey(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var public crypto.PublicKey
+	switch k := signKey.(type) {
+	case *rsa.PrivateKey:
+		if method != "RS256" {
+			return nil, fmt.Errorf("%s is an RSA key but JWT_SIGNING_METHOD is %s", path, method)
+		}
+		public = &k.PublicKey
+	case ed25519.PrivateKey:
+		if method != "EDDSA" {
+			return nil, fmt.Errorf("%s is an Ed25519 key but JWT_SIGNING_METHOD is %s", path, method)
+		}
+		public = k.Public()
+	}
+
+	ks := &jwtKeySet{verify: map[string]verificationKey{}}
+
+	kid, err := addVerificationKey(ks, config.Get("JWT_KEY_ID"), public)
+	if err != nil {
+		return nil, err
+	}
+	ks.kid = kid
+	ks.method = ks.verify[kid].method
+	ks.signKey = signKey
+
+	// Previous keys, as "kid=path" or just "path", kept for verification only.
+	for _, entry := range strings.Split(config.Get("JWT_VERIFY_KEYS"), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		kid, path := "", entry
+		if i := strings.Index(entry, "="); i >= 0 {
+			kid, path = entry[:i], entry[i+1:]
+		}
+
+		pub, err := readPublicKey(path)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := addVerificationKey(ks, kid, pub); err != nil {
+			return nil, err
+		}
+	}
+
+	return ks, nil
+}
+
+func addVerificationKey(ks *jwtKeySet, kid string, pub crypto.PublicKey) (string, error) {
+	var (
+		method jwt.SigningMethod
+		jwk    JWK
+	)
+
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		if k.N.BitLen() < 2048 {
+			return "", errors.New("RSA keys must be at least 2048 bits")
+		}
+		method = jwt.SigningMethodRS256
+		jwk = JWK{
+			Kty: "RSA",
+			Alg: "RS256",
+			N:   base64.RawURLEncoding.EncodeToString(k.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes()),
+		}
+	case ed25519.PublicKey:
+		method = jwt.SigningMethodEdDSA
+		jwk = JWK{
+			Kty: "OKP",
+			Alg: "EdDSA",
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(k),
+		}
+	default:
+		return "", fmt.Errorf("unsupported public key type %T", pub)
+	}
+
+	if kid == "" {
+		kid = jwkThumbprint(jwk)
+	}
+	if _, exists := ks.verify[kid]; exists {
+		return "", fmt.Errorf("duplicate JWT key id %q", kid)
+	}
+
+	jwk.Kid = kid
+	jwk.Use = "sig"
+	ks.verify[kid] = verificationKey{method: method, key: pub}
+	ks.public = append(ks.public, jwk)
+	return kid, nil
+}
+
+// jwkThumbprint computes the RFC 7638 thumbprint, used as the default kid.
+func jwkThumbprint(k JWK) string {
+	var members []byte
+	if k.Kty == "RSA" {
+		members, _ = json.Marshal(struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{k.E, k.Kty, k.N})
+	} else {
+		members, _ = json.Marshal(struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{k.Crv, k.Kty, k.X})
+	}
+	sum := sha256.Sum256(members)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM data found", path)
+	}
+	return block, nil
+}
+
+func readPrivateKey(path string) (crypto.Signer, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		switch k := key.(type) {
+		case *rsa.PrivateKey:
+			return k, nil
+		case ed25519.PrivateKey:
+			return k, nil
+		}
+		return nil, fmt.Errorf("%s: unsupported private key type %T", path, key)
+	}
+
+	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return key, nil
+}
+
+// readPublicKey accepts a public key, a certificate or a private key file.
+func readPublicKey(path string) (crypto.PublicKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch block.Type {
+	case "PUBLIC KEY":
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return cert.PublicKey, nil
+	}
+
+	key, err := readPrivateKey(path)
+	if err != nil {
+		return nil, err
+	}
+	return key.Public(), nil
+}
@@ -0,0 +1,367 @@
+package services
+
+import (
+	"backend101/models"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestJWKThumbprint(t *testing.T) {
+	tests := []struct {
+		name string
+		jwk  JWK
+		want string
+	}{
+		{
+			"RFC 7638 section 3.1",
+			JWK{
+				Kty: "RSA",
+				E:   "AQAB",
+				N:   "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw",
+				Alg: "RS256", // not part of the thumbprint
+				Kid: "2011-04-29",
+			},
+			"NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs",
+		},
+		{
+			"RFC 8037 appendix A.3",
+			JWK{Kty: "OKP", Crv: "Ed25519", X: "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"},
+			"kPrK_qmxVWaYVA9wwBF6Iuo3vVzz7TxHCTwXBygrS4k",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := jwkThumbprint(tt.jwk); got != tt.want {
+			t.Errorf("%s: thumbprint = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+// writeKey writes a PEM file to the test's temporary directory.
+func writeKey(t *testing.T, name, blockType string, der []byte) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func newRSAKey(t *testing.T, bits int) *rsa.PrivateKey {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func newEd25519Key(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// writePrivateKey writes key in PKCS #8 form.
+func writePrivateKey(t *testing.T, key interface{}) string {
+	t.Helper()
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return writeKey(t, "private.pem", "PRIVATE KEY", der)
+}
+
+func writePublicKey(t *testing.T, key interface{}) string {
+	t.Helper()
+
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return writeKey(t, "public.pem", "PUBLIC KEY", der)
+}
+
+// useSigningKeys loads the signing configuration for the rest of the test.
+func useSigningKeys(t *testing.T, method, privateKeyFile, kid, verifyKeys string) error {
+	t.Helper()
+
+	setConfig(t, "JWT_SIGNING_METHOD", method)
+	setConfig(t, "JWT_PRIVATE_KEY_FILE", privateKeyFile)
+	setConfig(t, "JWT_KEY_ID", kid)
+	setConfig(t, "JWT_VERIFY_KEYS", verifyKeys)
+
+	jwtKeysMu.Lock()
+	saved := jwtKeys
+	jwtKeysMu.Unlock()
+	t.Cleanup(func() {
+		jwtKeysMu.Lock()
+		jwtKeys = saved
+		jwtKeysMu.Unlock()
+	})
+
+	return LoadSigningKeys()
+}
+
+func tokenKid(t *testing.T, token string) string {
+	t.Helper()
+
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, &Claims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	kid, _ := parsed.Header["kid"].(string)
+	return kid
+}
+
+func TestLoadSigningKeys(t *testing.T) {
+	setClock(t, time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC))
+
+	rsaKey := newRSAKey(t, 2048)
+	edKey := newEd25519Key(t)
+	pkcs1 := writeKey(t, "rsa.pem", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey))
+
+	tests := []struct {
+		name   string
+		method string
+		file   string
+		kid    string
+		alg    string
+		kty    string
+	}{
+		{"RSA, PKCS #8", "RS256", writePrivateKey(t, rsaKey), "", "RS256", "RSA"},
+		{"RSA, PKCS #1", "rs256", pkcs1, "", "RS256", "RSA"},
+		{"RSA with a key id", "RS256", pkcs1, "2026-10", "RS256", "RSA"},
+		{"Ed25519", "EdDSA", writePrivateKey(t, edKey), "", "EdDSA", "OKP"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := useSigningKeys(t, tt.method, tt.file, tt.kid, ""); err != nil {
+				t.Fatal(err)
+			}
+
+			set, err := PublicJWKS()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(set.Keys) != 1 {
+				t.Fatalf("JWKS has %d keys, want 1", len(set.Keys))
+			}
+			jwk := set.Keys[0]
+			if jwk.Kty != tt.kty || jwk.Alg != tt.alg || jwk.Use != "sig" {
+				t.Errorf("JWK = %+v, want kty %s and alg %s", jwk, tt.kty, tt.alg)
+			}
+			wantKid := tt.kid
+			if wantKid == "" {
+				wantKid = jwkThumbprint(jwk)
+			}
+			if jwk.Kid != wantKid {
+				t.Errorf("kid = %s, want %s", jwk.Kid, wantKid)
+			}
+
+			token, err := GenerateJWT(1, models.RoleUser, "session")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if kid := tokenKid(t, token); kid != wantKid {
+				t.Errorf("token kid = %s, want %s", kid, wantKid)
+			}
+			if claims, err := ParseJWT(token); err != nil || claims.UserID != 1 {
+				t.Errorf("ParseJWT() = %+v, %v", claims, err)
+			}
+		})
+	}
+}
+
+func TestLoadSigningKeysRejects(t *testing.T) {
+	rsaFile := writePrivateKey(t, newRSAKey(t, 2048))
+	edFile := writePrivateKey(t, newEd25519Key(t))
+	small := writePrivateKey(t, newRSAKey(t, 1024))
+	garbage := filepath.Join(t.TempDir(), "garbage.pem")
+	if err := os.WriteFile(garbage, []byte("not a key"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		file       string
+		kid        string
+		verifyKeys string
+	}{
+		{"unknown method", "HS512", "", "", ""},
+		{"no key file", "RS256", "", "", ""},
+		{"missing key file", "RS256", filepath.Join(t.TempDir(), "missing.pem"), "", ""},
+		{"not PEM", "RS256", garbage, "", ""},
+		{"Ed25519 key for RS256", "RS256", edFile, "", ""},
+		{"RSA key for EdDSA", "EdDSA", rsaFile, "", ""},
+		{"RSA key under 2048 bits", "RS256", small, "", ""},
+		{"duplicate key id", "RS256", rsaFile, "k1", "k1=" + edFile},
+		{"same key twice", "RS256", rsaFile, "", rsaFile},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := useSigningKeys(t, tt.method, tt.file, tt.kid, tt.verifyKeys); err == nil {
+				t.Fatal("LoadSigningKeys() accepted the configuration")
+			}
+		})
+	}
+
+	t.Run("default secret in production", func(t *testing.T) {
+		setConfig(t, "ENV", "production")
+		setConfig(t, "JWT_SECRET", defaultJWTSecret)
+		if err := useSigningKeys(t, "HS256", "", "", ""); err == nil {
+			t.Fatal("LoadSigningKeys() accepted the default secret")
+		}
+	})
+}
+
+func TestKeyRotation(t *testing.T) {
+	setClock(t, time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC))
+
+	oldKey := newEd25519Key(t)
+	oldFile := writePrivateKey(t, oldKey)
+	newFile := writePrivateKey(t, newRSAKey(t, 2048))
+
+	if err := useSigningKeys(t, "EdDSA", oldFile, "", ""); err != nil {
+		t.Fatal(err)
+	}
+	oldToken, err := GenerateJWT(1, models.RoleUser, "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldKid := tokenKid(t, oldToken)
+
+	tests := []struct {
+		name       string
+		verifyKeys string
+		accepted   bool
+	}{
+		{"old public key kept by thumbprint", writePublicKey(t, oldKey.Public()), true},
+		{"old private key kept by thumbprint", oldFile, true},
+		{"old key kept under its kid", oldKid + "=" + writePublicKey(t, oldKey.Public()), true},
+		{"old key kept under another kid", "2025-01=" + writePublicKey(t, oldKey.Public()), false},
+		{"old key dropped", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := useSigningKeys(t, "RS256", newFile, "", tt.verifyKeys); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := ParseJWT(oldToken)
+			if tt.accepted && err != nil {
+				t.Fatalf("old token rejected: %v", err)
+			}
+			if !tt.accepted && !errors.Is(err, ErrInvalidToken) {
+				t.Fatalf("old token: error = %v, want ErrInvalidToken", err)
+			}
+
+			set, err := PublicJWKS()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := 1 + len(strings.Split(tt.verifyKeys, ",")); tt.verifyKeys != "" && len(set.Keys) != want {
+				t.Errorf("JWKS has %d keys, want %d", len(set.Keys), want)
+			}
+
+			// New tokens are signed with the new key only.
+			token, err := GenerateJWT(1, models.RoleUser, "session")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if kid := tokenKid(t, token); kid == oldKid {
+				t.Errorf("new token signed with the old key")
+			}
+			if _, err := ParseJWT(token); err != nil {
+				t.Errorf("new token rejected: %v", err)
+			}
+		})
+	}
+}
+
+func TestParseTokenRejectsForgedHeaders(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	setClock(t, now)
+
+	rsaKey := newRSAKey(t, 2048)
+	if err := useSigningKeys(t, "RS256", writePrivateKey(t, rsaKey), "", ""); err != nil {
+		t.Fatal(err)
+	}
+	set, err := PublicJWKS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	kid := set.Keys[0].Kid
+
+	publicPEM, err := os.ReadFile(writePublicKey(t, &rsaKey.PublicKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := func() *Claims {
+		return &Claims{
+			UserID: 1,
+			Role:   models.RoleUser,
+			RegisteredClaims: jwt.RegisteredClaims{
+				IssuedAt:  jwt.NewNumericDate(now),
+				ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+			},
+		}
+	}
+	sign := func(method jwt.SigningMethod, kid string, key interface{}) string {
+		token := jwt.NewWithClaims(method, claims())
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+		s, err := token.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		// The classic confusion: HMAC with the public key as the secret.
+		{"HS256 with the RSA kid", sign(jwt.SigningMethodHS256, kid, publicPEM)},
+		{"HS256 without a kid", sign(jwt.SigningMethodHS256, "", []byte(defaultJWTSecret))},
+		{"unknown kid", sign(jwt.SigningMethodRS256, "unknown", rsaKey)},
+		{"no kid", sign(jwt.SigningMethodRS256, "", rsaKey)},
+		{"another key under the kid", sign(jwt.SigningMethodRS256, kid, newRSAKey(t, 2048))},
+		{"EdDSA with the RSA kid", sign(jwt.SigningMethodEdDSA, kid, newEd25519Key(t))},
+		{"alg none", sign(jwt.SigningMethodNone, kid, jwt.UnsafeAllowNoneSignatureType)},
+	}
+
+	for _, tt := range tests {
+		if _, err := ParseJWT(tt.token); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s: error = %v, want ErrInvalidToken", tt.name, err)
+		}
+	}
+
+	if _, err := ParseJWT(sign(jwt.SigningMethodRS256, kid, rsaKey)); err != nil {
+		t.Errorf("correctly signed token rejected: %v", err)
+	}
+}
@@ -2,21 +2,106 @@ package services
 
 import (
 	"backend101/config"
+	"errors"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
-func GenerateJWT(userID uint) (string, error) {
-	secret := config.Get("JWT_SECRET")
-	expireHours := config.GetInt("JWT_EXPIRE_HOURS")
+var ErrInvalidToken = errors.New("invalid or expired token")
 
-	claims := jwt.MapClaims{
-		"user_id": userID,
-		"exp":     time.Now().Add(time.Hour * time.Duration(expireHours)).Unix(),
+// Claims are the claims carried by access tokens. The JSON name of UserID is
+// kept as "user_id" for compatibility with tokens issued by older versions.
+type Claims struct {
+	UserID uint   `json:"user_id"`
+	Role   string `json:"role,omitempty"`
+	// SessionID links the token to its models.Session.
+	SessionID string `json:"sid,omitempty"`
+	// Purpose is empty for access tokens. Other tokens signed with the same
+	// keys (e.g. MFA challenges) set it so they cannot be used as access tokens.
+	Purpose string `json:"purpose,omitempty"`
+	jwt.RegisteredClaims
+}
+
+func init() {
+	// Issue times in milliseconds, so a token issued right after "log out
+	// everywhere" is told apart from the ones it revoked; see
+	// IsTokenRevoked.
+	jwt.TimePrecision = time.Millisecond
+}
+
+func accessTokenTTL() time.Duration {
+	return time.Minute * time.Duration(config.GetInt("JWT_ACCESS_EXPIRE_MINUTES"))
+}
+
+func GenerateJWT(userID uint, role, sessionID string) (string, error) {
+	jti, err := generateID()
+	if err != nil {
+		return "", err
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	now := Now()
+	claims := Claims{
+		UserID:    userID,
+		Role:      role,
+		SessionID: sessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(accessTokenTTL())),
+		},
+	}
+
+	return signToken(claims)
+}
+
+// ParseJWT verifies the signature and expiry of an access token.
+func ParseJWT(tokenStr string) (*Claims, error) {
+	claims := &Claims{}
+	if err := parseToken(tokenStr, claims); err != nil {
+		return nil, err
+	}
+	if claims.Purpose != "" {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+func signToken(claims jwt.Claims) (string, error) {
+	ks, err := currentKeySet()
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(ks.method, claims)
+	if ks.kid != "" {
+		token.Header["kid"] = ks.kid
+	}
+
+	return token.SignedString(ks.signKey)
+}
+
+func parseToken(tokenStr string, claims jwt.Claims) error {
+	ks, err := currentKeySet()
+	if err != nil {
+		return err
+	}
+
+	token, err := jwt.ParseWithClaims(tokenStr, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		vk, ok := ks.verify[kid]
+		if !ok {
+			return nil, jwt.ErrTokenUnverifiable
+		}
+		// The key decides the algorithm, never the token header.
+		if t.Method.Alg() != vk.method.Alg() {
+			return nil, jwt.ErrSignatureInvalid
+		}
+		return vk.key, nil
+	}, jwt.WithTimeFunc(Now), jwt.WithExpirationRequired())
+	if err != nil || !token.Valid {
+		return ErrInvalidToken
+	}
 
-	return token.SignedString([]byte(secret))
+	return nil
 }
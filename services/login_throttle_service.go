@@ -0,0 +1,124 @@
+package services
+
+import (
+	"backend101/config"
+	"backend101/database"
+	"backend101/models"
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Failures before progressive delays kick in. The n-th failure after that
+// blocks further attempts for 2^(n-freeFailures) seconds.
+const freeLoginFailures = 3
+
+// ThrottledError is returned when a login is attempted during a delay or
+// lockout.
+type ThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("too many login attempts, retry after %s", e.RetryAfter)
+}
+
+type throttleSubject struct {
+	kind        string // "email" or "ip"
+	value       string
+	maxFailures int
+}
+
+func throttleSubjects(email, ip string) []throttleSubject {
+	subjects := []throttleSubject{{"email", strings.ToLower(strings.TrimSpace(email)), config.GetInt("LOGIN_MAX_FAILURES_PER_EMAIL")}}
+	if ip != "" {
+		subjects = append(subjects, throttleSubject{"ip", ip, config.GetInt("LOGIN_MAX_FAILURES_PER_IP")})
+	}
+	return subjects
+}
+
+func (s throttleSubject) failuresKey() string {
+	return "auth:login:failures:" + s.kind + ":" + s.value
+}
+
+func (s throttleSubject) lockKey() string {
+	return "auth:login:lock:" + s.kind + ":" + s.value
+}
+
+// CheckLoginThrottle returns a *ThrottledError while the email or IP is
+// delayed or locked out.
+func CheckLoginThrottle(ctx context.Context, email, ip string) error {
+	var wait time.Duration
+	for _, s := range throttleSubjects(email, ip) {
+		until, locked, err := database.GetInt64(ctx, database.Cache, s.lockKey())
+		if err != nil {
+			return err
+		}
+		if !locked {
+			continue
+		}
+		if d := time.Unix(until, 0).Sub(Now()); d > wait {
+			wait = d
+		}
+	}
+
+	if wait > 0 {
+		return &ThrottledError{RetryAfter: wait}
+	}
+	return nil
+}
+
+// RecordLoginFailure counts a failed attempt for the email and the IP and
+// applies a delay or lockout when the thresholds are crossed.
+func RecordLoginFailure(ctx context.Context, email, ip string, userID *uint) error {
+	window := time.Minute * time.Duration(config.GetInt("LOGIN_FAILURE_WINDOW_MINUTES"))
+	lockout := time.Minute * time.Duration(config.GetInt("LOGIN_LOCKOUT_MINUTES"))
+
+	for _, s := range throttleSubjects(email, ip) {
+		failures, err := database.Cache.Incr(ctx, s.failuresKey(), window)
+		if err != nil {
+			return err
+		}
+
+		var lockFor time.Duration
+		switch {
+		case failures >= int64(s.maxFailures):
+			lockFor = lockout
+			// Start counting from zero once the lock expires.
+			if err := database.Cache.Delete(ctx, s.failuresKey()); err != nil {
+				return err
+			}
+			RecordAuditEvent(models.AuditLoginLocked, userID, ip, map[string]interface{}{
+				"subject":  s.kind,
+				"value":    s.value,
+				"failures": failures,
+				"until":    Now().Add(lockFor).UTC(),
+			})
+		case failures > freeLoginFailures:
+			seconds := math.Pow(2, float64(failures-freeLoginFailures-1))
+			lockFor = time.Duration(seconds) * time.Second
+			if lockFor > lockout {
+				lockFor = lockout
+			}
+		default:
+			continue
+		}
+
+		until := strconv.FormatInt(Now().Add(lockFor).Unix(), 10)
+		if err := database.Cache.Set(ctx, s.lockKey(), until, lockFor); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ResetLoginFailures clears the per-email counter after a complete, successful
+// login. The per-IP counter is left alone so one valid account cannot be used
+// to reset the budget of an IP that is spraying others.
+func ResetLoginFailures(ctx context.Context, email string) error {
+	s := throttleSubjects(email, "")[0]
+	return database.Cache.Delete(ctx, s.failuresKey())
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"backend101/config"
+	"backend101/database"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+)
+
+func TestMain(m *testing.M) {
+	config.LoadConfig()
+	os.Exit(m.Run())
+}
+
+var (
+	testDBOnce sync.Once
+	testDB     *gorm.DB
+	testDBErr  error
+)
+
+// useTestDB points database.DB at a transaction on the Postgres database in
+// TEST_DATABASE_DSN and rolls it back when the test ends. Tests that need
+// the database are skipped when the variable is not set.
+func useTestDB(t *testing.T) {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN not set")
+	}
+
+	testDBOnce.Do(func() {
+		saved := database.DB
+		defer func() { database.DB = saved }()
+
+		if database.DB, testDBErr = database.Open(dsn); testDBErr != nil {
+			return
+		}
+		testDB = database.DB
+		testDBErr = database.Migrate()
+	})
+	if testDBErr != nil {
+		t.Fatalf("test database: %v", testDBErr)
+	}
+
+	tx := testDB.Begin()
+	if tx.Error != nil {
+		t.Fatalf("begin: %v", tx.Error)
+	}
+	saved := database.DB
+	database.DB = tx
+	t.Cleanup(func() {
+		database.DB = saved
+		tx.Rollback()
+	})
+}
+
+// setClock fixes Now at t for the rest of the test and returns a function
+// that moves it.
+func setClock(t *testing.T, at time.Time) func(time.Time) {
+	t.Helper()
+
+	var mu sync.Mutex
+	current := at
+	saved := Now
+	Now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return current
+	}
+	t.Cleanup(func() { Now = saved })
+
+	return func(next time.Time) {
+		mu.Lock()
+		current = next
+		mu.Unlock()
+	}
+}
+
+// setConfig overrides a config value for the rest of the test.
+func setConfig(t *testing.T, key string, value interface{}) {
+	t.Helper()
+
+	saved := viper.Get(key)
+	viper.Set(key, value)
+	t.Cleanup(func() { viper.Set(key, saved) })
+}
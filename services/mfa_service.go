@@ -0,0 +1,321 @@
+package services
+
+import (
+	"backend101/config"
+	"backend101/database"
+	"backend101/models"
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+const (
+	mfaChallengePurpose   = "mfa"
+	mfaChallengeKeyPrefix = "auth:mfa:challenge:"
+	// Wrong codes allowed per challenge before it is burned.
+	mfaChallengeMaxAttempts = 5
+	recoveryCodeCount       = 10
+)
+
+var (
+	ErrMFAAlreadyEnabled = errors.New("mfa is already enabled")
+	ErrMFANotEnabled     = errors.New("mfa is not enabled")
+	ErrMFANoEnrollment   = errors.New("no mfa enrollment in progress")
+	ErrInvalidMFACode    = errors.New("invalid mfa code")
+	ErrInvalidPassword   = errors.New("invalid password")
+	ErrInvalidMFAToken   = errors.New("invalid or expired mfa token")
+)
+
+// Recovery codes avoid look-alike characters (l, o, 0, 1).
+var recoveryCodeAlphabet = base32.NewEncoding("abcdefghijkmnpqrstuvwxyz23456789").WithPadding(base32.NoPadding)
+
+func mfaChallengeTTL() time.Duration {
+	return time.Minute * time.Duration(config.GetInt("MFA_CHALLENGE_EXPIRE_MINUTES"))
+}
+
+// BeginMFAEnrollment stores a new pending TOTP secret and returns it together
+// with the otpauth:// URI for authenticator apps.
+func BeginMFAEnrollment(userID uint) (*models.MFAEnrollResponse, error) {
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+	if user.MFAEnabledAt != nil {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := database.DB.Model(&user).Update("mfa_pending_secret", secret).Error; err != nil {
+		return nil, err
+	}
+
+	return &models.MFAEnrollResponse{
+		Secret:     secret,
+		OTPAuthURI: TOTPURI(config.Get("MFA_ISSUER"), user.Email, secret),
+	}, nil
+}
+
+// ConfirmMFAEnrollment enables MFA once the user proves their authenticator
+// works, and returns a fresh set of recovery codes.
+func ConfirmMFAEnrollment(userID uint, code string) ([]string, error) {
+	var codes []string
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.First(&user, userID).Error; err != nil {
+			return err
+		}
+		if user.MFAEnabledAt != nil {
+			return ErrMFAAlreadyEnabled
+		}
+		if user.MFAPendingSecret == "" {
+			return ErrMFANoEnrollment
+		}
+
+		step, ok := validateTOTP(user.MFAPendingSecret, normalizeMFACode(code), Now(), 0)
+		if !ok {
+			return ErrInvalidMFACode
+		}
+
+		if err := tx.Model(&user).Updates(map[string]interface{}{
+			"mfa_secret":         user.MFAPendingSecret,
+			"mfa_pending_secret": "",
+			"mfa_enabled_at":     Now(),
+			"mfa_last_used_step": step,
+		}).Error; err != nil {
+			return err
+		}
+
+		var err error
+		codes, err = replaceRecoveryCodes(tx, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// DisableMFA requires both the password and a valid second factor.
+func DisableMFA(userID uint, password, code string) error {
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		return err
+	}
+	if user.MFAEnabledAt == nil {
+		return ErrMFANotEnabled
+	}
+	if !CheckPasswordHash(password, user.Password) {
+		return ErrInvalidPassword
+	}
+	if err := VerifyMFACode(&user, code); err != nil {
+		return err
+	}
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Updates(map[string]interface{}{
+			"mfa_secret":         "",
+			"mfa_pending_secret": "",
+			"mfa_enabled_at":     nil,
+			"mfa_last_used_step": 0,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error
+	})
+}
+
+// RegenerateRecoveryCodes invalidates all existing recovery codes. It needs a
+// TOTP code rather than a recovery code so a leaked code cannot mint new ones.
+func RegenerateRecoveryCodes(userID uint, code string) ([]string, error) {
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+	if user.MFAEnabledAt == nil {
+		return nil, ErrMFANotEnabled
+	}
+	if err := verifyTOTP(&user, normalizeMFACode(code)); err != nil {
+		return nil, err
+	}
+
+	var codes []string
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		codes, err = replaceRecoveryCodes(tx, userID)
+		return err
+	})
+	return codes, err
+}
+
+// VerifyMFACode accepts either a TOTP code or an unused recovery code.
+func VerifyMFACode(user *models.User, code string) error {
+	code = normalizeMFACode(code)
+	if len(code) == totpDigits {
+		return verifyTOTP(user, code)
+	}
+	return useRecoveryCode(user.ID, code)
+}
+
+func verifyTOTP(user *models.User, code string) error {
+	step, ok := validateTOTP(user.MFASecret, code, Now(), user.MFALastUsedStep)
+	if !ok {
+		return ErrInvalidMFACode
+	}
+
+	// Conditional update so the same code cannot be redeemed twice, even by
+	// concurrent requests.
+	res := database.DB.Model(&models.User{}).
+		Where("id = ? AND mfa_last_used_step < ?", user.ID, step).
+		Update("mfa_last_used_step", step)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrInvalidMFACode
+	}
+	user.MFALastUsedStep = step
+	return nil
+}
+
+func useRecoveryCode(userID uint, code string) error {
+	res := database.DB.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hashToken(code)).
+		Update("used_at", Now())
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrInvalidMFACode
+	}
+	return nil
+}
+
+func normalizeMFACode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer(" ", "", "-", "").Replace(code)
+}
+
+func replaceRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	rows := make([]models.RecoveryCode, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		raw := recoveryCodeAlphabet.EncodeToString(b) // 8 characters
+		codes = append(codes, raw[:4]+"-"+raw[4:])
+		rows = append(rows, models.RecoveryCode{UserID: userID, CodeHash: hashToken(raw)})
+	}
+
+	if err := tx.Create(&rows).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// IssueMFAChallenge returns a short-lived token that proves the password step
+// of a login succeeded. It cannot be used as an access token.
+func IssueMFAChallenge(userID uint) (*models.MFAChallengeResponse, error) {
+	jti, err := generateID()
+	if err != nil {
+		return nil, err
+	}
+
+	now := Now()
+	ttl := mfaChallengeTTL()
+	token, err := signToken(Claims{
+		UserID:  userID,
+		Purpose: mfaChallengePurpose,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.MFAChallengeResponse{
+		MFARequired: true,
+		MFAToken:    token,
+		ExpiresIn:   int(ttl.Seconds()),
+	}, nil
+}
+
+func parseMFAChallenge(challenge string) (*Claims, error) {
+	claims := &Claims{}
+	if err := parseToken(challenge, claims); err != nil || claims.Purpose != mfaChallengePurpose || claims.ID == "" {
+		return nil, ErrInvalidMFAToken
+	}
+	return claims, nil
+}
+
+// CompleteMFAChallenge checks the second factor for a login challenge and
+// returns the user on success. A challenge can be completed once and allows
+// only a few wrong codes.
+func CompleteMFAChallenge(ctx context.Context, challenge, code, ip string) (*models.User, error) {
+	claims, err := parseMFAChallenge(challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	key := mfaChallengeKeyPrefix + claims.ID
+	ttl := mfaChallengeTTL()
+	attempts, err := database.Cache.Incr(ctx, key, ttl)
+	if err != nil {
+		return nil, err
+	}
+	if attempts > mfaChallengeMaxAttempts {
+		return nil, ErrInvalidMFAToken
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, claims.UserID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidMFAToken
+		}
+		return nil, err
+	}
+	if user.MFAEnabledAt == nil {
+		return nil, ErrInvalidMFAToken
+	}
+
+	// Wrong codes count towards the same lockout as wrong passwords.
+	if err := CheckLoginThrottle(ctx, user.Email, ip); err != nil {
+		return nil, err
+	}
+	if err := VerifyMFACode(&user, code); err != nil {
+		if errors.Is(err, ErrInvalidMFACode) {
+			if recErr := RecordLoginFailure(ctx, user.Email, ip, &user.ID); recErr != nil {
+				return nil, recErr
+			}
+		}
+		return nil, err
+	}
+	if err := ResetLoginFailures(ctx, user.Email); err != nil {
+		return nil, err
+	}
+
+	// Burn the challenge.
+	if err := database.Cache.Set(ctx, key, fmt.Sprint(mfaChallengeMaxAttempts+1), ttl); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
@@ -0,0 +1,212 @@
+package services
+
+import (
+	"backend101/database"
+	"backend101/models"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMFAChallengeExpiry(t *testing.T) {
+	issued := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	advance := setClock(t, issued)
+	ttl := mfaChallengeTTL()
+
+	challenge, err := IssueMFAChallenge(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if challenge.ExpiresIn != int(ttl.Seconds()) {
+		t.Errorf("ExpiresIn = %d, want %d", challenge.ExpiresIn, int(ttl.Seconds()))
+	}
+
+	tests := []struct {
+		name  string
+		at    time.Time
+		valid bool
+	}{
+		{"just issued", issued, true},
+		{"one second left", issued.Add(ttl - time.Second), true},
+		{"at expiry", issued.Add(ttl), false},
+		{"an hour later", issued.Add(time.Hour), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			advance(tt.at)
+
+			claims, err := parseMFAChallenge(challenge.MFAToken)
+			if tt.valid {
+				if err != nil || claims.UserID != 1 {
+					t.Fatalf("parseMFAChallenge() = %+v, %v; want user 1", claims, err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrInvalidMFAToken) {
+				t.Fatalf("parseMFAChallenge() error = %v, want ErrInvalidMFAToken", err)
+			}
+			if _, err := CompleteMFAChallenge(context.Background(), challenge.MFAToken, "000000", "127.0.0.1"); !errors.Is(err, ErrInvalidMFAToken) {
+				t.Fatalf("CompleteMFAChallenge() error = %v, want ErrInvalidMFAToken", err)
+			}
+		})
+	}
+}
+
+func TestMFAChallengeIsNotAnAccessToken(t *testing.T) {
+	setClock(t, time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC))
+
+	access, err := GenerateJWT(1, models.RoleUser, "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parseMFAChallenge(access); !errors.Is(err, ErrInvalidMFAToken) {
+		t.Fatalf("access token accepted as a challenge: %v", err)
+	}
+}
+
+// createMFAUser stores a user with TOTP enabled on the RFC 6238 secret and
+// returns it with its recovery codes.
+func createMFAUser(t *testing.T) (*models.User, []string) {
+	t.Helper()
+
+	now := Now()
+	user := models.User{
+		Name:            "Ada",
+		Email:           "ada@example.com",
+		Password:        "hash",
+		EmailVerifiedAt: &now,
+		MFASecret:       rfc6238Secret,
+		MFAEnabledAt:    &now,
+	}
+	if err := database.DB.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	codes, err := replaceRecoveryCodes(database.DB, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &user, codes
+}
+
+func TestVerifyMFACodeRefusesReuse(t *testing.T) {
+	useTestDB(t)
+	advance := setClock(t, time.Unix(1111111111, 0).UTC())
+	user, _ := createMFAUser(t)
+
+	tests := []struct {
+		name string
+		at   time.Time
+		code string
+		err  error
+	}{
+		{"current code", time.Unix(1111111111, 0), "050471", nil},
+		{"same code again", time.Unix(1111111115, 0), "050471", ErrInvalidMFACode},
+		{"previous step after a newer one", time.Unix(1111111111, 0), "081804", ErrInvalidMFACode},
+		{"next step", time.Unix(1111111140, 0), mustTOTP(t, time.Unix(1111111140, 0)), nil},
+	}
+
+	for _, tt := range tests {
+		advance(tt.at.UTC())
+
+		// Reload so the stored step is what protects against reuse, not the
+		// in-memory copy.
+		var fresh models.User
+		if err := database.DB.First(&fresh, user.ID).Error; err != nil {
+			t.Fatal(err)
+		}
+		if err := VerifyMFACode(&fresh, tt.code); !errors.Is(err, tt.err) {
+			t.Errorf("%s: VerifyMFACode() error = %v, want %v", tt.name, err, tt.err)
+		}
+	}
+}
+
+func mustTOTP(t *testing.T, at time.Time) string {
+	t.Helper()
+
+	code, err := TOTPCode(rfc6238Secret, at)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+func TestRecoveryCodeSingleUse(t *testing.T) {
+	useTestDB(t)
+	setClock(t, time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC))
+	user, codes := createMFAUser(t)
+
+	if len(codes) != recoveryCodeCount {
+		t.Fatalf("%d recovery codes, want %d", len(codes), recoveryCodeCount)
+	}
+
+	tests := []struct {
+		name string
+		code string
+		err  error
+	}{
+		{"first use", codes[0], nil},
+		{"second use", codes[0], ErrInvalidMFACode},
+		{"another code, as typed", " " + strings.ToUpper(codes[1]) + " ", nil},
+		{"without the dash, used", strings.ReplaceAll(codes[1], "-", ""), ErrInvalidMFACode},
+		{"unknown code", "aaaa-bbbb", ErrInvalidMFACode},
+	}
+
+	for _, tt := range tests {
+		if err := VerifyMFACode(user, tt.code); !errors.Is(err, tt.err) {
+			t.Errorf("%s: VerifyMFACode() error = %v, want %v", tt.name, err, tt.err)
+		}
+	}
+
+	var used int64
+	database.DB.Model(&models.RecoveryCode{}).Where("user_id = ? AND used_at IS NOT NULL", user.ID).Count(&used)
+	if used != 2 {
+		t.Errorf("%d recovery codes used, want 2", used)
+	}
+
+	// New codes replace every old one, used or not.
+	if _, err := replaceRecoveryCodes(database.DB, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyMFACode(user, codes[2]); !errors.Is(err, ErrInvalidMFACode) {
+		t.Errorf("replaced code: VerifyMFACode() error = %v, want ErrInvalidMFACode", err)
+	}
+}
+
+func TestCompleteMFAChallenge(t *testing.T) {
+	useTestDB(t)
+	now := time.Unix(1111111111, 0).UTC()
+	advance := setClock(t, now)
+	user, _ := createMFAUser(t)
+	ctx := context.Background()
+
+	challenge, err := IssueMFAChallenge(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := CompleteMFAChallenge(ctx, challenge.MFAToken, "050 471", "127.0.0.1")
+	if err != nil {
+		t.Fatalf("CompleteMFAChallenge() error = %v", err)
+	}
+	if got.ID != user.ID {
+		t.Errorf("user %d, want %d", got.ID, user.ID)
+	}
+
+	// A completed challenge cannot be used again, even with a new code.
+	advance(now.Add(totpPeriod * time.Second))
+	if _, err := CompleteMFAChallenge(ctx, challenge.MFAToken, mustTOTP(t, Now()), "127.0.0.1"); !errors.Is(err, ErrInvalidMFAToken) {
+		t.Fatalf("reused challenge: error = %v, want ErrInvalidMFAToken", err)
+	}
+
+	// An expired challenge is refused before the code is checked.
+	challenge, err = IssueMFAChallenge(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	advance(Now().Add(mfaChallengeTTL()))
+	if _, err := CompleteMFAChallenge(ctx, challenge.MFAToken, mustTOTP(t, Now()), "127.0.0.1"); !errors.Is(err, ErrInvalidMFAToken) {
+		t.Fatalf("expired challenge: error = %v, want ErrInvalidMFAToken", err)
+	}
+}
@@ -0,0 +1,371 @@
+package services
+
+import (
+	"backend101/config"
+	"backend101/database"
+	"backend101/dto"
+	"backend101/mailer"
+	"backend101/models"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrUnknownChannel       = errors.New("unknown notification channel")
+	ErrWebhookURLRequired   = errors.New("the webhook channel needs a url")
+	ErrWebhookURLNotAllowed = errors.New("webhook url must use https and a public address")
+)
+
+// Notifier delivers notifications on a channel outside the API.
+// Implementations must be safe for concurrent use.
+type Notifier interface {
+	Deliver(ctx context.Context, user *models.User, pref *models.NotificationPreference, n *models.Notification) error
+}
+
+// notifiers deliver each channel except in-app, which is the notification
+// center itself.
+var notifiers = map[string]Notifier{
+	models.ChannelEmail:   EmailNotifier{},
+	models.ChannelWebhook: &WebhookNotifier{Client: newWebhookClient()},
+}
+
+// RegisterNotifier adds or replaces the notifier of a channel. Call it at
+// startup, before notifications are sent.
+func RegisterNotifier(channel string, n Notifier) {
+	notifiers[channel] = n
+	if !slices.Contains(models.NotificationChannels, channel) {
+		models.NotificationChannels = append(models.NotificationChannels, channel)
+	}
+}
+
+// EmailNotifier sends notifications to the user's email address.
+type EmailNotifier struct{}
+
+func (EmailNotifier) Deliver(ctx context.Context, user *models.User, _ *models.NotificationPreference, n *models.Notification) error {
+	return mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: n.Title,
+		Body: fmt.Sprintf("Hi %s,\n\n%s\n\n"+
+			"You can change which notifications you get by email at %s.\n",
+			user.Name, n.Body, config.Get("APP_URL")+"/settings/notifications"),
+	})
+}
+
+// WebhookNotifier POSTs notifications as JSON to the user's URL. The
+// X-Signature header is "sha256=" and the hex HMAC-SHA256 of the body, keyed
+// with the channel's secret.
+type WebhookNotifier struct {
+	Client *http.Client
+}
+
+func (w *WebhookNotifier) Deliver(ctx context.Context, _ *models.User, pref *models.NotificationPreference, n *models.Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	mac := hmac.New(sha256.New, []byte(pref.Secret))
+	mac.Write(body)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, pref.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+
+	resp, err := w.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// newWebhookClient returns a client that only connects to public addresses.
+// The check runs on the resolved address of every connection, so a hostname
+// that resolves (or later rebinds) to an internal address is refused too.
+// Redirects are not followed, and proxies are not used since they would
+// connect on our behalf.
+func newWebhookClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			addr, err := netip.ParseAddrPort(address)
+			if err != nil || !publicAddr(addr.Addr()) {
+				return fmt.Errorf("%w: %s", ErrWebhookURLNotAllowed, address)
+			}
+			return nil
+		},
+	}
+
+	return &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 10 * time.Second,
+			MaxIdleConns:        10,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return errors.New("webhook redirects are not followed")
+		},
+	}
+}
+
+// Ranges that are not reachable on the internet but that netip does not
+// classify as private.
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"), // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"), // benchmarking
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"), // NAT64, may map to private IPv4
+}
+
+// publicAddr reports whether addr is a unicast address on the internet, as
+// opposed to loopback, link-local (including cloud metadata endpoints),
+// private or otherwise reserved.
+func publicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, p := range nonPublicPrefixes {
+		if p.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// validateWebhookURL requires https, except with ENV=development, and
+// refuses hosts that are literal non-public addresses. Hostnames are checked
+// again when connecting, after they are resolved.
+func validateWebhookURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || u.User != nil {
+		return ErrWebhookURLNotAllowed
+	}
+	switch u.Scheme {
+	case "https":
+	case "http":
+		if config.Get("ENV") != "development" {
+			return ErrWebhookURLNotAllowed
+		}
+	default:
+		return ErrWebhookURLNotAllowed
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrWebhookURLNotAllowed
+	}
+	if addr, err := netip.ParseAddr(host); err == nil && !publicAddr(addr) {
+		return ErrWebhookURLNotAllowed
+	}
+	return nil
+}
+
+// wants reports whether notifications of kind are sent on the channel.
+func wants(pref models.NotificationPreference, kind string) bool {
+	return pref.Enabled && (pref.Kinds == "" || slices.Contains(strings.Split(pref.Kinds, ","), kind))
+}
+
+// GetNotificationPreferences returns the user's setting for every channel,
+// with defaults for the ones never changed.
+func GetNotificationPreferences(userID uint) ([]models.NotificationPreference, error) {
+	var stored []models.NotificationPreference
+	if err := database.DB.Where("user_id = ?", userID).Find(&stored).Error; err != nil {
+		return nil, err
+	}
+
+	prefs := make([]models.NotificationPreference, len(models.NotificationChannels))
+	for i, channel := range models.NotificationChannels {
+		prefs[i] = models.DefaultNotificationPreference(userID, channel)
+		for _, p := range stored {
+			if p.Channel == channel {
+				prefs[i] = p
+			}
+		}
+	}
+	return prefs, nil
+}
+
+// UpdateNotificationPreference applies the fields that are set in input to
+// one channel. A webhook gets a signing secret when it is first set up.
+func UpdateNotificationPreference(userID uint, channel string, input dto.UpdateChannelInput) (*models.NotificationPreference, error) {
+	if !slices.Contains(models.NotificationChannels, channel) {
+		return nil, ErrUnknownChannel
+	}
+
+	pref := models.DefaultNotificationPreference(userID, channel)
+	err := database.DB.Where("user_id = ? AND channel = ?", userID, channel).First(&pref).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if input.Enabled != nil {
+		pref.Enabled = *input.Enabled
+	}
+	if input.Kinds != nil {
+		pref.Kinds = strings.Join(input.Kinds, ",")
+	}
+	if channel == models.ChannelWebhook {
+		if input.URL != nil {
+			pref.URL = strings.TrimSpace(*input.URL)
+			if pref.URL != "" {
+				if err := validateWebhookURL(pref.URL); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if pref.Enabled && pref.URL == "" {
+			return nil, ErrWebhookURLRequired
+		}
+		if pref.URL != "" && pref.Secret == "" {
+			if pref.Secret, err = generateOpaqueToken(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := database.DB.Save(&pref).Error; err != nil {
+		return nil, err
+	}
+	return &pref, nil
+}
+
+// notify stores a notification and delivers it on the user's channels, and
+// reports whether it was new. A notification whose key the user already has
+// is dropped, so callers can check for events as often as they like.
+func notify(n models.Notification) (bool, error) {
+	prefs, err := GetNotificationPreferences(n.UserID)
+	if err != nil {
+		return false, err
+	}
+	for _, p := range prefs {
+		if p.Channel == models.ChannelInApp {
+			n.InApp = wants(p, n.Kind)
+		}
+	}
+
+	res := database.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&n)
+	if res.Error != nil || res.RowsAffected == 0 {
+		return false, res.Error
+	}
+
+	var user *models.User
+	for _, p := range prefs {
+		notifier := notifiers[p.Channel]
+		if notifier == nil || !wants(p, n.Kind) {
+			continue
+		}
+		if user == nil {
+			if user, err = GetUser(n.UserID); err != nil {
+				return true, err
+			}
+		}
+		deliverAsync(notifier, user, p, n)
+	}
+	return true, nil
+}
+
+func deliverAsync(notifier Notifier, user *models.User, pref models.NotificationPreference, n models.Notification) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := notifier.Deliver(ctx, user, &pref, &n); err != nil {
+			log.Printf("❌ Failed to deliver notification %d by %s: %v", n.ID, pref.Channel, err)
+		}
+	}()
+}
+
+// ListNotifications returns the user's in-app notifications, newest first.
+func ListNotifications(userID uint, q dto.NotificationQuery) (*dto.NotificationList, error) {
+	if q.Page == 0 {
+		q.Page = 1
+	}
+	if q.Limit == 0 {
+		q.Limit = 20
+	}
+
+	db := database.DB.Model(&models.Notification{}).Where("user_id = ? AND in_app", userID)
+	list := &dto.NotificationList{Page: q.Page, Limit: q.Limit, Data: []models.Notification{}}
+	if err := db.Session(&gorm.Session{}).Where("read_at IS NULL").Count(&list.UnreadCount).Error; err != nil {
+		return nil, err
+	}
+	if q.Unread {
+		db = db.Where("read_at IS NULL")
+	}
+	if err := db.Session(&gorm.Session{}).Count(&list.Total).Error; err != nil {
+		return nil, err
+	}
+	err := db.Order("created_at DESC, id DESC").Offset((q.Page - 1) * q.Limit).Limit(q.Limit).Find(&list.Data).Error
+	return list, err
+}
+
+// SetNotificationRead marks a notification as read or unread.
+func SetNotificationRead(userID uint, id string, read bool) (*models.Notification, error) {
+	var n models.Notification
+	if err := database.DB.Where("id = ? AND user_id = ? AND in_app", id, userID).First(&n).Error; err != nil {
+		return nil, err
+	}
+
+	switch {
+	case read && n.ReadAt == nil:
+		now := Now()
+		n.ReadAt = &now
+	case !read:
+		n.ReadAt = nil
+	}
+	if err := database.DB.Model(&n).Update("read_at", n.ReadAt).Error; err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+// MarkAllNotificationsRead marks every unread notification as read and
+// returns how many there were.
+func MarkAllNotificationsRead(userID uint) (int64, error) {
+	res := database.DB.Model(&models.Notification{}).
+		Where("user_id = ? AND in_app AND read_at IS NULL", userID).
+		Update("read_at", Now())
+	return res.RowsAffected, res.Error
+}
+
+// DeleteNotification removes a notification from the notification center.
+// The row is kept, hidden, so the same event is not notified again.
+func DeleteNotification(userID uint, id string) error {
+	res := database.DB.Model(&models.Notification{}).
+		Where("id = ? AND user_id = ? AND in_app", id, userID).
+		Update("in_app", false)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
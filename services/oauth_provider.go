@@ -0,0 +1,508 @@
+package services
+
+import (
+	"backend101/config"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	providerTypeOIDC   = "oidc"
+	providerTypeGitHub = "github"
+
+	jwksCacheTTL = time.Hour
+)
+
+var (
+	ErrUnknownProvider = errors.New("unknown oauth provider")
+	ErrOAuthExchange   = errors.New("oauth code exchange failed")
+)
+
+// oauthHTTPClient is used for every call to an identity provider.
+var oauthHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// ExternalIdentity is what a provider tells us about the signed-in user.
+type ExternalIdentity struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// OAuthProvider is an OAuth2 provider. OIDC providers are configured from
+// their discovery document; GitHub is plain OAuth2 plus its REST API. For
+// GitHub, Issuer is the web host serving /login/oauth and APIURL the REST
+// API, so GitHub Enterprise works the same way as github.com.
+type OAuthProvider struct {
+	Name         string
+	Type         string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	RedirectURL  string
+	Issuer       string
+	APIURL       string
+
+	mu          sync.Mutex
+	discovered  bool
+	authURL     string
+	tokenURL    string
+	userinfoURL string
+	jwksURL     string
+	jwks        map[string]crypto.PublicKey
+	jwksFetched time.Time
+}
+
+var (
+	oauthProvidersMu sync.Mutex
+	oauthProviders   map[string]*OAuthProvider
+)
+
+// GetOAuthProvider returns a provider listed in OAUTH_PROVIDERS. Each provider
+// NAME reads OAUTH_<NAME>_CLIENT_ID, _CLIENT_SECRET, _ISSUER, _SCOPES, _TYPE
+// ("oidc" or "github") and, for GitHub, _API_URL.
+func GetOAuthProvider(name string) (*OAuthProvider, error) {
+	oauthProvidersMu.Lock()
+	defer oauthProvidersMu.Unlock()
+
+	if oauthProviders == nil {
+		oauthProviders = loadOAuthProviders()
+	}
+
+	p, ok := oauthProviders[strings.ToLower(name)]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	return p, nil
+}
+
+func loadOAuthProviders() map[string]*OAuthProvider {
+	providers := map[string]*OAuthProvider{}
+
+	for _, name := range strings.Split(config.Get("OAUTH_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		prefix := "OAUTH_" + strings.ToUpper(name) + "_"
+
+		p := &OAuthProvider{
+			Name:         name,
+			Type:         strings.ToLower(config.Get(prefix + "TYPE")),
+			ClientID:     config.Get(prefix + "CLIENT_ID"),
+			ClientSecret: config.Get(prefix + "CLIENT_SECRET"),
+			Issuer:       strings.TrimRight(config.Get(prefix+"ISSUER"), "/"),
+			APIURL:       strings.TrimRight(config.Get(prefix+"API_URL"), "/"),
+			RedirectURL:  config.Get("API_URL") + "/api/auth/oauth/" + name + "/callback",
+		}
+
+		switch name {
+		case "google":
+			if p.Issuer == "" {
+				p.Issuer = "https://accounts.google.com"
+			}
+		case "github":
+			if p.Type == "" {
+				p.Type = providerTypeGitHub
+			}
+		}
+		if p.Type == "" {
+			p.Type = providerTypeOIDC
+		}
+		if p.Type == providerTypeGitHub {
+			if p.Issuer == "" {
+				p.Issuer = "https://github.com"
+			}
+			if p.APIURL == "" {
+				p.APIURL = "https://api.github.com"
+			}
+		}
+
+		scopes := config.Get(prefix + "SCOPES")
+		if scopes == "" {
+			scopes = "openid email profile"
+			if p.Type == providerTypeGitHub {
+				scopes = "read:user user:email"
+			}
+		}
+		p.Scopes = strings.Fields(strings.ReplaceAll(scopes, ",", " "))
+
+		providers[name] = p
+	}
+
+	return providers
+}
+
+func (p *OAuthProvider) discover(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovered {
+		return nil
+	}
+
+	if p.Type == providerTypeGitHub {
+		p.authURL = p.Issuer + "/login/oauth/authorize"
+		p.tokenURL = p.Issuer + "/login/oauth/access_token"
+		p.discovered = true
+		return nil
+	}
+
+	var doc struct {
+		Issuer                string `json:"issuer"`
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+		UserinfoEndpoint      string `json:"userinfo_endpoint"`
+		JWKSURI               string `json:"jwks_uri"`
+	}
+	if err := getJSON(ctx, p.Issuer+"/.well-known/openid-configuration", "", &doc); err != nil {
+		return fmt.Errorf("oidc discovery for %s: %w", p.Name, err)
+	}
+	if strings.TrimRight(doc.Issuer, "/") != p.Issuer {
+		return fmt.Errorf("oidc discovery for %s: issuer mismatch %q", p.Name, doc.Issuer)
+	}
+
+	p.authURL = doc.AuthorizationEndpoint
+	p.tokenURL = doc.TokenEndpoint
+	p.userinfoURL = doc.UserinfoEndpoint
+	p.jwksURL = doc.JWKSURI
+	p.discovered = true
+	return nil
+}
+
+// AuthCodeURL builds the authorization request URL with PKCE (S256).
+func (p *OAuthProvider) AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error) {
+	if err := p.discover(ctx); err != nil {
+		return "", err
+	}
+
+	q := url.Values{}
+	q.Set("response_type", "code")
+	q.Set("client_id", p.ClientID)
+	q.Set("redirect_uri", p.RedirectURL)
+	q.Set("scope", strings.Join(p.Scopes, " "))
+	q.Set("state", state)
+	q.Set("code_challenge", codeChallenge)
+	q.Set("code_challenge_method", "S256")
+	if p.Type == providerTypeOIDC {
+		q.Set("nonce", nonce)
+	}
+
+	sep := "?"
+	if strings.Contains(p.authURL, "?") {
+		sep = "&"
+	}
+	return p.authURL + sep + q.Encode(), nil
+}
+
+// Exchange trades the authorization code for the user's identity.
+func (p *OAuthProvider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*ExternalIdentity, error) {
+	if err := p.discover(ctx); err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.RedirectURL)
+	form.Set("client_id", p.ClientID)
+	form.Set("client_secret", p.ClientSecret)
+	form.Set("code_verifier", codeVerifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	var tokens struct {
+		AccessToken string `json:"access_token"`
+		IDToken     string `json:"id_token"`
+		Error       string `json:"error"`
+	}
+	if err := doJSON(req, &tokens); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOAuthExchange, err)
+	}
+	if tokens.Error != "" || tokens.AccessToken == "" {
+		return nil, fmt.Errorf("%w: %s", ErrOAuthExchange, tokens.Error)
+	}
+
+	if p.Type == providerTypeGitHub {
+		return p.githubIdentity(ctx, tokens.AccessToken)
+	}
+	if tokens.IDToken == "" {
+		return nil, fmt.Errorf("%w: no id_token in response", ErrOAuthExchange)
+	}
+	identity, err := p.verifyIDToken(ctx, tokens.IDToken, nonce)
+	if err != nil {
+		return nil, err
+	}
+	if identity.Email == "" && p.userinfoURL != "" {
+		if err := p.addUserinfo(ctx, tokens.AccessToken, identity); err != nil {
+			return nil, err
+		}
+	}
+	return identity, nil
+}
+
+type idTokenClaims struct {
+	Email         string          `json:"email"`
+	EmailVerified json.RawMessage `json:"email_verified"`
+	Name          string          `json:"name"`
+	Nonce         string          `json:"nonce"`
+	AZP           string          `json:"azp"`
+	jwt.RegisteredClaims
+}
+
+func (p *OAuthProvider) verifyIDToken(ctx context.Context, raw, nonce string) (*ExternalIdentity, error) {
+	claims := &idTokenClaims{}
+	_, err := jwt.ParseWithClaims(raw, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return p.verificationKey(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"}),
+		jwt.WithIssuer(p.Issuer),
+		jwt.WithAudience(p.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithTimeFunc(Now),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: id_token: %v", ErrOAuthExchange, err)
+	}
+	if claims.Nonce != nonce {
+		return nil, fmt.Errorf("%w: id_token nonce mismatch", ErrOAuthExchange)
+	}
+	if claims.AZP != "" && claims.AZP != p.ClientID {
+		return nil, fmt.Errorf("%w: id_token azp mismatch", ErrOAuthExchange)
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: id_token without subject", ErrOAuthExchange)
+	}
+
+	return &ExternalIdentity{
+		Provider:      p.Name,
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: parseEmailVerified(claims.EmailVerified),
+		Name:          claims.Name,
+	}, nil
+}
+
+// addUserinfo fills in the email from the userinfo endpoint for providers
+// that leave it out of the id_token.
+func (p *OAuthProvider) addUserinfo(ctx context.Context, accessToken string, identity *ExternalIdentity) error {
+	var info struct {
+		Subject       string          `json:"sub"`
+		Email         string          `json:"email"`
+		EmailVerified json.RawMessage `json:"email_verified"`
+		Name          string          `json:"name"`
+	}
+	if err := getJSON(ctx, p.userinfoURL, accessToken, &info); err != nil {
+		return fmt.Errorf("%w: userinfo: %v", ErrOAuthExchange, err)
+	}
+	// The response must be about the user the id_token was issued for.
+	if info.Subject != identity.Subject {
+		return fmt.Errorf("%w: userinfo subject mismatch", ErrOAuthExchange)
+	}
+
+	identity.Email = info.Email
+	identity.EmailVerified = parseEmailVerified(info.EmailVerified)
+	if identity.Name == "" {
+		identity.Name = info.Name
+	}
+	return nil
+}
+
+// parseEmailVerified accepts both true and "true"; some providers send the
+// claim as a string.
+func parseEmailVerified(raw json.RawMessage) bool {
+	verified, _ := strconv.ParseBool(strings.Trim(string(raw), `"`))
+	return verified
+}
+
+// verificationKey looks up kid in the provider's JWKS. The set is refetched
+// when it is stale or does not contain kid, to pick up key rotations.
+func (p *OAuthProvider) verificationKey(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.jwks[kid]; ok && Now().Sub(p.jwksFetched) < jwksCacheTTL {
+		return key, nil
+	}
+
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := getJSON(ctx, p.jwksURL, "", &set); err != nil {
+		return nil, err
+	}
+
+	keys := map[string]crypto.PublicKey{}
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		var (
+			key crypto.PublicKey
+			err error
+		)
+		switch k.Kty {
+		case "RSA":
+			key, err = rsaKeyFromJWK(k.N, k.E)
+		case "EC":
+			key, err = ecKeyFromJWK(k.Crv, k.X, k.Y)
+		default:
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = key
+	}
+	p.jwks = keys
+	p.jwksFetched = Now()
+
+	key, ok := keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("no key %q in %s JWKS", kid, p.Name)
+	}
+	return key, nil
+}
+
+func rsaKeyFromJWK(n, e string) (*rsa.PublicKey, error) {
+	nb, err := base64.RawURLEncoding.DecodeString(n)
+	if err != nil {
+		return nil, err
+	}
+	eb, err := base64.RawURLEncoding.DecodeString(e)
+	if err != nil {
+		return nil, err
+	}
+	exp := new(big.Int).SetBytes(eb)
+	if !exp.IsInt64() || exp.Int64() > 1<<31-1 {
+		return nil, errors.New("invalid RSA exponent")
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(nb), E: int(exp.Int64())}, nil
+}
+
+func ecKeyFromJWK(crv, x, y string) (*ecdsa.PublicKey, error) {
+	var curve elliptic.Curve
+	switch crv {
+	case "P-256":
+		curve = elliptic.P256()
+	case "P-384":
+		curve = elliptic.P384()
+	case "P-521":
+		curve = elliptic.P521()
+	default:
+		return nil, fmt.Errorf("unsupported curve %q", crv)
+	}
+
+	xb, err := base64.RawURLEncoding.DecodeString(x)
+	if err != nil {
+		return nil, err
+	}
+	yb, err := base64.RawURLEncoding.DecodeString(y)
+	if err != nil {
+		return nil, err
+	}
+
+	key := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(xb), Y: new(big.Int).SetBytes(yb)}
+	if !curve.IsOnCurve(key.X, key.Y) {
+		return nil, errors.New("EC point not on curve")
+	}
+	return key, nil
+}
+
+func (p *OAuthProvider) githubIdentity(ctx context.Context, accessToken string) (*ExternalIdentity, error) {
+	var profile struct {
+		ID    int64  `json:"id"`
+		Login string `json:"login"`
+		Name  string `json:"name"`
+	}
+	if err := getJSON(ctx, p.APIURL+"/user", accessToken, &profile); err != nil {
+		return nil, err
+	}
+
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := getJSON(ctx, p.APIURL+"/user/emails", accessToken, &emails); err != nil {
+		return nil, err
+	}
+
+	identity := &ExternalIdentity{
+		Provider: p.Name,
+		Subject:  strconv.FormatInt(profile.ID, 10),
+		Name:     profile.Name,
+	}
+	if identity.Name == "" {
+		identity.Name = profile.Login
+	}
+	for _, e := range emails {
+		if e.Primary {
+			identity.Email = e.Email
+			identity.EmailVerified = e.Verified
+		}
+	}
+	return identity, nil
+}
+
+func getJSON(ctx context.Context, rawURL, bearer string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+bearer)
+	}
+	return doJSON(req, out)
+}
+
+func doJSON(req *http.Request, out interface{}) error {
+	resp, err := oauthHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s %s: status %d", req.Method, req.URL.Redacted(), resp.StatusCode)
+	}
+	return json.Unmarshal(body, out)
+}
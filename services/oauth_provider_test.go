@@ -0,0 +1,551 @@
+package services
+
+import (
+	"backend101/database"
+	"backend101/models"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	mockClientID     = "backend101"
+	mockClientSecret = "s3cret"
+	mockKeyID        = "mock-1"
+)
+
+// mockGrant is what the mock provider hands out for one authorization code.
+type mockGrant struct {
+	challenge string
+	// idToken holds extra id_token claims; iss, aud, exp and iat are filled
+	// in unless set.
+	idToken  jwt.MapClaims
+	userinfo map[string]interface{}
+	// GitHub API responses.
+	githubUser   map[string]interface{}
+	githubEmails []map[string]interface{}
+}
+
+// mockProvider is a local OIDC issuer (discovery, JWKS, token and userinfo)
+// that also answers GitHub's OAuth and REST endpoints.
+type mockProvider struct {
+	*httptest.Server
+	t   *testing.T
+	key *rsa.PrivateKey
+
+	mu     sync.Mutex
+	grants map[string]mockGrant
+	tokens map[string]mockGrant
+}
+
+func newMockProvider(t *testing.T) *mockProvider {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &mockProvider{t: t, key: key, grants: map[string]mockGrant{}, tokens: map[string]mockGrant{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]string{
+			"issuer":                 m.URL,
+			"authorization_endpoint": m.URL + "/authorize",
+			"token_endpoint":         m.URL + "/token",
+			"userinfo_endpoint":      m.URL + "/userinfo",
+			"jwks_uri":               m.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": mockKeyID,
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(m.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(m.key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("POST /token", m.token)
+	mux.HandleFunc("POST /login/oauth/access_token", m.token)
+	mux.HandleFunc("GET /userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if g, ok := m.bearer(w, r); ok {
+			writeJSON(w, g.userinfo)
+		}
+	})
+	mux.HandleFunc("GET /user", func(w http.ResponseWriter, r *http.Request) {
+		if g, ok := m.bearer(w, r); ok {
+			writeJSON(w, g.githubUser)
+		}
+	})
+	mux.HandleFunc("GET /user/emails", func(w http.ResponseWriter, r *http.Request) {
+		if g, ok := m.bearer(w, r); ok {
+			writeJSON(w, g.githubEmails)
+		}
+	})
+
+	m.Server = httptest.NewServer(mux)
+	t.Cleanup(m.Close)
+	return m
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// token redeems a code once, checking the client credentials and PKCE.
+func (m *mockProvider) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	m.mu.Lock()
+	g, ok := m.grants[r.Form.Get("code")]
+	delete(m.grants, r.Form.Get("code"))
+	m.mu.Unlock()
+
+	verifier := sha256.Sum256([]byte(r.Form.Get("code_verifier")))
+	switch {
+	case !ok:
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]string{"error": "invalid_grant"})
+		return
+	case r.Form.Get("client_id") != mockClientID || r.Form.Get("client_secret") != mockClientSecret:
+		w.WriteHeader(http.StatusUnauthorized)
+		writeJSON(w, map[string]string{"error": "invalid_client"})
+		return
+	case base64.RawURLEncoding.EncodeToString(verifier[:]) != g.challenge:
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	accessToken, err := generateOpaqueToken()
+	if err != nil {
+		m.t.Error(err)
+		return
+	}
+	m.mu.Lock()
+	m.tokens[accessToken] = g
+	m.mu.Unlock()
+
+	resp := map[string]string{"access_token": accessToken, "token_type": "bearer"}
+	if g.idToken != nil {
+		claims := jwt.MapClaims{
+			"iss": m.URL,
+			"aud": mockClientID,
+			"iat": time.Now().Unix(),
+			"exp": time.Now().Add(time.Hour).Unix(),
+		}
+		for k, v := range g.idToken {
+			claims[k] = v
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = mockKeyID
+		if resp["id_token"], err = token.SignedString(m.key); err != nil {
+			m.t.Error(err)
+			return
+		}
+	}
+	writeJSON(w, resp)
+}
+
+func (m *mockProvider) bearer(w http.ResponseWriter, r *http.Request) (mockGrant, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	g, ok := m.tokens[strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")]
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+	}
+	return g, ok
+}
+
+// grant registers code for a login that used codeVerifier.
+func (m *mockProvider) grant(code, codeVerifier string, g mockGrant) {
+	challenge := sha256.Sum256([]byte(codeVerifier))
+	g.challenge = base64.RawURLEncoding.EncodeToString(challenge[:])
+
+	m.mu.Lock()
+	m.grants[code] = g
+	m.mu.Unlock()
+}
+
+// authorize plays the browser and the provider's sign-in page: it follows
+// authURL and returns the state and code the provider redirects back with.
+func (m *mockProvider) authorize(t *testing.T, authURL string, g mockGrant) (state, code string) {
+	t.Helper()
+
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(authURL, m.URL+"/") {
+		t.Fatalf("auth URL %s is not on the mock provider", authURL)
+	}
+	q := u.Query()
+	if q.Get("client_id") != mockClientID || q.Get("code_challenge_method") != "S256" {
+		t.Fatalf("unexpected authorization request %s", authURL)
+	}
+
+	code, err = generateOpaqueToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.idToken != nil {
+		g.idToken["nonce"] = q.Get("nonce")
+	}
+	g.challenge = q.Get("code_challenge")
+
+	m.mu.Lock()
+	m.grants[code] = g
+	m.mu.Unlock()
+	return q.Get("state"), code
+}
+
+func (m *mockProvider) oidc(name string) *OAuthProvider {
+	return &OAuthProvider{
+		Name:         name,
+		Type:         providerTypeOIDC,
+		ClientID:     mockClientID,
+		ClientSecret: mockClientSecret,
+		Scopes:       []string{"openid", "email"},
+		RedirectURL:  "http://localhost/api/auth/oauth/" + name + "/callback",
+		Issuer:       m.URL,
+	}
+}
+
+func (m *mockProvider) github(name string) *OAuthProvider {
+	return &OAuthProvider{
+		Name:         name,
+		Type:         providerTypeGitHub,
+		ClientID:     mockClientID,
+		ClientSecret: mockClientSecret,
+		RedirectURL:  "http://localhost/api/auth/oauth/" + name + "/callback",
+		Issuer:       m.URL,
+		APIURL:       m.URL,
+	}
+}
+
+// useOAuthProviders replaces the configured providers for the rest of the
+// test.
+func useOAuthProviders(t *testing.T, providers ...*OAuthProvider) {
+	t.Helper()
+
+	oauthProvidersMu.Lock()
+	saved := oauthProviders
+	oauthProviders = map[string]*OAuthProvider{}
+	for _, p := range providers {
+		oauthProviders[p.Name] = p
+	}
+	oauthProvidersMu.Unlock()
+
+	t.Cleanup(func() {
+		oauthProvidersMu.Lock()
+		oauthProviders = saved
+		oauthProvidersMu.Unlock()
+	})
+}
+
+func TestOAuthExchangeOIDC(t *testing.T) {
+	mock := newMockProvider(t)
+
+	tests := []struct {
+		name    string
+		nonce   string
+		grant   mockGrant
+		want    *ExternalIdentity
+		wantErr bool
+	}{
+		{
+			name:  "verified email in id_token",
+			nonce: "n1",
+			grant: mockGrant{idToken: jwt.MapClaims{"sub": "u1", "nonce": "n1", "email": "ada@example.com", "email_verified": true, "name": "Ada"}},
+			want:  &ExternalIdentity{Provider: "corp", Subject: "u1", Email: "ada@example.com", EmailVerified: true, Name: "Ada"},
+		},
+		{
+			name:  "email_verified as a string",
+			nonce: "n1",
+			grant: mockGrant{idToken: jwt.MapClaims{"sub": "u1", "nonce": "n1", "email": "ada@example.com", "email_verified": "true"}},
+			want:  &ExternalIdentity{Provider: "corp", Subject: "u1", Email: "ada@example.com", EmailVerified: true},
+		},
+		{
+			name:  "unverified email",
+			nonce: "n1",
+			grant: mockGrant{idToken: jwt.MapClaims{"sub": "u1", "nonce": "n1", "email": "ada@example.com", "email_verified": false}},
+			want:  &ExternalIdentity{Provider: "corp", Subject: "u1", Email: "ada@example.com"},
+		},
+		{
+			name:  "email from userinfo",
+			nonce: "n1",
+			grant: mockGrant{
+				idToken:  jwt.MapClaims{"sub": "u1", "nonce": "n1"},
+				userinfo: map[string]interface{}{"sub": "u1", "email": "ada@example.com", "email_verified": true, "name": "Ada"},
+			},
+			want: &ExternalIdentity{Provider: "corp", Subject: "u1", Email: "ada@example.com", EmailVerified: true, Name: "Ada"},
+		},
+		{
+			name:  "userinfo for another subject",
+			nonce: "n1",
+			grant: mockGrant{
+				idToken:  jwt.MapClaims{"sub": "u1", "nonce": "n1"},
+				userinfo: map[string]interface{}{"sub": "u2", "email": "eve@example.com", "email_verified": true},
+			},
+			wantErr: true,
+		},
+		{
+			name:    "nonce mismatch",
+			nonce:   "n1",
+			grant:   mockGrant{idToken: jwt.MapClaims{"sub": "u1", "nonce": "replayed"}},
+			wantErr: true,
+		},
+		{
+			name:    "token for another client",
+			nonce:   "n1",
+			grant:   mockGrant{idToken: jwt.MapClaims{"sub": "u1", "nonce": "n1", "aud": "someone-else"}},
+			wantErr: true,
+		},
+		{
+			name:    "token from another issuer",
+			nonce:   "n1",
+			grant:   mockGrant{idToken: jwt.MapClaims{"sub": "u1", "nonce": "n1", "iss": "https://evil.example.com"}},
+			wantErr: true,
+		},
+		{
+			name:    "expired token",
+			nonce:   "n1",
+			grant:   mockGrant{idToken: jwt.MapClaims{"sub": "u1", "nonce": "n1", "exp": time.Now().Add(-time.Minute).Unix()}},
+			wantErr: true,
+		},
+		{
+			name:    "no subject",
+			nonce:   "n1",
+			grant:   mockGrant{idToken: jwt.MapClaims{"nonce": "n1", "email": "ada@example.com"}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := mock.oidc("corp")
+			mock.grant("code", "verifier", tt.grant)
+
+			got, err := p.Exchange(context.Background(), "code", "verifier", tt.nonce)
+			if tt.wantErr {
+				if !errors.Is(err, ErrOAuthExchange) {
+					t.Fatalf("Exchange() error = %v, want ErrOAuthExchange", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Exchange() error = %v", err)
+			}
+			if *got != *tt.want {
+				t.Errorf("Exchange() = %+v, want %+v", *got, *tt.want)
+			}
+		})
+	}
+}
+
+func TestOAuthExchangeRejectsWrongVerifier(t *testing.T) {
+	mock := newMockProvider(t)
+	p := mock.oidc("corp")
+	mock.grant("code", "verifier", mockGrant{idToken: jwt.MapClaims{"sub": "u1", "nonce": "n1"}})
+
+	if _, err := p.Exchange(context.Background(), "code", "stolen", "n1"); !errors.Is(err, ErrOAuthExchange) {
+		t.Fatalf("Exchange() error = %v, want ErrOAuthExchange", err)
+	}
+}
+
+func TestOAuthExchangeGitHub(t *testing.T) {
+	mock := newMockProvider(t)
+	p := mock.github("github")
+
+	authURL, err := p.AuthCodeURL(context.Background(), "state", "nonce", "challenge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(authURL, mock.URL+"/login/oauth/authorize?") {
+		t.Errorf("AuthCodeURL() = %s, want the configured host", authURL)
+	}
+
+	mock.grant("code", "verifier", mockGrant{
+		githubUser: map[string]interface{}{"id": 42, "login": "ada"},
+		githubEmails: []map[string]interface{}{
+			{"email": "old@example.com", "primary": false, "verified": true},
+			{"email": "ada@example.com", "primary": true, "verified": true},
+		},
+	})
+	got, err := p.Exchange(context.Background(), "code", "verifier", "")
+	if err != nil {
+		t.Fatalf("Exchange() error = %v", err)
+	}
+	want := ExternalIdentity{Provider: "github", Subject: "42", Email: "ada@example.com", EmailVerified: true, Name: "ada"}
+	if *got != want {
+		t.Errorf("Exchange() = %+v, want %+v", *got, want)
+	}
+}
+
+func TestLoadOAuthProvidersGitHubURLs(t *testing.T) {
+	setConfig(t, "OAUTH_PROVIDERS", "github,ghe")
+	setConfig(t, "OAUTH_GHE_TYPE", "github")
+	setConfig(t, "OAUTH_GHE_ISSUER", "https://ghe.example.com/")
+	setConfig(t, "OAUTH_GHE_API_URL", "https://ghe.example.com/api/v3")
+
+	providers := loadOAuthProviders()
+	tests := []struct {
+		name, issuer, api string
+	}{
+		{"github", "https://github.com", "https://api.github.com"},
+		{"ghe", "https://ghe.example.com", "https://ghe.example.com/api/v3"},
+	}
+	for _, tt := range tests {
+		p := providers[tt.name]
+		if p == nil {
+			t.Fatalf("provider %s not loaded", tt.name)
+		}
+		if p.Type != providerTypeGitHub || p.Issuer != tt.issuer || p.APIURL != tt.api {
+			t.Errorf("%s: type %q issuer %q api %q, want github %q %q", tt.name, p.Type, p.Issuer, p.APIURL, tt.issuer, tt.api)
+		}
+	}
+}
+
+func TestOAuthCallbackStateIsSingleUse(t *testing.T) {
+	mock := newMockProvider(t)
+	useOAuthProviders(t, mock.oidc("corp"))
+	ctx := context.Background()
+
+	if _, err := CompleteOAuthLogin(ctx, "corp", "forged", "code"); !errors.Is(err, ErrInvalidOAuthState) {
+		t.Fatalf("unknown state: error = %v, want ErrInvalidOAuthState", err)
+	}
+
+	authURL, err := BeginOAuthLogin(ctx, "corp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, _ := mock.authorize(t, authURL, mockGrant{idToken: jwt.MapClaims{"sub": "u1"}})
+
+	// The provider rejects the code, but the state is spent either way.
+	if _, err := CompleteOAuthLogin(ctx, "corp", state, "wrong-code"); !errors.Is(err, ErrOAuthExchange) {
+		t.Fatalf("first callback: error = %v, want ErrOAuthExchange", err)
+	}
+	if _, err := CompleteOAuthLogin(ctx, "corp", state, "wrong-code"); !errors.Is(err, ErrInvalidOAuthState) {
+		t.Fatalf("replayed callback: error = %v, want ErrInvalidOAuthState", err)
+	}
+}
+
+// oauthLogin runs the whole redirect flow against the mock provider.
+func oauthLogin(t *testing.T, mock *mockProvider, provider string, g mockGrant) (*models.User, error) {
+	t.Helper()
+	ctx := context.Background()
+
+	authURL, err := BeginOAuthLogin(ctx, provider)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, code := mock.authorize(t, authURL, g)
+	return CompleteOAuthLogin(ctx, provider, state, code)
+}
+
+func TestOAuthCallbackLinksVerifiedEmail(t *testing.T) {
+	useTestDB(t)
+	mock := newMockProvider(t)
+	useOAuthProviders(t, mock.oidc("corp"), mock.github("github"))
+
+	now := Now()
+	existing := models.User{Name: "Ada", Email: "ada@example.com", Password: "hash", EmailVerifiedAt: &now}
+	if err := database.DB.Create(&existing).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// First identity: linked to the existing account by verified email.
+	user, err := oauthLogin(t, mock, "corp", mockGrant{idToken: jwt.MapClaims{
+		"sub": "corp-1", "email": "ADA@example.com", "email_verified": true,
+	}})
+	if err != nil {
+		t.Fatalf("corp login: %v", err)
+	}
+	if user.ID != existing.ID {
+		t.Fatalf("corp login: user %d, want existing user %d", user.ID, existing.ID)
+	}
+
+	// Second identity on the same user, from another provider.
+	user, err = oauthLogin(t, mock, "github", mockGrant{
+		githubUser:   map[string]interface{}{"id": 7, "login": "ada"},
+		githubEmails: []map[string]interface{}{{"email": "ada@example.com", "primary": true, "verified": true}},
+	})
+	if err != nil {
+		t.Fatalf("github login: %v", err)
+	}
+	if user.ID != existing.ID {
+		t.Fatalf("github login: user %d, want existing user %d", user.ID, existing.ID)
+	}
+
+	identities, err := ListIdentities(existing.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(identities) != 2 || identities[0].Provider != "corp" || identities[1].Provider != "github" {
+		t.Fatalf("identities = %+v, want corp and github", identities)
+	}
+
+	// A linked identity logs in by subject, even after the email changed.
+	user, err = oauthLogin(t, mock, "corp", mockGrant{idToken: jwt.MapClaims{
+		"sub": "corp-1", "email": "ada@new.example.com", "email_verified": true,
+	}})
+	if err != nil {
+		t.Fatalf("repeat corp login: %v", err)
+	}
+	if user.ID != existing.ID {
+		t.Fatalf("repeat corp login: user %d, want existing user %d", user.ID, existing.ID)
+	}
+
+	var count int64
+	database.DB.Model(&models.User{}).Where("LOWER(email) LIKE ?", "ada@%").Count(&count)
+	if count != 1 {
+		t.Errorf("%d users for ada, want 1", count)
+	}
+}
+
+func TestOAuthCallbackRejectsUnverifiedEmail(t *testing.T) {
+	useTestDB(t)
+	mock := newMockProvider(t)
+	useOAuthProviders(t, mock.oidc("corp"))
+
+	now := Now()
+	existing := models.User{Name: "Ada", Email: "ada@example.com", Password: "hash", EmailVerifiedAt: &now}
+	if err := database.DB.Create(&existing).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := oauthLogin(t, mock, "corp", mockGrant{idToken: jwt.MapClaims{
+		"sub": "corp-1", "email": "ada@example.com", "email_verified": false,
+	}})
+	if !errors.Is(err, ErrExternalEmailUnverified) {
+		t.Fatalf("error = %v, want ErrExternalEmailUnverified", err)
+	}
+
+	identities, err := ListIdentities(existing.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(identities) != 0 {
+		t.Errorf("identities = %+v, want none", identities)
+	}
+}
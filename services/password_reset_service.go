@@ -0,0 +1,102 @@
+package services
+
+import (
+	"backend101/config"
+	"backend101/database"
+	"backend101/mailer"
+	"backend101/models"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func passwordResetTTL() time.Duration {
+	return time.Minute * time.Duration(config.GetInt("PASSWORD_RESET_EXPIRE_MINUTES"))
+}
+
+// appLink builds a link to the frontend, e.g. appLink("/reset-password", token).
+func appLink(path, token string) string {
+	return config.Get("APP_URL") + path + "?token=" + url.QueryEscape(token)
+}
+
+// sendMailAsync delivers mail in the background so that response times do
+// not reveal whether an address is registered.
+func sendMailAsync(msg mailer.Message) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := mailer.Send(ctx, msg); err != nil {
+			log.Printf("❌ Failed to send %q to %s: %v", msg.Subject, msg.To, err)
+		}
+	}()
+}
+
+// RequestPasswordReset emails a reset link if the address belongs to a user.
+// Unknown addresses are silently ignored.
+func RequestPasswordReset(email string) error {
+	var user models.User
+	err := database.DB.Where("email = ?", email).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	ttl := passwordResetTTL()
+	token, err := IssueUserToken(user.ID, models.TokenPurposePasswordReset, ttl)
+	if err != nil {
+		return err
+	}
+
+	sendMailAsync(mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"Use the link below to choose a new password. It expires in %d minutes and can only be used once.\n\n"+
+			"%s\n\n"+
+			"If you did not ask for a password reset you can ignore this email.\n",
+			user.Name, int(ttl.Minutes()), appLink("/reset-password", token)),
+	})
+	return nil
+}
+
+// ResetPassword redeems a reset token, sets the new password and signs the
+// user out of every device.
+func ResetPassword(rawToken, newPassword string) error {
+	hashed, err := HashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+
+	var userID uint
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		token, err := consumeUserToken(tx, rawToken, models.TokenPurposePasswordReset)
+		if err != nil {
+			return err
+		}
+		userID = token.UserID
+
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"password":                hashed,
+			"password_reset_required": false,
+		}).Error; err != nil {
+			return err
+		}
+
+		// Redeeming the link proves control of the mailbox.
+		return tx.Model(&models.User{}).
+			Where("id = ? AND email_verified_at IS NULL", userID).
+			Update("email_verified_at", Now()).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	return RevokeAllUserTokens(context.Background(), userID)
+}
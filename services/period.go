@@ -0,0 +1,77 @@
+package services
+
+import (
+	"backend101/models"
+	"errors"
+	"time"
+)
+
+const (
+	PeriodAll   = "all"
+	PeriodWeek  = "week"
+	PeriodMonth = "month"
+	PeriodYear  = "year"
+)
+
+var ErrInvalidPeriod = errors.New("invalid period")
+
+// Period is the half-open interval [From, To).
+type Period struct {
+	Name string    `json:"period"`
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// UserLocation returns the time zone from the preferences, falling back to
+// UTC if it cannot be loaded.
+func UserLocation(prefs *models.UserPreference) *time.Location {
+	loc, err := time.LoadLocation(prefs.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// CurrentPeriod returns the week, month or year containing now, as seen in
+// the user's time zone. Weeks begin on prefs.WeekStart and months on
+// prefs.MonthStartDay. It returns nil for PeriodAll.
+func CurrentPeriod(name string, prefs *models.UserPreference, now time.Time) (*Period, error) {
+	now = now.In(UserLocation(prefs))
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	var from, to time.Time
+	switch name {
+	case "", PeriodAll:
+		return nil, nil
+	case PeriodWeek:
+		offset := (int(today.Weekday()) - weekdayIndex(prefs.WeekStart) + 7) % 7
+		from = today.AddDate(0, 0, -offset)
+		to = from.AddDate(0, 0, 7)
+	case PeriodMonth:
+		startDay := prefs.MonthStartDay
+		if startDay < 1 {
+			startDay = 1
+		}
+		from = time.Date(today.Year(), today.Month(), startDay, 0, 0, 0, 0, today.Location())
+		if today.Day() < startDay {
+			from = from.AddDate(0, -1, 0)
+		}
+		to = from.AddDate(0, 1, 0)
+	case PeriodYear:
+		from = time.Date(today.Year(), time.January, 1, 0, 0, 0, 0, today.Location())
+		to = from.AddDate(1, 0, 0)
+	default:
+		return nil, ErrInvalidPeriod
+	}
+
+	return &Period{Name: name, From: from, To: to}, nil
+}
+
+func weekdayIndex(name string) int {
+	for i, day := range models.Weekdays {
+		if day == name {
+			return i
+		}
+	}
+	return int(time.Monday)
+}
@@ -0,0 +1,152 @@
+package services
+
+import (
+	"backend101/models"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCurrentPeriod(t *testing.T) {
+	load := func(name string) *time.Location {
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			t.Skip(err)
+		}
+		return loc
+	}
+	tokyo, berlin := load("Asia/Tokyo"), load("Europe/Berlin")
+	at := func(loc *time.Location, year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 0, 0, 0, 0, loc)
+	}
+	prefs := func(timezone, weekStart string, monthStartDay int) *models.UserPreference {
+		p := models.DefaultPreferences(1)
+		p.Timezone, p.WeekStart, p.MonthStartDay = timezone, weekStart, monthStartDay
+		return &p
+	}
+
+	tests := []struct {
+		name   string
+		period string
+		prefs  *models.UserPreference
+		now    time.Time
+		from   time.Time
+		to     time.Time
+	}{
+		{
+			"month in UTC", PeriodMonth, prefs("UTC", "monday", 1),
+			time.Date(2026, 10, 31, 16, 0, 0, 0, time.UTC),
+			at(time.UTC, 2026, 10, 1), at(time.UTC, 2026, 11, 1),
+		},
+		{
+			// 16:00 UTC on 31 October is already 1 November in Tokyo.
+			"month ahead of UTC after midnight", PeriodMonth, prefs("Asia/Tokyo", "monday", 1),
+			time.Date(2026, 10, 31, 16, 0, 0, 0, time.UTC),
+			at(tokyo, 2026, 11, 1), at(tokyo, 2026, 12, 1),
+		},
+		{
+			"month ahead of UTC before midnight", PeriodMonth, prefs("Asia/Tokyo", "monday", 1),
+			time.Date(2026, 10, 31, 14, 59, 59, 0, time.UTC),
+			at(tokyo, 2026, 10, 1), at(tokyo, 2026, 11, 1),
+		},
+		{
+			"before the month start day", PeriodMonth, prefs("UTC", "monday", 25),
+			time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC),
+			at(time.UTC, 2026, 9, 25), at(time.UTC, 2026, 10, 25),
+		},
+		{
+			"on the month start day", PeriodMonth, prefs("UTC", "monday", 25),
+			time.Date(2026, 10, 25, 0, 0, 0, 0, time.UTC),
+			at(time.UTC, 2026, 10, 25), at(time.UTC, 2026, 11, 25),
+		},
+		{
+			"before the month start day in January", PeriodMonth, prefs("UTC", "monday", 25),
+			time.Date(2027, 1, 10, 12, 0, 0, 0, time.UTC),
+			at(time.UTC, 2026, 12, 25), at(time.UTC, 2027, 1, 25),
+		},
+		{
+			"month start day reached in Tokyo only", PeriodMonth, prefs("Asia/Tokyo", "monday", 25),
+			time.Date(2026, 10, 24, 20, 0, 0, 0, time.UTC),
+			at(tokyo, 2026, 10, 25), at(tokyo, 2026, 11, 25),
+		},
+		{
+			"unset month start day", PeriodMonth, prefs("UTC", "monday", 0),
+			time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC),
+			at(time.UTC, 2026, 10, 1), at(time.UTC, 2026, 11, 1),
+		},
+		{
+			// 18 October 2026 is a Sunday.
+			"week from Monday on a Sunday", PeriodWeek, prefs("UTC", "monday", 1),
+			time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC),
+			at(time.UTC, 2026, 10, 12), at(time.UTC, 2026, 10, 19),
+		},
+		{
+			"week from Sunday on a Sunday", PeriodWeek, prefs("UTC", "sunday", 1),
+			time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC),
+			at(time.UTC, 2026, 10, 18), at(time.UTC, 2026, 10, 25),
+		},
+		{
+			"week from Saturday", PeriodWeek, prefs("UTC", "saturday", 1),
+			time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC),
+			at(time.UTC, 2026, 10, 17), at(time.UTC, 2026, 10, 24),
+		},
+		{
+			"week from Wednesday", PeriodWeek, prefs("UTC", "wednesday", 1),
+			time.Date(2026, 10, 20, 12, 0, 0, 0, time.UTC),
+			at(time.UTC, 2026, 10, 14), at(time.UTC, 2026, 10, 21),
+		},
+		{
+			"unknown week start means Monday", PeriodWeek, prefs("UTC", "someday", 1),
+			time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC),
+			at(time.UTC, 2026, 10, 12), at(time.UTC, 2026, 10, 19),
+		},
+		{
+			// Sunday 15:30 UTC is Monday 00:30 in Tokyo.
+			"week ahead of UTC after midnight", PeriodWeek, prefs("Asia/Tokyo", "monday", 1),
+			time.Date(2026, 10, 18, 15, 30, 0, 0, time.UTC),
+			at(tokyo, 2026, 10, 19), at(tokyo, 2026, 10, 26),
+		},
+		{
+			// Summer time ends on 25 October; the week is an hour longer.
+			"week across a DST change", PeriodWeek, prefs("Europe/Berlin", "monday", 1),
+			time.Date(2026, 10, 25, 12, 0, 0, 0, time.UTC),
+			at(berlin, 2026, 10, 19), at(berlin, 2026, 10, 26),
+		},
+		{
+			"year ahead of UTC after midnight", PeriodYear, prefs("Asia/Tokyo", "monday", 1),
+			time.Date(2026, 12, 31, 15, 0, 0, 0, time.UTC),
+			at(tokyo, 2027, 1, 1), at(tokyo, 2028, 1, 1),
+		},
+		{
+			"unknown time zone falls back to UTC", PeriodMonth, prefs("Mars/Olympus", "monday", 1),
+			time.Date(2026, 10, 31, 16, 0, 0, 0, time.UTC),
+			at(time.UTC, 2026, 10, 1), at(time.UTC, 2026, 11, 1),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CurrentPeriod(tt.period, tt.prefs, tt.now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Name != tt.period || !got.From.Equal(tt.from) || !got.To.Equal(tt.to) {
+				t.Errorf("CurrentPeriod() = %s %s - %s, want %s - %s", got.Name, got.From, got.To, tt.from, tt.to)
+			}
+		})
+	}
+}
+
+func TestCurrentPeriodAll(t *testing.T) {
+	prefs := models.DefaultPreferences(1)
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+	for _, name := range []string{"", PeriodAll} {
+		if got, err := CurrentPeriod(name, &prefs, now); got != nil || err != nil {
+			t.Errorf("CurrentPeriod(%q) = %+v, %v; want nil", name, got, err)
+		}
+	}
+	if _, err := CurrentPeriod("fortnight", &prefs, now); !errors.Is(err, ErrInvalidPeriod) {
+		t.Errorf("error = %v, want ErrInvalidPeriod", err)
+	}
+}
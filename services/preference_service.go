@@ -0,0 +1,68 @@
+package services
+
+import (
+	"backend101/database"
+	"backend101/models"
+	"errors"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// GetPreferences returns the user's preferences, or the defaults if they
+// never changed any.
+func GetPreferences(userID uint) (*models.UserPreference, error) {
+	return getPreferences(database.DB, userID)
+}
+
+func getPreferences(tx *gorm.DB, userID uint) (*models.UserPreference, error) {
+	prefs := models.DefaultPreferences(userID)
+	err := tx.Where("user_id = ?", userID).First(&prefs).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	return &prefs, nil
+}
+
+// UpdatePreferences applies the fields that are set in req.
+func UpdatePreferences(userID uint, req models.UpdatePreferencesRequest) (*models.UserPreference, error) {
+	var prefs *models.UserPreference
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		prefs, err = updatePreferences(tx, userID, req)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return prefs, nil
+}
+
+func updatePreferences(tx *gorm.DB, userID uint, req models.UpdatePreferencesRequest) (*models.UserPreference, error) {
+	prefs, err := getPreferences(tx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Currency != nil {
+		prefs.Currency = strings.ToUpper(*req.Currency)
+	}
+	if req.Timezone != nil {
+		prefs.Timezone = *req.Timezone
+	}
+	if req.Locale != nil {
+		prefs.Locale = *req.Locale
+	}
+	if req.WeekStart != nil {
+		prefs.WeekStart = *req.WeekStart
+	}
+	if req.MonthStartDay != nil {
+		prefs.MonthStartDay = *req.MonthStartDay
+	}
+
+	if err := tx.Save(prefs).Error; err != nil {
+		return nil, err
+	}
+	return prefs, nil
+}
@@ -0,0 +1,183 @@
+package services
+
+import (
+	"backend101/config"
+	"backend101/database"
+	"backend101/mailer"
+	"backend101/models"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrEmailTaken = errors.New("email is already registered")
+	ErrEmptyName  = errors.New("name must not be empty")
+)
+
+// GetProfile returns the user together with their preferences.
+func GetProfile(userID uint) (*models.User, error) {
+	var user models.User
+	if err := database.DB.Preload("Preferences").First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+	if user.Preferences == nil {
+		prefs := models.DefaultPreferences(user.ID)
+		user.Preferences = &prefs
+	}
+	return &user, nil
+}
+
+// UpdateProfile applies the fields that are set in req, all or none of
+// them.
+func UpdateProfile(userID uint, req models.UpdateProfileRequest) (*models.User, error) {
+	updates := map[string]interface{}{}
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			return nil, ErrEmptyName
+		}
+		updates["name"] = name
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if len(updates) > 0 {
+			if err := tx.Model(&models.User{}).Where("id = ?", userID).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+		if req.Preferences != nil {
+			if _, err := updatePreferences(tx, userID, *req.Preferences); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return GetProfile(userID)
+}
+
+// ChangePassword sets a new password after checking the current one, and
+// signs out every session except keepSessionID.
+func ChangePassword(ctx context.Context, userID uint, keepSessionID, currentPassword, newPassword string) error {
+	user, err := GetProfile(userID)
+	if err != nil {
+		return err
+	}
+	if !VerifyUserPassword(user, currentPassword) {
+		return ErrInvalidPassword
+	}
+
+	hashed, err := HashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+	if err := database.DB.Model(user).Updates(map[string]interface{}{
+		"password":                hashed,
+		"password_reset_required": false,
+	}).Error; err != nil {
+		return err
+	}
+
+	sendMailAsync(mailer.Message{
+		To:      user.Email,
+		Subject: "Your password was changed",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"The password for your account was just changed and your other devices were signed out.\n\n"+
+			"If this wasn't you, reset your password right away:\n\n%s\n",
+			user.Name, config.Get("APP_URL")+"/forgot-password"),
+	})
+
+	return RevokeOtherSessions(ctx, userID, keepSessionID)
+}
+
+// RequestEmailChange emails a confirmation link to the new address. The
+// account keeps its current email until the link is opened.
+func RequestEmailChange(userID uint, password, newEmail string) error {
+	user, err := GetProfile(userID)
+	if err != nil {
+		return err
+	}
+	if !VerifyUserPassword(user, password) {
+		return ErrInvalidPassword
+	}
+	if err := ensureEmailAvailable(database.DB, newEmail); err != nil {
+		return err
+	}
+
+	token, err := issueUserToken(user.ID, models.TokenPurposeEmailChange, newEmail, emailVerificationTTL())
+	if err != nil {
+		return err
+	}
+
+	sendMailAsync(mailer.Message{
+		To:      newEmail,
+		Subject: "Confirm your new email address",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"Open the link below to use this address for your account:\n\n"+
+			"%s\n\n"+
+			"If you did not ask for this change you can ignore this email.\n",
+			user.Name, appLink("/confirm-email-change", token)),
+	})
+	return nil
+}
+
+// ConfirmEmailChange redeems an email change token, switches the address and
+// lets the old address know.
+func ConfirmEmailChange(rawToken string) error {
+	var user models.User
+	var oldEmail, newEmail string
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		token, err := consumeUserToken(tx, rawToken, models.TokenPurposeEmailChange)
+		if err != nil {
+			return err
+		}
+		if err := tx.First(&user, token.UserID).Error; err != nil {
+			return err
+		}
+		// The address may have been registered since the link was sent.
+		if err := ensureEmailAvailable(tx, token.Payload); err != nil {
+			return err
+		}
+
+		oldEmail, newEmail = user.Email, token.Payload
+		// Opening the link proves control of the new mailbox.
+		return tx.Model(&user).Updates(map[string]interface{}{
+			"email":             newEmail,
+			"email_verified_at": Now(),
+		}).Error
+	})
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return ErrEmailTaken
+	}
+	if err != nil {
+		return err
+	}
+
+	sendMailAsync(mailer.Message{
+		To:      oldEmail,
+		Subject: "Your email address was changed",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"The email address of your account was changed to %s.\n\n"+
+			"If this wasn't you, please contact support.\n",
+			user.Name, newEmail),
+	})
+	return nil
+}
+
+func ensureEmailAvailable(tx *gorm.DB, email string) error {
+	var count int64
+	if err := tx.Model(&models.User{}).Where("email = ?", email).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrEmailTaken
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package services
+
+import (
+	"backend101/database"
+	"backend101/models"
+	"errors"
+	"testing"
+)
+
+func TestUpdateProfileRejectsBlankName(t *testing.T) {
+	for _, name := range []string{"", "   ", "\t\n"} {
+		if _, err := UpdateProfile(1, models.UpdateProfileRequest{Name: &name}); !errors.Is(err, ErrEmptyName) {
+			t.Errorf("name %q: error = %v, want ErrEmptyName", name, err)
+		}
+	}
+}
+
+func TestUpdateProfileIsAllOrNothing(t *testing.T) {
+	useTestDB(t)
+	user := createTestUser(t, "profile@example.com")
+
+	name := "  Ada Lovelace  "
+	currency := "EURO" // too long for the column
+	_, err := UpdateProfile(user.ID, models.UpdateProfileRequest{
+		Name:        &name,
+		Preferences: &models.UpdatePreferencesRequest{Currency: &currency},
+	})
+	if err == nil {
+		t.Fatal("UpdateProfile() accepted an invalid currency")
+	}
+
+	var stored models.User
+	if err := database.DB.First(&stored, user.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored.Name != user.Name {
+		t.Errorf("name = %q after a failed update, want %q", stored.Name, user.Name)
+	}
+
+	currency = "EUR"
+	updated, err := UpdateProfile(user.ID, models.UpdateProfileRequest{
+		Name:        &name,
+		Preferences: &models.UpdatePreferencesRequest{Currency: &currency},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Name != "Ada Lovelace" || updated.Preferences.Currency != "EUR" {
+		t.Errorf("profile = %q, %s; want %q, EUR", updated.Name, updated.Preferences.Currency, "Ada Lovelace")
+	}
+}
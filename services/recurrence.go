@@ -0,0 +1,243 @@
+package services
+
+import (
+	"backend101/models"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Occurrences are searched at most this many periods ahead, so a rule that
+// never matches cannot loop forever.
+const maxRecurrencePeriods = 10000
+
+var ErrInvalidRecurrence = errors.New("invalid recurrence")
+
+var weekdayCodes = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+// weekdayRule is one BYDAY entry: a weekday with an optional ordinal, e.g.
+// -1FR for the last Friday of the month.
+type weekdayRule struct {
+	n   int
+	day time.Weekday
+}
+
+// schedule is a parsed recurrence. Dates are midnight UTC.
+type schedule struct {
+	freq       string
+	interval   int
+	start      time.Time
+	byDay      []weekdayRule
+	byMonthDay int
+}
+
+func invalidRecurrence(msg string) error {
+	return fmt.Errorf("%w: %s", ErrInvalidRecurrence, msg)
+}
+
+// parseSchedule checks a rule's recurrence fields and fills in the defaults
+// RRULE takes from the start date: its weekday for weekly rules and its day
+// of the month for monthly ones.
+func parseSchedule(rule *models.RecurringRule) (*schedule, error) {
+	s := &schedule{freq: rule.Frequency, interval: rule.Interval, start: rule.StartDate}
+	if s.interval < 1 {
+		return nil, invalidRecurrence("interval must be at least 1")
+	}
+
+	for _, code := range strings.Split(rule.ByDay, ",") {
+		code = strings.ToUpper(strings.TrimSpace(code))
+		if code == "" {
+			continue
+		}
+		if len(code) < 2 {
+			return nil, invalidRecurrence("by_day entries look like MO or 2MO")
+		}
+		day, ok := weekdayCodes[code[len(code)-2:]]
+		if !ok {
+			return nil, invalidRecurrence("by_day entries look like MO or 2MO")
+		}
+		w := weekdayRule{day: day}
+		if prefix := code[:len(code)-2]; prefix != "" {
+			n, err := strconv.Atoi(prefix)
+			if err != nil || n == 0 || n < -5 || n > 5 {
+				return nil, invalidRecurrence("by_day ordinals must be between -5 and 5, e.g. 1MO or -1FR")
+			}
+			w.n = n
+		}
+		s.byDay = append(s.byDay, w)
+	}
+	if rule.ByMonthDay != nil {
+		s.byMonthDay = *rule.ByMonthDay
+		if s.byMonthDay == 0 || s.byMonthDay < -31 || s.byMonthDay > 31 {
+			return nil, invalidRecurrence("by_month_day must be between 1 and 31, or -1 to -31 counting from the end")
+		}
+	}
+
+	switch s.freq {
+	case models.FrequencyDaily, models.FrequencyYearly:
+		if len(s.byDay) > 0 || s.byMonthDay != 0 {
+			return nil, invalidRecurrence("by_day and by_month_day are only allowed for weekly and monthly rules")
+		}
+	case models.FrequencyWeekly:
+		if s.byMonthDay != 0 {
+			return nil, invalidRecurrence("by_month_day is only allowed for monthly rules")
+		}
+		for _, w := range s.byDay {
+			if w.n != 0 {
+				return nil, invalidRecurrence("weekly by_day entries cannot have an ordinal")
+			}
+		}
+		if len(s.byDay) == 0 {
+			s.byDay = []weekdayRule{{day: s.start.Weekday()}}
+		}
+	case models.FrequencyMonthly:
+		if len(s.byDay) > 0 && s.byMonthDay != 0 {
+			return nil, invalidRecurrence("use either by_day or by_month_day")
+		}
+		for _, w := range s.byDay {
+			if w.n == 0 {
+				return nil, invalidRecurrence("monthly by_day entries need an ordinal, e.g. 1MO or -1FR")
+			}
+		}
+		if len(s.byDay) == 0 && s.byMonthDay == 0 {
+			s.byMonthDay = s.start.Day()
+		}
+	default:
+		return nil, invalidRecurrence("frequency must be daily, weekly, monthly or yearly")
+	}
+	return s, nil
+}
+
+func daysIn(year int, month time.Month) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+// mondayOf returns the Monday of the week of d; weeks start on Monday as in
+// RRULE's default WKST.
+func mondayOf(d time.Time) time.Time {
+	return d.AddDate(0, 0, -((int(d.Weekday()) + 6) % 7))
+}
+
+// period returns the index of the period containing d, counted in units of
+// the frequency from the period containing the start date.
+func (s *schedule) period(d time.Time) int {
+	switch s.freq {
+	case models.FrequencyDaily:
+		return int(d.Sub(s.start).Hours() / 24)
+	case models.FrequencyWeekly:
+		return int(mondayOf(d).Sub(mondayOf(s.start)).Hours() / (24 * 7))
+	case models.FrequencyMonthly:
+		return (d.Year()-s.start.Year())*12 + int(d.Month()) - int(s.start.Month())
+	default:
+		return d.Year() - s.start.Year()
+	}
+}
+
+// candidates returns the dates the rule matches in period p, in order. Days
+// past the end of a short month fall on its last day, so a rule for the
+// 31st still fires in February.
+func (s *schedule) candidates(p int) []time.Time {
+	switch s.freq {
+	case models.FrequencyDaily:
+		return []time.Time{s.start.AddDate(0, 0, p)}
+
+	case models.FrequencyWeekly:
+		monday := mondayOf(s.start).AddDate(0, 0, 7*p)
+		dates := make([]time.Time, 0, len(s.byDay))
+		for _, w := range s.byDay {
+			dates = append(dates, monday.AddDate(0, 0, (int(w.day)+6)%7))
+		}
+		sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+		return dates
+
+	case models.FrequencyMonthly:
+		first := time.Date(s.start.Year(), s.start.Month()+time.Month(p), 1, 0, 0, 0, 0, time.UTC)
+		last := daysIn(first.Year(), first.Month())
+		if s.byMonthDay != 0 {
+			day := s.byMonthDay
+			if day < 0 {
+				day = last + day + 1
+			}
+			day = min(max(day, 1), last)
+			return []time.Time{first.AddDate(0, 0, day-1)}
+		}
+
+		var dates []time.Time
+		for _, w := range s.byDay {
+			offset := (int(w.day) - int(first.Weekday()) + 7) % 7
+			day := 1 + offset + 7*(w.n-1)
+			if w.n < 0 {
+				lastDay := first.AddDate(0, 0, last-1)
+				day = last - (int(lastDay.Weekday())-int(w.day)+7)%7 + 7*(w.n+1)
+			}
+			if day >= 1 && day <= last {
+				dates = append(dates, first.AddDate(0, 0, day-1))
+			}
+		}
+		sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+		return dates
+
+	default:
+		year := s.start.Year() + p
+		day := min(s.start.Day(), daysIn(year, s.start.Month()))
+		return []time.Time{time.Date(year, s.start.Month(), day, 0, 0, 0, 0, time.UTC)}
+	}
+}
+
+// next returns the first occurrence after the given date, or the zero time
+// if there is none within maxRecurrencePeriods.
+func (s *schedule) next(after time.Time) time.Time {
+	if after.Before(s.start) {
+		after = s.start.AddDate(0, 0, -1)
+	}
+
+	p := s.period(after)
+	p -= ((p % s.interval) + s.interval) % s.interval
+	for i := 0; i < maxRecurrencePeriods; i++ {
+		for _, d := range s.candidates(p) {
+			if d.After(after) && !d.Before(s.start) {
+				return d
+			}
+		}
+		p += s.interval
+	}
+	return time.Time{}
+}
+
+// endsOn returns the last date the rule can occur on given until and count,
+// or nil if it never ends.
+func (s *schedule) endsOn(until *time.Time, count *int) *time.Time {
+	var end *time.Time
+	if until != nil {
+		u := *until
+		end = &u
+	}
+	if count != nil {
+		d := s.start.AddDate(0, 0, -1)
+		for i := 0; i < *count; i++ {
+			if d = s.next(d); d.IsZero() {
+				break
+			}
+		}
+		if end == nil || d.Before(*end) {
+			end = &d
+		}
+	}
+	return end
+}
+
+// firstOnOrAfter returns the first occurrence on or after d that is not past
+// end, or nil.
+func (s *schedule) firstOnOrAfter(d time.Time, end *time.Time) *time.Time {
+	next := s.next(d.AddDate(0, 0, -1))
+	if next.IsZero() || (end != nil && next.After(*end)) {
+		return nil
+	}
+	return &next
+}
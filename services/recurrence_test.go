@@ -0,0 +1,330 @@
+package services
+
+import (
+	"backend101/database"
+	"backend101/models"
+	"errors"
+	"testing"
+	"time"
+)
+
+func date(s string) time.Time {
+	d, err := time.Parse(dateOnlyLayout, s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func intPtr(n int) *int {
+	return &n
+}
+
+// firstOccurrences returns the first n occurrences of the rule.
+func firstOccurrences(t *testing.T, rule *models.RecurringRule, n int) []string {
+	t.Helper()
+
+	s, err := parseSchedule(rule)
+	if err != nil {
+		t.Fatalf("parseSchedule() error = %v", err)
+	}
+	var out []string
+	d := rule.StartDate.AddDate(0, 0, -1)
+	for i := 0; i < n; i++ {
+		if d = s.next(d); d.IsZero() {
+			break
+		}
+		out = append(out, d.Format(dateOnlyLayout))
+	}
+	return out
+}
+
+func TestScheduleNext(t *testing.T) {
+	tests := []struct {
+		name string
+		rule models.RecurringRule
+		want []string
+	}{
+		{
+			"daily every third day",
+			models.RecurringRule{Frequency: models.FrequencyDaily, Interval: 3, StartDate: date("2026-10-18")},
+			[]string{"2026-10-18", "2026-10-21", "2026-10-24", "2026-10-27"},
+		},
+		{
+			"weekly on the start weekday",
+			models.RecurringRule{Frequency: models.FrequencyWeekly, Interval: 1, StartDate: date("2026-10-18")},
+			[]string{"2026-10-18", "2026-10-25", "2026-11-01"},
+		},
+		{
+			// Weeks count from the start's Monday, so the Monday before a
+			// Wednesday start is skipped but its Thursday is not.
+			"every other week on Monday and Thursday",
+			models.RecurringRule{Frequency: models.FrequencyWeekly, Interval: 2, ByDay: "MO,TH", StartDate: date("2026-10-14")},
+			[]string{"2026-10-15", "2026-10-26", "2026-10-29", "2026-11-09", "2026-11-12"},
+		},
+		{
+			"weekly on Sunday, the end of the week",
+			models.RecurringRule{Frequency: models.FrequencyWeekly, Interval: 2, ByDay: "SU,MO", StartDate: date("2026-10-12")},
+			[]string{"2026-10-12", "2026-10-18", "2026-10-26", "2026-11-01"},
+		},
+		{
+			"monthly on the 31st clamps to short months",
+			models.RecurringRule{Frequency: models.FrequencyMonthly, Interval: 1, StartDate: date("2026-12-31")},
+			[]string{"2026-12-31", "2027-01-31", "2027-02-28", "2027-03-31", "2027-04-30"},
+		},
+		{
+			"monthly on the 31st in a leap year",
+			models.RecurringRule{Frequency: models.FrequencyMonthly, Interval: 1, StartDate: date("2028-01-31")},
+			[]string{"2028-01-31", "2028-02-29", "2028-03-31"},
+		},
+		{
+			"every other month on the 31st",
+			models.RecurringRule{Frequency: models.FrequencyMonthly, Interval: 2, StartDate: date("2026-12-31")},
+			[]string{"2026-12-31", "2027-02-28", "2027-04-30", "2027-06-30"},
+		},
+		{
+			"monthly on the last day",
+			models.RecurringRule{Frequency: models.FrequencyMonthly, Interval: 1, ByMonthDay: intPtr(-1), StartDate: date("2026-10-18")},
+			[]string{"2026-10-31", "2026-11-30", "2026-12-31", "2027-01-31", "2027-02-28"},
+		},
+		{
+			"monthly on the last Friday",
+			models.RecurringRule{Frequency: models.FrequencyMonthly, Interval: 1, ByDay: "-1FR", StartDate: date("2026-10-01")},
+			[]string{"2026-10-30", "2026-11-27", "2026-12-25", "2027-01-29"},
+		},
+		{
+			"monthly on the second Monday",
+			models.RecurringRule{Frequency: models.FrequencyMonthly, Interval: 1, ByDay: "2MO", StartDate: date("2026-10-01")},
+			[]string{"2026-10-12", "2026-11-09", "2026-12-14"},
+		},
+		{
+			"monthly on the fifth Friday skips months without one",
+			models.RecurringRule{Frequency: models.FrequencyMonthly, Interval: 1, ByDay: "5FR", StartDate: date("2026-10-01")},
+			[]string{"2026-10-30", "2027-01-29", "2027-04-30"},
+		},
+		{
+			"monthly on the first Monday and the last Friday",
+			models.RecurringRule{Frequency: models.FrequencyMonthly, Interval: 1, ByDay: "-1FR,1MO", StartDate: date("2026-10-18")},
+			[]string{"2026-10-30", "2026-11-02", "2026-11-27"},
+		},
+		{
+			"yearly on 29 February",
+			models.RecurringRule{Frequency: models.FrequencyYearly, Interval: 1, StartDate: date("2028-02-29")},
+			[]string{"2028-02-29", "2029-02-28", "2030-02-28", "2031-02-28", "2032-02-29"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := firstOccurrences(t, &tt.rule, len(tt.want))
+			if len(got) != len(tt.want) {
+				t.Fatalf("occurrences = %v, want %v", got, tt.want)
+			}
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Fatalf("occurrences = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestScheduleNextFromAnyDate(t *testing.T) {
+	rule := models.RecurringRule{Frequency: models.FrequencyMonthly, Interval: 1, StartDate: date("2026-10-31")}
+	s, err := parseSchedule(&rule)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]string{
+		"2020-01-01": "2026-10-31", // before the start
+		"2026-10-31": "2026-11-30",
+		"2026-11-29": "2026-11-30",
+		"2026-11-30": "2026-12-31",
+		"2027-02-27": "2027-02-28",
+	}
+	for after, want := range tests {
+		if got := s.next(date(after)).Format(dateOnlyLayout); got != want {
+			t.Errorf("next(%s) = %s, want %s", after, got, want)
+		}
+	}
+}
+
+func TestParseScheduleRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		rule models.RecurringRule
+	}{
+		{"zero interval", models.RecurringRule{Frequency: models.FrequencyDaily}},
+		{"unknown frequency", models.RecurringRule{Frequency: "hourly", Interval: 1}},
+		{"unknown weekday", models.RecurringRule{Frequency: models.FrequencyWeekly, Interval: 1, ByDay: "XX"}},
+		{"short weekday", models.RecurringRule{Frequency: models.FrequencyWeekly, Interval: 1, ByDay: "M"}},
+		{"zero ordinal", models.RecurringRule{Frequency: models.FrequencyMonthly, Interval: 1, ByDay: "0MO"}},
+		{"ordinal too large", models.RecurringRule{Frequency: models.FrequencyMonthly, Interval: 1, ByDay: "6MO"}},
+		{"ordinal too small", models.RecurringRule{Frequency: models.FrequencyMonthly, Interval: 1, ByDay: "-6MO"}},
+		{"monthly weekday without ordinal", models.RecurringRule{Frequency: models.FrequencyMonthly, Interval: 1, ByDay: "MO"}},
+		{"weekly weekday with ordinal", models.RecurringRule{Frequency: models.FrequencyWeekly, Interval: 1, ByDay: "1MO"}},
+		{"weekly by month day", models.RecurringRule{Frequency: models.FrequencyWeekly, Interval: 1, ByMonthDay: intPtr(1)}},
+		{"daily by day", models.RecurringRule{Frequency: models.FrequencyDaily, Interval: 1, ByDay: "MO"}},
+		{"yearly by month day", models.RecurringRule{Frequency: models.FrequencyYearly, Interval: 1, ByMonthDay: intPtr(1)}},
+		{"by day and by month day", models.RecurringRule{Frequency: models.FrequencyMonthly, Interval: 1, ByDay: "1MO", ByMonthDay: intPtr(1)}},
+		{"month day 0", models.RecurringRule{Frequency: models.FrequencyMonthly, Interval: 1, ByMonthDay: intPtr(0)}},
+		{"month day 32", models.RecurringRule{Frequency: models.FrequencyMonthly, Interval: 1, ByMonthDay: intPtr(32)}},
+		{"month day -32", models.RecurringRule{Frequency: models.FrequencyMonthly, Interval: 1, ByMonthDay: intPtr(-32)}},
+	}
+
+	for _, tt := range tests {
+		tt.rule.StartDate = date("2026-10-18")
+		if _, err := parseSchedule(&tt.rule); !errors.Is(err, ErrInvalidRecurrence) {
+			t.Errorf("%s: error = %v, want ErrInvalidRecurrence", tt.name, err)
+		}
+	}
+}
+
+func TestScheduleEndsOn(t *testing.T) {
+	monthly31 := models.RecurringRule{Frequency: models.FrequencyMonthly, Interval: 1, StartDate: date("2026-12-31")}
+	fifthFriday := models.RecurringRule{Frequency: models.FrequencyMonthly, Interval: 1, ByDay: "5FR", StartDate: date("2026-10-01")}
+	until := func(s string) *time.Time {
+		d := date(s)
+		return &d
+	}
+
+	tests := []struct {
+		name  string
+		rule  models.RecurringRule
+		until *time.Time
+		count *int
+		want  string
+	}{
+		{"open-ended", monthly31, nil, nil, ""},
+		{"until", monthly31, until("2027-06-15"), nil, "2027-06-15"},
+		{"count", monthly31, nil, intPtr(3), "2027-02-28"},
+		{"count of one", monthly31, nil, intPtr(1), "2026-12-31"},
+		{"count skipping months", fifthFriday, nil, intPtr(2), "2027-01-29"},
+		{"until before the count ends", monthly31, until("2027-01-15"), intPtr(5), "2027-01-15"},
+		{"count before until", monthly31, until("2030-01-01"), intPtr(2), "2027-01-31"},
+	}
+
+	for _, tt := range tests {
+		s, err := parseSchedule(&tt.rule)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := ""
+		if end := s.endsOn(tt.until, tt.count); end != nil {
+			got = end.Format(dateOnlyLayout)
+		}
+		if got != tt.want {
+			t.Errorf("%s: endsOn() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// disableAlerts stops alert checks queued by the test from running after its
+// database transaction is gone.
+func disableAlerts(t *testing.T) {
+	saved := alerts
+	alerts = newAlertQueue(0, time.Hour, func(uint, []models.Transaction) {})
+	t.Cleanup(func() { alerts = saved })
+}
+
+func TestMaterializeDueOccurrencesIsIdempotent(t *testing.T) {
+	useTestDB(t)
+	disableAlerts(t)
+	setClock(t, time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC))
+	user := createTestUser(t, "recurring@example.com")
+	category := createTestCategory(t, user.ID, "Coffee")
+
+	rule := models.RecurringRule{
+		UserID:      user.ID,
+		AmountMinor: 500,
+		Currency:    "USD",
+		Type:        "expense",
+		CategoryID:  &category.ID,
+		Category:    category.Name,
+		Description: "Coffee",
+		Frequency:   models.FrequencyDaily,
+		Interval:    1,
+		StartDate:   date("2026-10-10"),
+	}
+	if err := reschedule(&rule, rule.StartDate); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.DB.Create(&rule).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// One occurrence skipped and one changed before they came due.
+	amount := int64(700)
+	for _, o := range []models.RecurringOccurrence{
+		{RuleID: rule.ID, UserID: user.ID, Date: date("2026-10-12"), Skipped: true},
+		{RuleID: rule.ID, UserID: user.ID, Date: date("2026-10-13"), AmountMinor: &amount},
+	} {
+		if err := database.DB.Create(&o).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 10 to 18 October, without the 12th.
+	const want = 8
+	if n, err := MaterializeDueOccurrences(); err != nil || n != want {
+		t.Fatalf("first run created %d, %v; want %d", n, err, want)
+	}
+	if n, err := MaterializeDueOccurrences(); err != nil || n != 0 {
+		t.Fatalf("second run created %d, %v; want 0", n, err)
+	}
+
+	// A run that lost its progress, e.g. one that crashed before saving the
+	// next date, must not create anything twice either.
+	if err := database.DB.Model(&rule).Update("next_date", rule.StartDate).Error; err != nil {
+		t.Fatal(err)
+	}
+	if n, err := materializeRule(rule.ID); err != nil || n != 0 {
+		t.Fatalf("rerun from the start created %d, %v; want 0", n, err)
+	}
+
+	var occurrences []models.RecurringOccurrence
+	if err := database.DB.Where("rule_id = ?", rule.ID).Order("date").Find(&occurrences).Error; err != nil {
+		t.Fatal(err)
+	}
+	var transactions []models.Transaction
+	if err := database.DB.Where("recurring_rule_id = ?", rule.ID).Find(&transactions).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(occurrences) != 9 || len(transactions) != want {
+		t.Fatalf("%d occurrences and %d transactions, want 9 and %d", len(occurrences), len(transactions), want)
+	}
+
+	byID := map[uint]models.Transaction{}
+	for _, tx := range transactions {
+		byID[tx.ID] = tx
+	}
+	for _, o := range occurrences {
+		day := o.Date.Format(dateOnlyLayout)
+		switch {
+		case o.Skipped:
+			if day != "2026-10-12" || o.TransactionID != nil {
+				t.Errorf("skipped occurrence %s has transaction %v", day, o.TransactionID)
+			}
+		case o.TransactionID == nil:
+			t.Errorf("occurrence %s has no transaction", day)
+		default:
+			tx, ok := byID[*o.TransactionID]
+			if !ok {
+				t.Errorf("occurrence %s points at transaction %d of another rule", day, *o.TransactionID)
+				continue
+			}
+			wantAmount := int64(500)
+			if day == "2026-10-13" {
+				wantAmount = 700
+			}
+			if tx.AmountMinor != wantAmount {
+				t.Errorf("occurrence %s: amount %d, want %d", day, tx.AmountMinor, wantAmount)
+			}
+			delete(byID, tx.ID)
+		}
+	}
+	if len(byID) > 0 {
+		t.Errorf("transactions without an occurrence: %+v", byID)
+	}
+}
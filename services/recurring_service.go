@@ -0,0 +1,574 @@
+package services
+
+import (
+	"backend101/database"
+	"backend101/dto"
+	"backend101/models"
+	"backend101/money"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// A rule creates at most this many transactions per run, so a rule that
+// starts far in the past is backfilled over several runs.
+const maxOccurrencesPerRun = 500
+
+var (
+	ErrNotAnOccurrence   = errors.New("the rule has no occurrence on this date")
+	ErrOccurrenceCreated = errors.New("the occurrence's transaction was already created")
+)
+
+func parseDate(s string) (time.Time, error) {
+	t, err := time.Parse(dateOnlyLayout, strings.TrimSpace(s))
+	if err != nil {
+		return time.Time{}, ErrInvalidDate
+	}
+	return t, nil
+}
+
+// today is the current date in loc, as midnight UTC like DATE columns.
+func today(loc *time.Location) time.Time {
+	return dateOf(Now().In(loc))
+}
+
+// occurrenceTransaction is the transaction for the rule's occurrence on
+// date, with the overrides in o applied.
+func occurrenceTransaction(rule *models.RecurringRule, date time.Time, loc *time.Location, o *models.RecurringOccurrence) models.Transaction {
+	tx := models.Transaction{
+		UserID:          rule.UserID,
+		AccountID:       rule.AccountID,
+		RecurringRuleID: &rule.ID,
+		AmountMinor:     rule.AmountMinor,
+		Currency:        rule.Currency,
+		CategoryID:      rule.CategoryID,
+		Category:        rule.Category,
+		Description:     rule.Description,
+		Type:            rule.Type,
+		Date:            time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, loc),
+	}
+	if o != nil && o.AmountMinor != nil {
+		tx.AmountMinor = *o.AmountMinor
+	}
+	if o != nil && o.Description != nil {
+		tx.Description = *o.Description
+	}
+	return tx
+}
+
+// reschedule recomputes when the rule ends and its next occurrence on or
+// after from.
+func reschedule(rule *models.RecurringRule, from time.Time) error {
+	s, err := parseSchedule(rule)
+	if err != nil {
+		return err
+	}
+	if rule.Until != nil && rule.Until.Before(rule.StartDate) {
+		return invalidRecurrence("until must not be before start_date")
+	}
+	rule.EndsOn = s.endsOn(rule.Until, rule.Count)
+	if from.Before(rule.StartDate) {
+		from = rule.StartDate
+	}
+	rule.NextDate = s.firstOnOrAfter(from, rule.EndsOn)
+	return nil
+}
+
+// CreateRecurringRule stores a rule and creates the transactions of its
+// occurrences up to today, so a start date in the past is backfilled.
+func CreateRecurringRule(userID uint, input dto.CreateRecurringInput) (*models.RecurringRule, error) {
+	rule := models.RecurringRule{
+		UserID:      userID,
+		AccountID:   input.AccountID,
+		Type:        input.Type,
+		Description: strings.TrimSpace(input.Description),
+		Frequency:   input.Frequency,
+		Interval:    input.Interval,
+		ByDay:       strings.ToUpper(strings.Join(input.ByDay, ",")),
+		ByMonthDay:  input.ByMonthDay,
+		Count:       input.Count,
+	}
+	if rule.Interval == 0 {
+		rule.Interval = 1
+	}
+
+	var account *models.Account
+	if input.AccountID != nil {
+		var err error
+		if account, err = activeAccount(userID, *input.AccountID); err != nil {
+			return nil, err
+		}
+	}
+	prefs, err := GetPreferences(userID)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case input.Currency != nil:
+		rule.Currency = strings.ToUpper(*input.Currency)
+	case account != nil:
+		rule.Currency = account.Currency
+	default:
+		rule.Currency = prefs.Currency
+	}
+	if rule.AmountMinor, err = money.Parse(input.Amount.String(), rule.Currency); err != nil {
+		return nil, err
+	}
+
+	if rule.StartDate, err = parseDate(input.StartDate); err != nil {
+		return nil, err
+	}
+	if input.Until != nil {
+		until, err := parseDate(*input.Until)
+		if err != nil {
+			return nil, err
+		}
+		rule.Until = &until
+	}
+	if err := reschedule(&rule, rule.StartDate); err != nil {
+		return nil, err
+	}
+
+	err = database.DB.Transaction(func(db *gorm.DB) error {
+		category, err := resolveTransactionCategory(db, userID, input.CategoryID, input.Category, rule.Type)
+		if err != nil {
+			return err
+		}
+		rule.CategoryID, rule.Category = &category.ID, category.Name
+
+		// Every occurrence must make a valid transaction.
+		sample := occurrenceTransaction(&rule, rule.StartDate, UserLocation(prefs), nil)
+		if err := validateTransaction(&sample); err != nil {
+			return err
+		}
+		return db.Create(&rule).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := materializeRule(rule.ID); err != nil {
+		log.Printf("❌ Failed to create occurrences of recurring rule %d: %v", rule.ID, err)
+	}
+	return GetRecurringRule(userID, rule.ID)
+}
+
+func ListRecurringRules(userID uint) ([]models.RecurringRule, error) {
+	rules := []models.RecurringRule{}
+	err := database.DB.Where("user_id = ?", userID).Order("next_date NULLS LAST, id").Find(&rules).Error
+	return rules, err
+}
+
+func GetRecurringRule(userID uint, id interface{}) (*models.RecurringRule, error) {
+	var rule models.RecurringRule
+	if err := database.DB.Where("id = ? AND user_id = ?", id, userID).First(&rule).Error; err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// UpdateRecurringRule applies the fields that are set in input to future
+// occurrences. Transactions already created are left alone. A new schedule
+// takes effect from today and drops changes to single occurrences that
+// have not been created yet.
+func UpdateRecurringRule(userID uint, id string, input dto.UpdateRecurringInput) (*models.RecurringRule, error) {
+	rule, err := GetRecurringRule(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	prefs, err := GetPreferences(userID)
+	if err != nil {
+		return nil, err
+	}
+	loc := UserLocation(prefs)
+
+	if input.Amount != nil {
+		if rule.AmountMinor, err = money.Parse(input.Amount.String(), rule.Currency); err != nil {
+			return nil, err
+		}
+	}
+	if input.Description != nil {
+		rule.Description = strings.TrimSpace(*input.Description)
+	}
+	if input.AccountID != nil {
+		if *input.AccountID == 0 {
+			rule.AccountID = nil
+		} else {
+			if _, err := activeAccount(userID, *input.AccountID); err != nil {
+				return nil, err
+			}
+			rule.AccountID = input.AccountID
+		}
+	}
+
+	rescheduled := input.Frequency != nil || input.Interval != nil || input.ByDay != nil ||
+		input.ByMonthDay != nil || input.StartDate != nil || input.Until != nil || input.Count != nil
+	if input.Frequency != nil {
+		rule.Frequency = *input.Frequency
+		// Day rules of one frequency rarely make sense for another.
+		if input.ByDay == nil {
+			rule.ByDay = ""
+		}
+		if input.ByMonthDay == nil {
+			rule.ByMonthDay = nil
+		}
+	}
+	if input.Interval != nil {
+		rule.Interval = *input.Interval
+	}
+	if input.ByDay != nil {
+		rule.ByDay = strings.ToUpper(strings.Join(input.ByDay, ","))
+	}
+	if input.ByMonthDay != nil {
+		rule.ByMonthDay = input.ByMonthDay
+	}
+	if input.StartDate != nil {
+		if rule.StartDate, err = parseDate(*input.StartDate); err != nil {
+			return nil, err
+		}
+	}
+	if input.Until != nil {
+		rule.Until = nil
+		if *input.Until != "" {
+			until, err := parseDate(*input.Until)
+			if err != nil {
+				return nil, err
+			}
+			rule.Until, rule.Count = &until, nil
+		}
+	}
+	if input.Count != nil {
+		rule.Count = nil
+		if *input.Count > 0 {
+			rule.Count, rule.Until = input.Count, nil
+		}
+	}
+	if rescheduled {
+		if err := reschedule(rule, today(loc)); err != nil {
+			return nil, err
+		}
+	}
+
+	err = database.DB.Transaction(func(db *gorm.DB) error {
+		if input.CategoryID != nil || input.Category != nil {
+			name := rule.Category
+			if input.Category != nil {
+				name = *input.Category
+			}
+			category, err := resolveTransactionCategory(db, userID, input.CategoryID, name, rule.Type)
+			if err != nil {
+				return err
+			}
+			rule.CategoryID, rule.Category = &category.ID, category.Name
+		}
+
+		sample := occurrenceTransaction(rule, rule.StartDate, loc, nil)
+		if err := validateTransaction(&sample); err != nil {
+			return err
+		}
+		if rescheduled {
+			if err := db.Where("rule_id = ? AND transaction_id IS NULL", rule.ID).
+				Delete(&models.RecurringOccurrence{}).Error; err != nil {
+				return err
+			}
+		}
+		return db.Save(rule).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+// DeleteRecurringRule stops a rule. Transactions it created are kept.
+func DeleteRecurringRule(userID uint, id string) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		rule, err := getRecurringRuleForUpdate(tx, userID, id)
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Transaction{}).Where("recurring_rule_id = ?", rule.ID).
+			Update("recurring_rule_id", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("rule_id = ?", rule.ID).Delete(&models.RecurringOccurrence{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(rule).Error
+	})
+}
+
+// getRecurringRuleForUpdate locks the rule, so it cannot change while the
+// scheduler works on it.
+func getRecurringRuleForUpdate(tx *gorm.DB, userID uint, id interface{}) (*models.RecurringRule, error) {
+	var rule models.RecurringRule
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ? AND user_id = ?", id, userID).
+		First(&rule).Error
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// Occurrence is an upcoming occurrence of a rule, with the changes made to
+// it if any.
+type Occurrence struct {
+	Date     time.Time
+	Override *models.RecurringOccurrence
+}
+
+// UpcomingOccurrences returns the next limit occurrences of a rule that
+// have not been created yet.
+func UpcomingOccurrences(userID uint, id string, limit int) (*models.RecurringRule, []Occurrence, error) {
+	rule, err := GetRecurringRule(userID, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	s, err := parseSchedule(rule)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	occurrences := []Occurrence{}
+	for d := rule.NextDate; d != nil && len(occurrences) < limit; {
+		occurrences = append(occurrences, Occurrence{Date: *d})
+		next := s.next(*d)
+		if next.IsZero() || (rule.EndsOn != nil && next.After(*rule.EndsOn)) {
+			break
+		}
+		d = &next
+	}
+	if len(occurrences) == 0 {
+		return rule, occurrences, nil
+	}
+
+	var overrides []models.RecurringOccurrence
+	if err := database.DB.Where("rule_id = ? AND date BETWEEN ? AND ?",
+		rule.ID, occurrences[0].Date, occurrences[len(occurrences)-1].Date).
+		Find(&overrides).Error; err != nil {
+		return nil, nil, err
+	}
+	byDate := map[time.Time]*models.RecurringOccurrence{}
+	for i := range overrides {
+		byDate[dateOf(overrides[i].Date)] = &overrides[i]
+	}
+	for i := range occurrences {
+		occurrences[i].Override = byDate[occurrences[i].Date]
+	}
+	return rule, occurrences, nil
+}
+
+// isOccurrence reports whether the rule occurs on date.
+func isOccurrence(rule *models.RecurringRule, date time.Time) (bool, error) {
+	s, err := parseSchedule(rule)
+	if err != nil {
+		return false, err
+	}
+	if rule.EndsOn != nil && date.After(*rule.EndsOn) {
+		return false, nil
+	}
+	return s.next(date.AddDate(0, 0, -1)).Equal(date), nil
+}
+
+// UpdateOccurrence skips or changes a single upcoming occurrence. Skip false
+// without other changes restores the occurrence as scheduled.
+func UpdateOccurrence(userID uint, id, rawDate string, input dto.UpdateOccurrenceInput) (*models.RecurringRule, *Occurrence, error) {
+	date, err := parseDate(rawDate)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var rule *models.RecurringRule
+	var occurrence models.RecurringOccurrence
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if rule, err = getRecurringRuleForUpdate(tx, userID, id); err != nil {
+			return err
+		}
+		ok, err := isOccurrence(rule, date)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrNotAnOccurrence
+		}
+
+		err = tx.Where("rule_id = ? AND date = ?", rule.ID, date).First(&occurrence).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			occurrence = models.RecurringOccurrence{RuleID: rule.ID, UserID: userID, Date: date}
+		case err != nil:
+			return err
+		}
+		// Past occurrences without a row were created before the rule's
+		// schedule changed; either way, they are no longer upcoming.
+		if occurrence.TransactionID != nil || rule.NextDate == nil || date.Before(*rule.NextDate) {
+			return ErrOccurrenceCreated
+		}
+
+		if input.Skip != nil {
+			occurrence.Skipped = *input.Skip
+		}
+		if input.Amount != nil {
+			amount, err := money.Parse(input.Amount.String(), rule.Currency)
+			if err != nil {
+				return err
+			}
+			occurrence.AmountMinor = &amount
+		}
+		if input.Description != nil {
+			description := strings.TrimSpace(*input.Description)
+			occurrence.Description = &description
+		}
+
+		sample := occurrenceTransaction(rule, date, time.UTC, &occurrence)
+		if err := validateTransaction(&sample); err != nil {
+			return err
+		}
+		return tx.Save(&occurrence).Error
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return rule, &Occurrence{Date: date, Override: &occurrence}, nil
+}
+
+// ResetOccurrence undoes changes to a single upcoming occurrence. Resetting
+// an occurrence without changes does nothing.
+func ResetOccurrence(userID uint, id, rawDate string) error {
+	date, err := parseDate(rawDate)
+	if err != nil {
+		return err
+	}
+	rule, err := GetRecurringRule(userID, id)
+	if err != nil {
+		return err
+	}
+
+	res := database.DB.Where("rule_id = ? AND date = ? AND transaction_id IS NULL", rule.ID, date).
+		Delete(&models.RecurringOccurrence{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected > 0 {
+		return nil
+	}
+
+	var created int64
+	if err := database.DB.Model(&models.RecurringOccurrence{}).
+		Where("rule_id = ? AND date = ?", rule.ID, date).
+		Count(&created).Error; err != nil {
+		return err
+	}
+	if created > 0 {
+		return ErrOccurrenceCreated
+	}
+	return nil
+}
+
+// MaterializeDueOccurrences creates the transactions of every occurrence
+// that has come due, including ones missed while no instance was running,
+// and returns how many were created. A failure on one rule does not stop
+// the others.
+func MaterializeDueOccurrences() (int, error) {
+	// A day of slack covers users in time zones ahead of UTC; each rule is
+	// checked against its owner's date below.
+	var ids []uint
+	if err := database.DB.Model(&models.RecurringRule{}).
+		Where("next_date IS NOT NULL AND next_date <= ?", dateOf(Now().UTC()).AddDate(0, 0, 1)).
+		Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+
+	created := 0
+	for _, id := range ids {
+		n, err := materializeRule(id)
+		if err != nil {
+			log.Printf("❌ Failed to create occurrences of recurring rule %d: %v", id, err)
+			continue
+		}
+		created += n
+	}
+	return created, nil
+}
+
+// materializeRule creates the transactions of the rule's due occurrences
+// and advances its next date. The rule row is locked with SKIP LOCKED, so
+// instances running at the same time do not wait on or repeat each other's
+// work, and the unique rule and date of each occurrence guards against
+// creating one twice.
+func materializeRule(id uint) (int, error) {
+	created := 0
+	var rule models.RecurringRule
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("id = ? AND next_date IS NOT NULL", id).
+			First(&rule).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		s, err := parseSchedule(&rule)
+		if err != nil {
+			return err
+		}
+		prefs, err := GetPreferences(rule.UserID)
+		if err != nil {
+			return err
+		}
+		loc := UserLocation(prefs)
+		due := today(loc)
+
+		for i := 0; i < maxOccurrencesPerRun && rule.NextDate != nil && !rule.NextDate.After(due); i++ {
+			ok, err := materializeOccurrence(tx, &rule, *rule.NextDate, loc)
+			if err != nil {
+				return err
+			}
+			if ok {
+				created++
+			}
+
+			next := s.next(*rule.NextDate)
+			if next.IsZero() || (rule.EndsOn != nil && next.After(*rule.EndsOn)) {
+				rule.NextDate = nil
+			} else {
+				rule.NextDate = &next
+			}
+		}
+
+		return tx.Model(&rule).Update("next_date", rule.NextDate).Error
+	})
+	if err == nil && created > 0 {
+		checkAlertsAsync(rule.UserID, nil)
+	}
+	return created, err
+}
+
+// materializeOccurrence creates the transaction of one occurrence unless it
+// was skipped or already created.
+func materializeOccurrence(tx *gorm.DB, rule *models.RecurringRule, date time.Time, loc *time.Location) (bool, error) {
+	occurrence := models.RecurringOccurrence{RuleID: rule.ID, UserID: rule.UserID, Date: date}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&occurrence).Error; err != nil {
+		return false, err
+	}
+	// The row may have existed already, with changes or a transaction.
+	if err := tx.Where("rule_id = ? AND date = ?", rule.ID, date).First(&occurrence).Error; err != nil {
+		return false, err
+	}
+	if occurrence.Skipped || occurrence.TransactionID != nil {
+		return false, nil
+	}
+
+	transaction := occurrenceTransaction(rule, date, loc, &occurrence)
+	if err := tx.Create(&transaction).Error; err != nil {
+		return false, err
+	}
+	return true, tx.Model(&occurrence).Update("transaction_id", transaction.ID).Error
+}
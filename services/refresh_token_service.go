@@ -0,0 +1,127 @@
+package services
+
+import (
+	"backend101/config"
+	"backend101/database"
+	"backend101/models"
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected")
+)
+
+func refreshTokenTTL() time.Duration {
+	return time.Hour * time.Duration(config.GetInt("REFRESH_TOKEN_EXPIRE_HOURS"))
+}
+
+// IssueRefreshToken stores a new refresh token for the user and returns the
+// raw value. The family ID is the ID of the session the token belongs to.
+func IssueRefreshToken(userID uint, familyID string) (string, error) {
+	raw, err := generateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	token := models.RefreshToken{
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: hashToken(raw),
+		ExpiresAt: Now().Add(refreshTokenTTL()),
+	}
+	if err := database.DB.Create(&token).Error; err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+// RotateRefreshToken exchanges a valid refresh token for a new one in the same
+// family and returns the user, the family (session) ID and the new token.
+// Presenting a token that was already rotated is treated as theft and revokes
+// the whole session.
+func RotateRefreshToken(raw string) (uint, string, string, error) {
+	var (
+		userID       uint
+		familyID     string
+		newRaw       string
+		reusedFamily string
+	)
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var current models.RefreshToken
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ?", hashToken(raw)).
+			First(&current).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidRefreshToken
+		}
+		if err != nil {
+			return err
+		}
+
+		if current.RevokedAt != nil {
+			reusedFamily = current.FamilyID
+			return ErrRefreshTokenReused
+		}
+		if !Now().Before(current.ExpiresAt) {
+			return ErrInvalidRefreshToken
+		}
+
+		next := models.RefreshToken{
+			UserID:    current.UserID,
+			FamilyID:  current.FamilyID,
+			ExpiresAt: Now().Add(refreshTokenTTL()),
+		}
+		newRaw, err = generateOpaqueToken()
+		if err != nil {
+			return err
+		}
+		next.TokenHash = hashToken(newRaw)
+		if err := tx.Create(&next).Error; err != nil {
+			return err
+		}
+
+		now := Now()
+		if err := tx.Model(&current).Updates(map[string]interface{}{
+			"revoked_at":     now,
+			"replaced_by_id": next.ID,
+		}).Error; err != nil {
+			return err
+		}
+
+		userID = current.UserID
+		familyID = current.FamilyID
+		return nil
+	})
+
+	if errors.Is(err, ErrRefreshTokenReused) {
+		if revokeErr := revokeSessions(context.Background(), []string{reusedFamily}); revokeErr != nil {
+			return 0, "", "", revokeErr
+		}
+	}
+	if err != nil {
+		return 0, "", "", err
+	}
+	return userID, familyID, newRaw, nil
+}
+
+// RevokeUserRefreshTokens revokes every refresh token and session of the user.
+func RevokeUserRefreshTokens(userID uint) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		now := Now()
+		if err := tx.Model(&models.Session{}).
+			Where("user_id = ? AND revoked_at IS NULL", userID).
+			Update("revoked_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.RefreshToken{}).
+			Where("user_id = ? AND revoked_at IS NULL", userID).
+			Update("revoked_at", now).Error
+	})
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"backend101/database"
+	"backend101/models"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func revokedTokenKey(jti string) string {
+	return "auth:revoked:jti:" + jti
+}
+
+func revokedUserKey(userID uint) string {
+	return fmt.Sprintf("auth:revoked:user:%d", userID)
+}
+
+// RevokeAccessToken adds the token's jti to the denylist until the token
+// would have expired on its own.
+func RevokeAccessToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	if jti == "" {
+		return nil
+	}
+	ttl := expiresAt.Sub(Now())
+	if ttl <= 0 {
+		return nil
+	}
+	return database.Cache.Set(ctx, revokedTokenKey(jti), "1", ttl)
+}
+
+// RevokeAllUserTokens invalidates every access token issued to the user up to
+// now and revokes all of their refresh tokens ("log out everywhere"). The
+// cutoff is kept in milliseconds, like the tokens' issue times, so tokens
+// issued right afterwards, e.g. by the login that triggered it, stay valid.
+func RevokeAllUserTokens(ctx context.Context, userID uint) error {
+	if err := revokeUserAccessTokens(ctx, userID); err != nil {
+		return err
+	}
+	return RevokeUserRefreshTokens(userID)
+}
+
+func revokeUserAccessTokens(ctx context.Context, userID uint) error {
+	cutoff := strconv.FormatInt(Now().UnixMilli(), 10)
+	return database.Cache.Set(ctx, revokedUserKey(userID), cutoff, accessTokenTTL())
+}
+
+// RevokeRefreshToken revokes the session of the given refresh token if it
+// belongs to the user. Unknown tokens are ignored.
+func RevokeRefreshToken(ctx context.Context, userID uint, raw string) error {
+	var token models.RefreshToken
+	err := database.DB.Where("token_hash = ? AND user_id = ?", hashToken(raw), userID).First(&token).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return revokeSessions(ctx, []string{token.FamilyID})
+}
+
+// IsTokenRevoked reports whether the token was logged out individually, its
+// session was revoked, or it was issued before the user's last "log out
+// everywhere".
+func IsTokenRevoked(ctx context.Context, claims *Claims) (bool, error) {
+	if claims.ID != "" {
+		_, revoked, err := database.Cache.Get(ctx, revokedTokenKey(claims.ID))
+		if err != nil || revoked {
+			return revoked, err
+		}
+	}
+
+	if claims.SessionID != "" {
+		_, revoked, err := database.Cache.Get(ctx, revokedSessionKey(claims.SessionID))
+		if err != nil || revoked {
+			return revoked, err
+		}
+	}
+
+	cutoff, ok, err := database.GetInt64(ctx, database.Cache, revokedUserKey(claims.UserID))
+	if err != nil || !ok {
+		return false, err
+	}
+	if claims.IssuedAt == nil {
+		return true, nil
+	}
+	// Cutoffs stored by older versions are in seconds.
+	if cutoff < 1e12 {
+		return claims.IssuedAt.Unix() <= cutoff, nil
+	}
+	return claims.IssuedAt.UnixMilli() < cutoff, nil
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"backend101/database"
+	"backend101/models"
+	"context"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRevokedUserCutoff(t *testing.T) {
+	useMemoryCache(t)
+	revokedAt := time.Date(2026, 10, 18, 12, 0, 0, 500*int(time.Millisecond), time.UTC)
+	advance := setClock(t, revokedAt)
+	ctx := context.Background()
+
+	if err := revokeUserAccessTokens(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		issued  time.Time
+		revoked bool
+	}{
+		{"earlier second", revokedAt.Add(-time.Second), true},
+		{"same second, before", revokedAt.Add(-100 * time.Millisecond), true},
+		{"same second, after", revokedAt.Add(100 * time.Millisecond), false},
+		{"next second", revokedAt.Add(time.Second), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			advance(tt.issued)
+			token, err := GenerateJWT(1, models.RoleUser, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			claims, err := ParseJWT(token)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, err := IsTokenRevoked(ctx, claims); err != nil || got != tt.revoked {
+				t.Errorf("IsTokenRevoked() = %v, %v; want %v", got, err, tt.revoked)
+			}
+		})
+	}
+}
+
+func TestRevokedUserCutoffInSeconds(t *testing.T) {
+	useMemoryCache(t)
+	ctx := context.Background()
+	revokedAt := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	advance := setClock(t, revokedAt)
+
+	// Cutoffs written before they were kept in milliseconds.
+	if err := database.Cache.Set(ctx, revokedUserKey(1), strconv.FormatInt(revokedAt.Unix(), 10), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	for issued, want := range map[time.Time]bool{
+		revokedAt.Add(900 * time.Millisecond): true,
+		revokedAt.Add(time.Second):            false,
+	} {
+		advance(issued)
+		token, _ := GenerateJWT(1, models.RoleUser, "")
+		claims, err := ParseJWT(token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := IsTokenRevoked(ctx, claims); got != want {
+			t.Errorf("issued %s: IsTokenRevoked() = %v, want %v", issued.Format(time.RFC3339Nano), got, want)
+		}
+	}
+}
+
+// useMemoryCache gives the test an empty in-memory cache.
+func useMemoryCache(t *testing.T) {
+	saved := database.Cache
+	database.Cache = database.NewMemoryStore()
+	t.Cleanup(func() { database.Cache = saved })
+}
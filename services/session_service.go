@@ -0,0 +1,147 @@
+package services
+
+import (
+	"backend101/database"
+	"backend101/models"
+	"context"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+)
+
+// Only write LastSeenAt this often per session.
+const sessionTouchInterval = time.Minute
+
+// ClientInfo describes the device a login came from.
+type ClientInfo struct {
+	UserAgent string
+	IP        string
+}
+
+func revokedSessionKey(sessionID string) string {
+	return "auth:revoked:session:" + sessionID
+}
+
+func createSession(userID uint, client ClientInfo) (*models.Session, error) {
+	id, err := generateID()
+	if err != nil {
+		return nil, err
+	}
+
+	now := Now()
+	session := models.Session{
+		ID:         id,
+		UserID:     userID,
+		UserAgent:  truncate(client.UserAgent, 512),
+		IP:         client.IP,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(refreshTokenTTL()),
+	}
+	if err := database.DB.Create(&session).Error; err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// extendSession records a refresh: the session stays alive as long as its
+// refresh tokens do.
+func extendSession(sessionID string, client ClientInfo) error {
+	now := Now()
+	return database.DB.Model(&models.Session{}).Where("id = ?", sessionID).Updates(map[string]interface{}{
+		"last_seen_at": now,
+		"expires_at":   now.Add(refreshTokenTTL()),
+		"ip":           client.IP,
+	}).Error
+}
+
+// TouchSession updates LastSeenAt, at most once per sessionTouchInterval.
+func TouchSession(ctx context.Context, sessionID string) error {
+	key := "auth:session:seen:" + sessionID
+	if _, seen, err := database.Cache.Get(ctx, key); err != nil || seen {
+		return err
+	}
+	if err := database.Cache.Set(ctx, key, "1", sessionTouchInterval); err != nil {
+		return err
+	}
+	return database.DB.Model(&models.Session{}).Where("id = ?", sessionID).Update("last_seen_at", Now()).Error
+}
+
+func ListSessions(userID uint, currentSessionID string) ([]models.Session, error) {
+	var sessions []models.Session
+	err := database.DB.
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, Now()).
+		Order("last_seen_at DESC").
+		Find(&sessions).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentSessionID
+	}
+	return sessions, nil
+}
+
+// RevokeUserSession revokes one of the user's sessions.
+func RevokeUserSession(ctx context.Context, userID uint, sessionID string) error {
+	var session models.Session
+	if err := database.DB.Where("id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, userID).
+		First(&session).Error; err != nil {
+		return err
+	}
+	return revokeSessions(ctx, []string{session.ID})
+}
+
+// RevokeOtherSessions signs the user out everywhere except keepSessionID.
+func RevokeOtherSessions(ctx context.Context, userID uint, keepSessionID string) error {
+	var ids []string
+	if err := database.DB.Model(&models.Session{}).
+		Where("user_id = ? AND id <> ? AND revoked_at IS NULL", userID, keepSessionID).
+		Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+	return revokeSessions(ctx, ids)
+}
+
+// revokeSessions marks sessions revoked, revokes their refresh tokens and
+// denylists their access tokens until they would have expired anyway.
+func revokeSessions(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		now := Now()
+		if err := tx.Model(&models.Session{}).
+			Where("id IN ? AND revoked_at IS NULL", ids).
+			Update("revoked_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.RefreshToken{}).
+			Where("family_id IN ? AND revoked_at IS NULL", ids).
+			Update("revoked_at", now).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if err := database.Cache.Set(ctx, revokedSessionKey(id), "1", accessTokenTTL()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// truncate shortens s to at most n bytes without splitting a UTF-8
+// sequence, which Postgres would reject.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
@@ -0,0 +1,30 @@
+package services
+
+import (
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		s    string
+		n    int
+		want string
+	}{
+		{"Mozilla/5.0", 64, "Mozilla/5.0"},
+		{"Mozilla/5.0", 7, "Mozilla"},
+		{"abcé", 4, "abc"}, // é is two bytes
+		{"abcé", 5, "abcé"},
+		{"ab日本", 4, "ab"}, // 日 is three bytes
+		{"ab日本", 6, "ab日"},
+		{"😀😀", 7, "😀"},
+		{"😀", 3, ""},
+	}
+
+	for _, tt := range tests {
+		got := truncate(tt.s, tt.n)
+		if got != tt.want || !utf8.ValidString(got) {
+			t.Errorf("truncate(%q, %d) = %q, want %q", tt.s, tt.n, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package services
+
+import (
+	"backend101/database"
+	"backend101/models"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	oauthStateKeyPrefix = "auth:oauth:state:"
+	oauthStateTTL       = 10 * time.Minute
+)
+
+var (
+	ErrInvalidOAuthState       = errors.New("invalid or expired oauth state")
+	ErrExternalEmailUnverified = errors.New("external account has no verified email")
+)
+
+type oauthState struct {
+	Provider     string `json:"provider"`
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"code_verifier"`
+}
+
+// BeginOAuthLogin stores the state, nonce and PKCE verifier for one login
+// attempt and returns the URL to send the browser to.
+func BeginOAuthLogin(ctx context.Context, providerName string) (string, error) {
+	provider, err := GetOAuthProvider(providerName)
+	if err != nil {
+		return "", err
+	}
+
+	state, err := generateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	nonce, err := generateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	verifier, err := generateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	data, err := json.Marshal(oauthState{Provider: provider.Name, Nonce: nonce, CodeVerifier: verifier})
+	if err != nil {
+		return "", err
+	}
+	if err := database.Cache.Set(ctx, oauthStateKeyPrefix+state, string(data), oauthStateTTL); err != nil {
+		return "", err
+	}
+
+	challenge := sha256.Sum256([]byte(verifier))
+	return provider.AuthCodeURL(ctx, state, nonce, base64.RawURLEncoding.EncodeToString(challenge[:]))
+}
+
+// CompleteOAuthLogin validates the callback, exchanges the code and returns
+// the local user, linking or creating it as needed.
+func CompleteOAuthLogin(ctx context.Context, providerName, state, code string) (*models.User, error) {
+	provider, err := GetOAuthProvider(providerName)
+	if err != nil {
+		return nil, err
+	}
+
+	key := oauthStateKeyPrefix + state
+	data, ok, err := database.Cache.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if !ok || state == "" {
+		return nil, ErrInvalidOAuthState
+	}
+	// States are single-use.
+	if err := database.Cache.Delete(ctx, key); err != nil {
+		return nil, err
+	}
+
+	var saved oauthState
+	if err := json.Unmarshal([]byte(data), &saved); err != nil || saved.Provider != provider.Name {
+		return nil, ErrInvalidOAuthState
+	}
+
+	identity, err := provider.Exchange(ctx, code, saved.CodeVerifier, saved.Nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	return LoginWithExternalIdentity(ctx, identity)
+}
+
+// LoginWithExternalIdentity maps a provider identity to a local user:
+//  1. a known (provider, subject) pair logs in its linked user;
+//  2. otherwise a verified email links the identity to the user with that
+//     email, creating the user if there is none.
+func LoginWithExternalIdentity(ctx context.Context, identity *ExternalIdentity) (*models.User, error) {
+	var user models.User
+
+	var link models.UserIdentity
+	err := database.DB.Where("provider = ? AND subject = ?", identity.Provider, identity.Subject).First(&link).Error
+	if err == nil {
+		if err := database.DB.First(&user, link.UserID).Error; err != nil {
+			return nil, err
+		}
+		return &user, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if identity.Email == "" || !identity.EmailVerified {
+		return nil, ErrExternalEmailUnverified
+	}
+
+	revokeExisting := false
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("LOWER(email) = ?", strings.ToLower(identity.Email)).First(&user).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			name := identity.Name
+			if name == "" {
+				name = strings.Split(identity.Email, "@")[0]
+			}
+			now := Now()
+			// No password: the user can set one through the reset flow.
+			user = models.User{Name: name, Email: identity.Email, EmailVerifiedAt: &now}
+			if err := tx.Create(&user).Error; err != nil {
+				return err
+			}
+			if err := SeedDefaultCategories(tx, user.ID); err != nil {
+				return err
+			}
+
+		case err != nil:
+			return err
+
+		case user.EmailVerifiedAt == nil:
+			// Someone registered this address without proving they own it.
+			// The provider just proved ownership, so drop the unverified
+			// password and sessions to prevent account pre-hijacking.
+			if err := tx.Model(&user).Updates(map[string]interface{}{
+				"password":          "",
+				"email_verified_at": Now(),
+			}).Error; err != nil {
+				return err
+			}
+			revokeExisting = true
+		}
+
+		return tx.Create(&models.UserIdentity{
+			UserID:   user.ID,
+			Provider: identity.Provider,
+			Subject:  identity.Subject,
+			Email:    identity.Email,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if revokeExisting {
+		if err := RevokeAllUserTokens(ctx, user.ID); err != nil {
+			return nil, err
+		}
+	}
+	return &user, nil
+}
+
+func ListIdentities(userID uint) ([]models.UserIdentity, error) {
+	var identities []models.UserIdentity
+	err := database.DB.Where("user_id = ?", userID).Order("created_at").Find(&identities).Error
+	return identities, err
+}
+
+var ErrLastLoginMethod = errors.New("cannot remove the only way to sign in")
+
+// UnlinkIdentity removes an identity unless it is the user's only way to
+// sign in.
+func UnlinkIdentity(userID, identityID uint) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		var identity models.UserIdentity
+		if err := tx.Where("id = ? AND user_id = ?", identityID, userID).First(&identity).Error; err != nil {
+			return err
+		}
+
+		var user models.User
+		if err := tx.Select("id", "password").First(&user, userID).Error; err != nil {
+			return err
+		}
+		var count int64
+		if err := tx.Model(&models.UserIdentity{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+			return err
+		}
+		if user.Password == "" && count <= 1 {
+			return ErrLastLoginMethod
+		}
+
+		return tx.Delete(&identity).Error
+	})
+}
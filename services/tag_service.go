@@ -0,0 +1,188 @@
+package services
+
+import (
+	"backend101/database"
+	"backend101/models"
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrTagExists   = errors.New("a tag with this name already exists")
+	ErrTagNotFound = errors.New("tag not found")
+	ErrTagInUse    = errors.New("tag has budgets")
+)
+
+// normalizeTag is the stored form of a tag name: trimmed and lower case, so
+// "Reimbursable" and "reimbursable " are the same tag.
+func normalizeTag(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+func ListTags(userID uint) ([]models.Tag, error) {
+	tags := []models.Tag{}
+	err := database.DB.Where("user_id = ?", userID).Order("name").Find(&tags).Error
+	return tags, err
+}
+
+func GetTag(userID uint, id interface{}) (*models.Tag, error) {
+	var tag models.Tag
+	if err := database.DB.Where("id = ? AND user_id = ?", id, userID).First(&tag).Error; err != nil {
+		return nil, err
+	}
+	return &tag, nil
+}
+
+var errEmptyTag = &ValidationError{Fields: map[string]string{"Name": "required"}}
+
+func CreateTag(userID uint, name string) (*models.Tag, error) {
+	tag := models.Tag{UserID: userID, Name: normalizeTag(name)}
+	if tag.Name == "" {
+		return nil, errEmptyTag
+	}
+	err := database.DB.Create(&tag).Error
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return nil, ErrTagExists
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &tag, nil
+}
+
+// RenameTag renames a tag on every transaction it is on.
+func RenameTag(userID uint, id, name string) (*models.Tag, error) {
+	tag, err := GetTag(userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if tag.Name = normalizeTag(name); tag.Name == "" {
+		return nil, errEmptyTag
+	}
+	err = database.DB.Save(tag).Error
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return nil, ErrTagExists
+	}
+	if err != nil {
+		return nil, err
+	}
+	return tag, nil
+}
+
+// DeleteTag deletes a tag and removes it from its transactions. Tags that
+// budgets refer to cannot be deleted.
+func DeleteTag(userID uint, id string) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		var tag models.Tag
+		if err := tx.Where("id = ? AND user_id = ?", id, userID).First(&tag).Error; err != nil {
+			return err
+		}
+
+		var budgets int64
+		if err := tx.Model(&models.Budget{}).Where("tag_id = ?", tag.ID).Count(&budgets).Error; err != nil {
+			return err
+		}
+		if budgets > 0 {
+			return ErrTagInUse
+		}
+
+		return tx.Delete(&tag).Error
+	})
+}
+
+// resolveTags returns the user's tags with the given names, creating the
+// ones that do not exist yet.
+func resolveTags(tx *gorm.DB, userID uint, names []string) ([]models.Tag, error) {
+	seen := map[string]bool{}
+	tags := []models.Tag{}
+	for _, name := range names {
+		name = normalizeTag(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		tags = append(tags, models.Tag{UserID: userID, Name: name})
+	}
+	if len(tags) == 0 {
+		return tags, nil
+	}
+
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&tags).Error; err != nil {
+		return nil, err
+	}
+	// Tags that already existed were skipped and have no ID yet.
+	normalized := make([]string, 0, len(tags))
+	for _, t := range tags {
+		normalized = append(normalized, t.Name)
+	}
+	tags = nil
+	err := tx.Where("user_id = ? AND name IN ?", userID, normalized).Order("name").Find(&tags).Error
+	return tags, err
+}
+
+// tagFilter selects the user's transactions with any, or all, of the tags.
+func tagFilter(db *gorm.DB, userID uint, names []string, all bool) *gorm.DB {
+	seen := map[string]bool{}
+	var normalized []string
+	for _, name := range names {
+		if name = normalizeTag(name); name != "" && !seen[name] {
+			seen[name] = true
+			normalized = append(normalized, name)
+		}
+	}
+	if len(normalized) == 0 {
+		return db
+	}
+
+	tagged := database.DB.Table("transaction_tags tt").
+		Select("tt.transaction_id").
+		Joins("JOIN tags ON tags.id = tt.tag_id").
+		Where("tags.user_id = ? AND tags.name IN ?", userID, normalized)
+	if all {
+		tagged = tagged.Group("tt.transaction_id").Having("COUNT(*) = ?", len(normalized))
+	}
+	return db.Where("transactions.id IN (?)", tagged)
+}
+
+// TagTotal is the income and expense of the transactions with one tag.
+type TagTotal struct {
+	Tag    models.Tag
+	Totals Totals
+}
+
+// GetTagTotals totals the user's transactions per tag in currency, like
+// the balance. A transaction with several tags counts towards each of them.
+// Tags without transactions in the period are included with zero totals.
+func GetTagTotals(userID uint, currency string, period *Period, loc *time.Location) ([]TagTotal, *Conversion, error) {
+	tags, err := ListTags(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	scope := func(db *gorm.DB) *gorm.DB {
+		db = db.Joins("JOIN transaction_tags tt ON tt.transaction_id = transactions.id").
+			Where("transactions.user_id = ?", userID)
+		if period != nil {
+			db = db.Where("date >= ? AND date < ?", period.From, period.To)
+		}
+		return db
+	}
+	byTag, conversion, err := sumTransactionsBy(database.DB, scope, "tt.tag_id", currency, loc)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	totals := make([]TagTotal, len(tags))
+	for i, tag := range tags {
+		totals[i].Tag = tag
+		if t := byTag[tag.ID]; t != nil {
+			totals[i].Totals = *t
+		}
+	}
+	return totals, conversion, nil
+}
@@ -0,0 +1,110 @@
+package services
+
+import (
+	"backend101/database"
+	"backend101/models"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrAccountDisabled       = errors.New("account is disabled")
+	ErrPasswordResetRequired = errors.New("password reset required")
+)
+
+// generateOpaqueToken returns 256 bits of randomness, URL-safe encoded.
+func generateOpaqueToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// generateID returns a random 128-bit hex identifier.
+func generateID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func hashToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+func tokenResponse(access, refresh string) *models.TokenResponse {
+	return &models.TokenResponse{
+		Token:        access,
+		RefreshToken: refresh,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(accessTokenTTL().Seconds()),
+	}
+}
+
+// IssueTokens starts a new session and returns its first access and refresh
+// tokens. Every successful login ends here, so disabled accounts and those
+// waiting for a forced password reset are rejected here.
+func IssueTokens(user *models.User, client ClientInfo) (*models.TokenResponse, error) {
+	if user.DisabledAt != nil {
+		return nil, ErrAccountDisabled
+	}
+	if user.PasswordResetRequired {
+		return nil, ErrPasswordResetRequired
+	}
+
+	session, err := createSession(user.ID, client)
+	if err != nil {
+		return nil, err
+	}
+
+	access, err := GenerateJWT(user.ID, user.Role, session.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	refresh, err := IssueRefreshToken(user.ID, session.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return tokenResponse(access, refresh), nil
+}
+
+// RefreshTokens rotates the refresh token and issues a fresh access token for
+// the same session.
+func RefreshTokens(rawRefresh string, client ClientInfo) (*models.TokenResponse, error) {
+	userID, sessionID, refresh, err := RotateRefreshToken(rawRefresh)
+	if err != nil {
+		return nil, err
+	}
+	if err := extendSession(sessionID, client); err != nil {
+		return nil, err
+	}
+
+	// Reload the user so role changes and disabling take effect on refresh.
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidRefreshToken
+		}
+		return nil, err
+	}
+	if user.DisabledAt != nil {
+		return nil, ErrAccountDisabled
+	}
+
+	access, err := GenerateJWT(user.ID, user.Role, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return tokenResponse(access, refresh), nil
+}
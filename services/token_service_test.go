@@ -0,0 +1,26 @@
+package services
+
+import (
+	"backend101/models"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestIssueTokensRefusesBlockedAccounts(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name string
+		user models.User
+		err  error
+	}{
+		{"disabled", models.User{DisabledAt: &now}, ErrAccountDisabled},
+		{"password reset required", models.User{PasswordResetRequired: true}, ErrPasswordResetRequired},
+	}
+
+	for _, tt := range tests {
+		if _, err := IssueTokens(&tt.user, ClientInfo{}); !errors.Is(err, tt.err) {
+			t.Errorf("%s: IssueTokens() error = %v, want %v", tt.name, err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+// The RFC 6238 appendix B secret, "12345678901234567890", in base32.
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCodeRFC6238(t *testing.T) {
+	// Appendix B lists 8-digit SHA-1 codes; 6-digit codes are their last
+	// six digits.
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, tt := range tests {
+		setClock(t, time.Unix(tt.unix, 0).UTC())
+
+		got, err := TOTPCode(rfc6238Secret, Now())
+		if err != nil {
+			t.Fatalf("TOTPCode(%d) error = %v", tt.unix, err)
+		}
+		if got != tt.want {
+			t.Errorf("TOTPCode(%d) = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestValidateTOTPSkew(t *testing.T) {
+	// The code for the step starting at 1111111110 (step 37037037).
+	issued := time.Unix(1111111110, 0).UTC()
+	code, err := TOTPCode(rfc6238Secret, issued)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		offset time.Duration
+		ok     bool
+	}{
+		{"two steps early", -31 * time.Second, false},
+		{"one step early", -1 * time.Second, true},
+		{"same step, start", 0, true},
+		{"same step, end", 29 * time.Second, true},
+		{"one step late", 30 * time.Second, true},
+		{"end of the window", 59 * time.Second, true},
+		{"two steps late", 60 * time.Second, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setClock(t, issued.Add(tt.offset))
+
+			step, ok := validateTOTP(rfc6238Secret, code, Now(), 0)
+			if ok != tt.ok {
+				t.Fatalf("validateTOTP at %+v = %v, want %v", tt.offset, ok, tt.ok)
+			}
+			if ok && step != totpStep(issued) {
+				t.Errorf("matched step %d, want %d", step, totpStep(issued))
+			}
+		})
+	}
+}
+
+func TestValidateTOTPRefusesUsedSteps(t *testing.T) {
+	now := time.Unix(1111111110, 0).UTC()
+	setClock(t, now)
+	current := totpStep(now)
+
+	currentCode, _ := TOTPCode(rfc6238Secret, now)
+	previousCode, _ := TOTPCode(rfc6238Secret, now.Add(-totpPeriod*time.Second))
+
+	tests := []struct {
+		name     string
+		code     string
+		lastUsed int64
+		ok       bool
+	}{
+		{"fresh code", currentCode, current - 1, true},
+		{"same step again", currentCode, current, false},
+		{"older step after a newer one", previousCode, current, false},
+		{"previous step, not used yet", previousCode, current - 2, true},
+		{"previous step already used", previousCode, current - 1, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := validateTOTP(rfc6238Secret, tt.code, Now(), tt.lastUsed); ok != tt.ok {
+				t.Errorf("validateTOTP(last used %d) = %v, want %v", tt.lastUsed-current, ok, tt.ok)
+			}
+		})
+	}
+}
+
+func TestValidateTOTPMalformed(t *testing.T) {
+	setClock(t, time.Unix(1111111110, 0).UTC())
+
+	for _, code := range []string{"", "08180", "0818044", "abcdef"} {
+		if _, ok := validateTOTP(rfc6238Secret, code, Now(), 0); ok {
+			t.Errorf("validateTOTP(%q) accepted", code)
+		}
+	}
+	if _, ok := validateTOTP("not base32!", "081804", Now(), 0); ok {
+		t.Error("validateTOTP accepted a broken secret")
+	}
+}
@@ -0,0 +1,488 @@
+package services
+
+import (
+	"backend101/config"
+	"backend101/database"
+	"backend101/dto"
+	"backend101/models"
+	"backend101/money"
+	"backend101/utils"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	defaultTransactionPageSize = 50
+	defaultTransactionSort     = "-date"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// transactionSorts maps the allowed sort values to their column. A leading
+// "-" means descending. The ID breaks ties so the order is total.
+var transactionSorts = map[string]string{
+	"date":    "date",
+	"-date":   "date",
+	"amount":  "amount_minor",
+	"-amount": "amount_minor",
+}
+
+// transactionCursor points just past the last row of a page.
+type transactionCursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    uint   `json:"id"`
+}
+
+func encodeTransactionCursor(sort string, tx models.Transaction) string {
+	c := transactionCursor{Sort: sort, ID: tx.ID}
+	switch transactionSorts[sort] {
+	case "date":
+		c.Value = tx.Date.UTC().Format(time.RFC3339Nano)
+	case "amount_minor":
+		c.Value = strconv.FormatInt(tx.AmountMinor, 10)
+	}
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeTransactionCursor returns the sort value of the cursor, typed for
+// the sort column.
+func decodeTransactionCursor(raw, sort string) (interface{}, uint, error) {
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, 0, ErrInvalidCursor
+	}
+	var c transactionCursor
+	if err := json.Unmarshal(data, &c); err != nil || c.Sort != sort {
+		return nil, 0, ErrInvalidCursor
+	}
+
+	switch transactionSorts[sort] {
+	case "date":
+		t, err := time.Parse(time.RFC3339Nano, c.Value)
+		if err != nil {
+			return nil, 0, ErrInvalidCursor
+		}
+		return t, c.ID, nil
+	case "amount_minor":
+		n, err := strconv.ParseInt(c.Value, 10, 64)
+		if err != nil {
+			return nil, 0, ErrInvalidCursor
+		}
+		return n, c.ID, nil
+	}
+	return nil, 0, ErrInvalidCursor
+}
+
+// TransactionList is one page of transactions. NextCursor is nil on the
+// last page and Total counts every match.
+type TransactionList struct {
+	Transactions []models.Transaction
+	NextCursor   *string
+	Total        int64
+}
+
+// ListTransactions returns one page of the user's transactions. Pages are
+// keyset-paginated on (sort column, id), so deep pages cost the same as the
+// first one and rows inserted meanwhile do not shift later pages.
+func ListTransactions(userID uint, q dto.TransactionQuery) (*TransactionList, error) {
+	sort := q.Sort
+	if sort == "" {
+		sort = defaultTransactionSort
+	}
+	column, ok := transactionSorts[sort]
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+	limit := q.Limit
+	if limit == 0 {
+		limit = defaultTransactionPageSize
+	}
+
+	filtered, err := filterTransactions(userID, q)
+	if err != nil {
+		return nil, err
+	}
+
+	var total int64
+	if err := database.DB.Model(&models.Transaction{}).Scopes(filtered).Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	direction, compare := "ASC", ">"
+	if strings.HasPrefix(sort, "-") {
+		direction, compare = "DESC", "<"
+	}
+
+	query := database.DB.Preload("Tags").Scopes(filtered).
+		Order(column + " " + direction).
+		Order("id " + direction).
+		Limit(limit + 1)
+	if q.Cursor != "" {
+		value, id, err := decodeTransactionCursor(q.Cursor, sort)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("("+column+", id) "+compare+" (?, ?)", value, id)
+	}
+
+	transactions := []models.Transaction{}
+	if err := query.Find(&transactions).Error; err != nil {
+		return nil, err
+	}
+
+	page := &TransactionList{Transactions: transactions, Total: total}
+	if len(transactions) > limit {
+		page.Transactions = transactions[:limit]
+		next := encodeTransactionCursor(sort, page.Transactions[limit-1])
+		page.NextCursor = &next
+	}
+	return page, nil
+}
+
+// filterTransactions builds the WHERE clause shared by the page and the
+// total count. Date-only bounds are whole days in the user's time zone, and
+// amount bounds are in the currency filter or the user's preferred currency;
+// they only match transactions in that currency.
+func filterTransactions(userID uint, q dto.TransactionQuery) (func(*gorm.DB) *gorm.DB, error) {
+	var from, to *time.Time
+	var toQuery string
+	var minAmount, maxAmount *int64
+	var amountCurrency string
+	if q.From != "" || q.To != "" || q.MinAmount != "" || q.MaxAmount != "" {
+		prefs, err := GetPreferences(userID)
+		if err != nil {
+			return nil, err
+		}
+		loc := UserLocation(prefs)
+		currency := q.Currency
+		if currency == "" {
+			currency = prefs.Currency
+		}
+
+		if q.MinAmount != "" {
+			n, err := money.Parse(q.MinAmount, currency)
+			if err != nil {
+				return nil, err
+			}
+			minAmount = &n
+		}
+		if q.MaxAmount != "" {
+			n, err := money.Parse(q.MaxAmount, currency)
+			if err != nil {
+				return nil, err
+			}
+			maxAmount = &n
+		}
+		if (minAmount != nil || maxAmount != nil) && q.Currency == "" {
+			amountCurrency = prefs.Currency
+		}
+
+		if q.From != "" {
+			t, _, err := ParseUserDate(q.From, loc)
+			if err != nil {
+				return nil, err
+			}
+			from = &t
+		}
+		if q.To != "" {
+			query, t, err := untilCondition(q.To, loc)
+			if err != nil {
+				return nil, err
+			}
+			toQuery, to = query, &t
+		}
+	}
+
+	return func(db *gorm.DB) *gorm.DB {
+		db = db.Where("user_id = ?", userID)
+		if q.Type != "" {
+			db = db.Where("type = ?", q.Type)
+		}
+		if q.Category != "" {
+			db = db.Where("category = ?", q.Category)
+		}
+		if q.Currency != "" {
+			db = db.Where("currency = ?", strings.ToUpper(q.Currency))
+		}
+		if q.CategoryID != 0 {
+			db = db.Where("category_id IN (?)", database.DB.Model(&models.Category{}).Select("id").
+				Where("user_id = ? AND (id = ? OR parent_id = ?)", userID, q.CategoryID, q.CategoryID))
+		}
+		if q.AccountID != 0 {
+			db = db.Where("account_id = ?", q.AccountID)
+		}
+		if amountCurrency != "" {
+			db = db.Where("currency = ?", amountCurrency)
+		}
+		if minAmount != nil {
+			db = db.Where("amount_minor >= ?", *minAmount)
+		}
+		if maxAmount != nil {
+			db = db.Where("amount_minor <= ?", *maxAmount)
+		}
+		if from != nil {
+			db = db.Where("date >= ?", *from)
+		}
+		if to != nil {
+			db = db.Where(toQuery, *to)
+		}
+		if q.Q != "" {
+			db = db.Where("description ILIKE ?", "%"+escapeLike(q.Q)+"%")
+		}
+		if len(q.Tags) > 0 {
+			db = tagFilter(db, userID, q.Tags, q.TagMode == "all")
+		}
+		return db
+	}, nil
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// ValidationError carries per-field validation failures.
+type ValidationError struct {
+	Fields map[string]string
+}
+
+func (e *ValidationError) Error() string {
+	return "validation failed"
+}
+
+var (
+	ErrDateTooFarInFuture = errors.New("date is too far in the future")
+	ErrDateTooOld         = errors.New("date is too far in the past")
+)
+
+// Transactions dated before this are assumed to be typos.
+var earliestTransactionDate = time.Date(1900, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// resolveTransactionDate parses a client-supplied date in the user's time
+// zone and applies the date policy: at most TRANSACTION_MAX_FUTURE_DAYS days
+// ahead of today, and not before 1900.
+func resolveTransactionDate(userID uint, raw string) (time.Time, error) {
+	prefs, err := GetPreferences(userID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	loc := UserLocation(prefs)
+
+	date, _, err := ParseUserDate(raw, loc)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	now := Now().In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	latest := today.AddDate(0, 0, config.GetInt("TRANSACTION_MAX_FUTURE_DAYS")+1)
+	if !date.Before(latest) {
+		return time.Time{}, ErrDateTooFarInFuture
+	}
+	if date.Before(earliestTransactionDate) {
+		return time.Time{}, ErrDateTooOld
+	}
+	return date, nil
+}
+
+func validateTransaction(tx *models.Transaction) error {
+	if fields := utils.ValidateStruct(tx); fields != nil {
+		return &ValidationError{Fields: fields}
+	}
+	return nil
+}
+
+// CreateTransaction stores a new transaction. Without a currency the
+// account's currency is used, or else the user's preferred currency.
+func CreateTransaction(userID uint, input dto.CreateTransactionInput) (*models.Transaction, error) {
+	var account *models.Account
+	if input.AccountID != nil {
+		var err error
+		if account, err = activeAccount(userID, *input.AccountID); err != nil {
+			return nil, err
+		}
+	}
+
+	currency := ""
+	if input.Currency != nil {
+		currency = strings.ToUpper(*input.Currency)
+	} else if account != nil {
+		currency = account.Currency
+	} else {
+		prefs, err := GetPreferences(userID)
+		if err != nil {
+			return nil, err
+		}
+		currency = prefs.Currency
+	}
+
+	amount, err := money.Parse(input.Amount.String(), currency)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := models.Transaction{
+		UserID:      userID,
+		AccountID:   input.AccountID,
+		AmountMinor: amount,
+		Currency:    currency,
+		Type:        input.Type,
+		Description: input.Description,
+		Date:        Now(),
+	}
+	if input.Date != nil {
+		date, err := resolveTransactionDate(userID, *input.Date)
+		if err != nil {
+			return nil, err
+		}
+		tx.Date = date
+	}
+
+	err = database.DB.Transaction(func(db *gorm.DB) error {
+		category, err := resolveTransactionCategory(db, userID, input.CategoryID, input.Category, tx.Type)
+		if err != nil {
+			return err
+		}
+		tx.CategoryID, tx.Category = &category.ID, category.Name
+
+		if tx.Tags, err = resolveTags(db, userID, input.Tags); err != nil {
+			return err
+		}
+
+		if err := validateTransaction(&tx); err != nil {
+			return err
+		}
+		// The tags exist already; only link them.
+		return db.Omit("Tags.*").Create(&tx).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	checkAlertsAsync(userID, &tx)
+	return &tx, nil
+}
+
+func GetTransaction(userID uint, id string) (*models.Transaction, error) {
+	var tx models.Transaction
+	if err := database.DB.Preload("Tags").Where("id = ? AND user_id = ?", id, userID).First(&tx).Error; err != nil {
+		return nil, err
+	}
+	return &tx, nil
+}
+
+// UpdateTransaction applies the fields that are set in input; everything
+// else, including the date, keeps its current value. Transfer legs can only
+// be changed through their transfer, so both legs stay in step.
+func UpdateTransaction(userID uint, id string, input dto.PatchTransactionInput) (*models.Transaction, error) {
+	tx, err := GetTransaction(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if tx.TransferID != nil {
+		return nil, ErrTransferLeg
+	}
+
+	if input.Currency != nil || input.Amount != nil {
+		currency := tx.Currency
+		if input.Currency != nil {
+			currency = strings.ToUpper(*input.Currency)
+		}
+		// Keep the same decimal amount when only the currency changes.
+		amount := money.Format(tx.AmountMinor, tx.Currency)
+		if input.Amount != nil {
+			amount = input.Amount.String()
+		}
+
+		minor, err := money.Parse(amount, currency)
+		if err != nil {
+			return nil, err
+		}
+		tx.AmountMinor, tx.Currency = minor, currency
+	}
+	if input.Type != nil {
+		tx.Type = *input.Type
+	}
+	if input.Description != nil {
+		tx.Description = *input.Description
+	}
+	if input.Date != nil {
+		date, err := resolveTransactionDate(userID, *input.Date)
+		if err != nil {
+			return nil, err
+		}
+		tx.Date = date
+	}
+	if input.AccountID != nil {
+		if *input.AccountID == 0 {
+			tx.AccountID = nil
+		} else if tx.AccountID == nil || *tx.AccountID != *input.AccountID {
+			if _, err := activeAccount(userID, *input.AccountID); err != nil {
+				return nil, err
+			}
+			tx.AccountID = input.AccountID
+		}
+	}
+
+	err = database.DB.Transaction(func(db *gorm.DB) error {
+		// A new type needs a category of that type, looked up by name.
+		if input.CategoryID != nil || input.Category != nil || input.Type != nil {
+			name := tx.Category
+			if input.Category != nil {
+				name = *input.Category
+			}
+			category, err := resolveTransactionCategory(db, userID, input.CategoryID, name, tx.Type)
+			if err != nil {
+				return err
+			}
+			tx.CategoryID, tx.Category = &category.ID, category.Name
+		}
+
+		if err := validateTransaction(tx); err != nil {
+			return err
+		}
+		if err := db.Omit("Tags").Save(tx).Error; err != nil {
+			return err
+		}
+
+		if input.Tags != nil {
+			tags, err := resolveTags(db, userID, input.Tags)
+			if err != nil {
+				return err
+			}
+			if err := db.Model(tx).Omit("Tags.*").Association("Tags").Replace(tags); err != nil {
+				return err
+			}
+			tx.Tags = tags
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	checkAlertsAsync(userID, tx)
+	return tx, nil
+}
+
+// DeleteTransaction deletes a transaction. Deleting either leg of a transfer
+// deletes the whole transfer.
+func DeleteTransaction(userID uint, id string) error {
+	tx, err := GetTransaction(userID, id)
+	if err != nil {
+		return err
+	}
+	if tx.TransferID != nil {
+		return DeleteTransfer(userID, *tx.TransferID)
+	}
+	if err := database.DB.Delete(tx).Error; err != nil {
+		return err
+	}
+	checkAlertsAsync(userID, nil)
+	return nil
+}
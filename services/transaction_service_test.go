@@ -0,0 +1,44 @@
+package services
+
+import (
+	"backend101/database"
+	"backend101/dto"
+	"testing"
+	"time"
+)
+
+func TestAmountFilterMatchesOnlyItsCurrency(t *testing.T) {
+	useTestDB(t)
+	setClock(t, time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC))
+	user := createTestUser(t, "amounts@example.com")
+
+	// 10.00 USD, ¥1000 and 1.000 KWD all have amount_minor 1000.
+	ids := map[string]uint{}
+	for _, currency := range []string{"USD", "JPY", "KWD"} {
+		tx := addTestTransaction(t, user.ID, "expense", 1000, nil, Now())
+		if err := database.DB.Model(&tx).Update("currency", currency).Error; err != nil {
+			t.Fatal(err)
+		}
+		ids[currency] = tx.ID
+	}
+
+	tests := []struct {
+		name  string
+		query dto.TransactionQuery
+		want  string
+	}{
+		{"preferred currency", dto.TransactionQuery{MinAmount: "10"}, "USD"},
+		{"currency filter", dto.TransactionQuery{MinAmount: "1000", Currency: "JPY"}, "JPY"},
+		{"maximum only", dto.TransactionQuery{MaxAmount: "1", Currency: "KWD"}, "KWD"},
+	}
+
+	for _, tt := range tests {
+		list, err := ListTransactions(user.ID, tt.query)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if list.Total != 1 || len(list.Transactions) != 1 || list.Transactions[0].ID != ids[tt.want] {
+			t.Errorf("%s: got %d transactions %+v, want only the %s one", tt.name, list.Total, list.Transactions, tt.want)
+		}
+	}
+}
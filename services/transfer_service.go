@@ -0,0 +1,261 @@
+package services
+
+import (
+	"backend101/database"
+	"backend101/dto"
+	"backend101/models"
+	"backend101/money"
+	"errors"
+	"math/big"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+const transferCategory = "Transfer"
+
+var (
+	ErrSameAccount          = errors.New("cannot transfer to the same account")
+	ErrTransferRateRequired = errors.New("rate or to_amount is required between currencies")
+	ErrInvalidTransferRate  = errors.New("invalid transfer rate")
+	ErrTransferLeg          = errors.New("transaction is part of a transfer")
+)
+
+// transferAmounts works out both legs of a transfer from the amount sent and
+// either a rate or the amount received. The rate is returned as stored.
+func transferAmounts(from, to string, amount money.Decimal, rate, toAmount *money.Decimal) (int64, int64, string, error) {
+	out, err := money.Parse(amount.String(), from)
+	if err != nil {
+		return 0, 0, "", err
+	}
+
+	if from == to {
+		if rate != nil {
+			r, ok := new(big.Rat).SetString(strings.TrimSpace(rate.String()))
+			if !ok || r.Cmp(big.NewRat(1, 1)) != 0 {
+				return 0, 0, "", ErrInvalidTransferRate
+			}
+		}
+		if toAmount != nil {
+			in, err := money.Parse(toAmount.String(), to)
+			if err != nil {
+				return 0, 0, "", err
+			}
+			if in != out {
+				return 0, 0, "", ErrInvalidTransferRate
+			}
+		}
+		return out, out, "1", nil
+	}
+
+	switch {
+	case rate != nil && toAmount == nil:
+		r, ok := new(big.Rat).SetString(strings.TrimSpace(rate.String()))
+		if !ok || r.Sign() <= 0 {
+			return 0, 0, "", ErrInvalidTransferRate
+		}
+		return out, convertMinor(out, from, to, r), r.FloatString(12), nil
+	case toAmount != nil && rate == nil:
+		in, err := money.Parse(toAmount.String(), to)
+		if err != nil {
+			return 0, 0, "", err
+		}
+		if out <= 0 || in <= 0 {
+			return out, in, "0", nil // rejected by leg validation
+		}
+		// rate = (in / 10^exp(to)) / (out / 10^exp(from))
+		r := new(big.Rat).SetFrac64(in, out)
+		r.Mul(r, new(big.Rat).SetFrac(pow10(money.Exponent(from)), pow10(money.Exponent(to))))
+		return out, in, r.FloatString(12), nil
+	}
+	return 0, 0, "", ErrTransferRateRequired
+}
+
+// CreateTransfer books a transfer between two active accounts of the user as
+// a pair of linked transactions, atomically.
+func CreateTransfer(userID uint, input dto.CreateTransferInput) (*models.Transfer, error) {
+	if input.FromAccountID == input.ToAccountID {
+		return nil, ErrSameAccount
+	}
+	from, err := activeAccount(userID, input.FromAccountID)
+	if err != nil {
+		return nil, err
+	}
+	to, err := activeAccount(userID, input.ToAccountID)
+	if err != nil {
+		return nil, err
+	}
+
+	out, in, rate, err := transferAmounts(from.Currency, to.Currency, input.Amount, input.Rate, input.ToAmount)
+	if err != nil {
+		return nil, err
+	}
+
+	transfer := models.Transfer{
+		UserID:        userID,
+		FromAccountID: from.ID,
+		ToAccountID:   to.ID,
+		Rate:          rate,
+		Date:          Now(),
+		Description:   strings.TrimSpace(input.Description),
+	}
+	if input.Date != nil {
+		if transfer.Date, err = resolveTransactionDate(userID, *input.Date); err != nil {
+			return nil, err
+		}
+	}
+
+	outDescription, inDescription := transfer.Description, transfer.Description
+	if transfer.Description == "" {
+		outDescription, inDescription = "Transfer to "+to.Name, "Transfer from "+from.Name
+	}
+	transfer.Legs = []models.Transaction{
+		{
+			UserID:      userID,
+			AccountID:   &from.ID,
+			AmountMinor: out,
+			Currency:    from.Currency,
+			Category:    transferCategory,
+			Description: outDescription,
+			Type:        models.TransactionTransferOut,
+			Date:        transfer.Date,
+		},
+		{
+			UserID:      userID,
+			AccountID:   &to.ID,
+			AmountMinor: in,
+			Currency:    to.Currency,
+			Category:    transferCategory,
+			Description: inDescription,
+			Type:        models.TransactionTransferIn,
+			Date:        transfer.Date,
+		},
+	}
+	for i := range transfer.Legs {
+		if err := validateTransaction(&transfer.Legs[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	// Creating the transfer also creates its legs, in one transaction.
+	if err := database.DB.Create(&transfer).Error; err != nil {
+		return nil, err
+	}
+	return &transfer, nil
+}
+
+// ListTransfers returns the user's transfers, newest first.
+func ListTransfers(userID uint, q dto.TransferQuery) ([]models.Transfer, error) {
+	db := database.DB.Preload("Legs").Where("user_id = ?", userID)
+	if q.AccountID != 0 {
+		db = db.Where("from_account_id = ? OR to_account_id = ?", q.AccountID, q.AccountID)
+	}
+	if q.From != "" || q.To != "" {
+		prefs, err := GetPreferences(userID)
+		if err != nil {
+			return nil, err
+		}
+		loc := UserLocation(prefs)
+		if q.From != "" {
+			t, _, err := ParseUserDate(q.From, loc)
+			if err != nil {
+				return nil, err
+			}
+			db = db.Where("date >= ?", t)
+		}
+		if q.To != "" {
+			query, t, err := untilCondition(q.To, loc)
+			if err != nil {
+				return nil, err
+			}
+			db = db.Where(query, t)
+		}
+	}
+
+	transfers := []models.Transfer{}
+	err := db.Order("date DESC, id DESC").Find(&transfers).Error
+	return transfers, err
+}
+
+func GetTransfer(userID uint, id interface{}) (*models.Transfer, error) {
+	var transfer models.Transfer
+	if err := database.DB.Preload("Legs").Where("id = ? AND user_id = ?", id, userID).First(&transfer).Error; err != nil {
+		return nil, err
+	}
+	return &transfer, nil
+}
+
+// UpdateTransfer changes a transfer and both its legs together.
+func UpdateTransfer(userID uint, id string, input dto.UpdateTransferInput) (*models.Transfer, error) {
+	transfer, err := GetTransfer(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	out, in := transfer.Leg(models.TransactionTransferOut), transfer.Leg(models.TransactionTransferIn)
+	if out == nil || in == nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	if input.Amount != nil || input.Rate != nil || input.ToAmount != nil {
+		amount := money.Decimal(money.Format(out.AmountMinor, out.Currency))
+		if input.Amount != nil {
+			amount = *input.Amount
+		}
+		rate := input.Rate
+		if rate == nil && input.ToAmount == nil {
+			// Only the amount changed: keep the rate.
+			kept := money.Decimal(transfer.Rate)
+			rate = &kept
+		}
+
+		if out.AmountMinor, in.AmountMinor, transfer.Rate, err = transferAmounts(out.Currency, in.Currency, amount, rate, input.ToAmount); err != nil {
+			return nil, err
+		}
+	}
+	if input.Description != nil {
+		transfer.Description = strings.TrimSpace(*input.Description)
+		if transfer.Description != "" {
+			out.Description, in.Description = transfer.Description, transfer.Description
+		}
+	}
+	if input.Date != nil {
+		if transfer.Date, err = resolveTransactionDate(userID, *input.Date); err != nil {
+			return nil, err
+		}
+		out.Date, in.Date = transfer.Date, transfer.Date
+	}
+
+	for _, leg := range []*models.Transaction{out, in} {
+		if err := validateTransaction(leg); err != nil {
+			return nil, err
+		}
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Legs").Save(transfer).Error; err != nil {
+			return err
+		}
+		if err := tx.Save(out).Error; err != nil {
+			return err
+		}
+		return tx.Save(in).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return transfer, nil
+}
+
+// DeleteTransfer deletes a transfer together with both its legs.
+func DeleteTransfer(userID uint, id interface{}) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		var transfer models.Transfer
+		if err := tx.Where("id = ? AND user_id = ?", id, userID).First(&transfer).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("transfer_id = ?", transfer.ID).Delete(&models.Transaction{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&transfer).Error
+	})
+}
@@ -0,0 +1,71 @@
+package services
+
+import (
+	"backend101/database"
+	"backend101/models"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrInvalidUserToken = errors.New("invalid or expired token")
+
+// IssueUserToken creates a single-use token for purpose and invalidates any
+// earlier unused token with the same purpose.
+func IssueUserToken(userID uint, purpose string, ttl time.Duration) (string, error) {
+	return issueUserToken(userID, purpose, "", ttl)
+}
+
+func issueUserToken(userID uint, purpose, payload string, ttl time.Duration) (string, error) {
+	raw, err := generateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+			Delete(&models.UserToken{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(&models.UserToken{
+			UserID:    userID,
+			Purpose:   purpose,
+			TokenHash: hashToken(raw),
+			Payload:   payload,
+			ExpiresAt: Now().Add(ttl),
+		}).Error
+	})
+	if err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+// consumeUserToken marks the token as used inside tx. The row is locked so
+// that two concurrent requests cannot both redeem it.
+func consumeUserToken(tx *gorm.DB, raw, purpose string) (*models.UserToken, error) {
+	var token models.UserToken
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("token_hash = ? AND purpose = ?", hashToken(raw), purpose).
+		First(&token).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalidUserToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if token.UsedAt != nil || !Now().Before(token.ExpiresAt) {
+		return nil, ErrInvalidUserToken
+	}
+
+	now := Now()
+	if err := tx.Model(&token).Update("used_at", now).Error; err != nil {
+		return nil, err
+	}
+	token.UsedAt = &now
+	return &token, nil
+}